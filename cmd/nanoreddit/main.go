@@ -11,17 +11,27 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"nanoreddit/internal/ads"
 	"nanoreddit/internal/handler"
+	"nanoreddit/internal/live"
 	"nanoreddit/internal/materializer"
+	"nanoreddit/internal/middleware"
 	"nanoreddit/internal/server"
 	"nanoreddit/internal/signal"
+	"nanoreddit/internal/spam"
 	"nanoreddit/internal/storage"
 )
 
 type config struct {
 	Server       server.Config
+	Handler      handler.Config
+	Auth         middleware.Config
+	RateLimit    middleware.RateLimitConfig
+	Spam         spam.Config
 	Storage      storage.Config
 	Materializer materializer.Config
+	Ads          ads.Config
+	Live         live.Config
 	RedisURL     string `env:"REDIS_URL,default=redis://localhost:6379/0"`
 	Logger       struct {
 		Level     string `env:"LOGGER_LEVEL,default=info"`
@@ -95,16 +105,33 @@ func main() {
 	}
 	{
 		srv := materializer.NewService(ctx, cancel, redisClient, &cfg.Materializer)
+		if err := srv.Migrate(); err != nil {
+			zerolog.Ctx(ctx).Fatal().Err(err).Msg("Couldn't migrate data")
+			return
+		}
+		g.Add(srv.Execute, srv.Interrupt)
+	}
+	{
+		srv := ads.NewService(ctx, cancel, redisClient, &cfg.Ads)
 		g.Add(srv.Execute, srv.Interrupt)
 	}
+	updates := live.NewService(ctx, cancel, redisClient, &cfg.Live)
+	g.Add(updates.Execute, updates.Interrupt)
 	{
-		handler, err := handler.NewHandler(storage)
+		sessions := middleware.NewSessions(&cfg.Auth)
+		scorer, err := spam.NewScorer(&cfg.Spam, storage)
+		if err != nil {
+			zerolog.Ctx(ctx).Fatal().Err(err).Msg("Couldn't initialize a spam scorer")
+			return
+		}
+		handler, err := handler.NewHandler(&cfg.Handler, storage, sessions, scorer, updates)
 		if err != nil {
 			zerolog.Ctx(ctx).Fatal().Err(err).Msg("Couldn't initialize an endpoints handler")
 			return
 		}
 		//TODO use dependency injection github.com/google/wire
-		srv := server.NewService(ctx, &cfg.Server, handler)
+		limiter := middleware.NewRateLimiter(redisClient, &cfg.RateLimit)
+		srv := server.NewService(ctx, &cfg.Server, sessions, limiter, &cfg.RateLimit, storage, handler)
 		g.Add(srv.Execute, srv.Interrupt)
 	}
 
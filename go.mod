@@ -3,11 +3,13 @@ module nanoreddit
 go 1.15
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/go-chi/chi v1.5.1
 	github.com/go-chi/render v1.0.1
 	github.com/go-playground/validator/v10 v10.4.1
 	github.com/go-redis/redis/v8 v8.4.8
 	github.com/go-resty/resty/v2 v2.4.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joeshaw/envdecode v0.0.0-20200121155833-099f1fc765bd
 	github.com/oklog/run v1.1.0
 	github.com/rs/zerolog v1.20.0
@@ -0,0 +1,15 @@
+package ads
+
+import "time"
+
+type Config struct {
+	// Interval is how often the scheduler puts campaigns into the ring and takes them out of it.
+	Interval time.Duration `env:"ADS_SCHEDULE_INTERVAL,default=1m"`
+	// Lock lets a single replica run the scheduler at a time.
+	Lock        string `env:"ADS_SCHEDULER_LOCK,default=ads_scheduler"`
+	Promotion   string `env:"ES_PROMOTION_WEIGHTS,default=promotion_weights"`
+	Targets     string `env:"ES_PROMOTION_TARGETS,default=promotion_targets"`
+	Posts       string `env:"ES_POSTS,default=post_by_id"`
+	Campaigns   string `env:"ES_CAMPAIGNS,default=campaign_by_id"`
+	Impressions string `env:"ES_IMPRESSIONS,default=impressions"`
+}
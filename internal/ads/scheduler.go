@@ -0,0 +1,171 @@
+package ads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
+)
+
+// service is the ads scheduler. It keeps posts of campaigns which are live and on pace with their daily budgets
+// in the rotation of promoted posts weighted by the budgets along with their targeting, and it takes the rest out
+// of it.
+type service struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *Config
+	client redis.Cmdable
+	now    func() time.Time
+}
+
+func (s *service) Execute() error {
+	ctx := s.ctx
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		// A failed run is retried on the next tick, the rotation stays as it is meanwhile.
+		if err := s.schedule(ctx); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't schedule campaigns")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *service) schedule(ctx context.Context) error {
+	// The lock expires by itself, so a replica which has died holding it doesn't stop scheduling.
+	locked, err := s.client.SetNX(ctx, s.cfg.Lock, 1, s.cfg.Interval).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't take a lock: %w", err)
+	}
+	if !locked {
+		return nil
+	}
+
+	blobs, err := s.client.HVals(ctx, s.cfg.Campaigns).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't load campaigns: %w", err)
+	}
+	if len(blobs) == 0 {
+		return nil
+	}
+	now := s.now()
+	campaigns := make([]protocol.Campaign, len(blobs))
+	postIDs := make([]string, len(blobs))
+	impressions := make([]string, len(blobs))
+	for i, blob := range blobs {
+		if err := json.Unmarshal([]byte(blob), &campaigns[i]); err != nil {
+			return fmt.Errorf("couldn't unmarshal a campaign: %w", err)
+		}
+		postIDs[i] = campaigns[i].PostID
+		impressions[i] = storage.ImpressionsKey(s.cfg.Impressions, campaigns[i].ID, now)
+	}
+
+	posts, err := s.client.HMGet(ctx, s.cfg.Posts, postIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't load posts: %w", err)
+	}
+	spent, err := s.client.MGet(ctx, impressions...).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't load impressions: %w", err)
+	}
+	weights, err := s.client.HMGet(ctx, s.cfg.Promotion, postIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't load the rotation: %w", err)
+	}
+	targets, err := s.client.HMGet(ctx, s.cfg.Targets, postIDs...).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't load targeting of the rotation: %w", err)
+	}
+
+	for i := range campaigns {
+		eligible, err := s.eligible(&campaigns[i], posts[i], spent[i], now)
+		if err != nil {
+			return err
+		}
+		id := campaigns[i].PostID
+		// A campaign is shown in proportion to its budget.
+		weight := strconv.Itoa(campaigns[i].DailyBudget)
+		targeting, err := json.Marshal(&campaigns[i].Targeting)
+		if err != nil {
+			return fmt.Errorf("couldn't marshal targeting: %w", err)
+		}
+		switch {
+		case eligible && (weights[i] != weight || targets[i] != string(targeting)):
+			// Targeting goes first, so a post never takes part in rotations it isn't targeted at.
+			if err := s.client.HSet(ctx, s.cfg.Targets, id, string(targeting)).Err(); err != nil {
+				return fmt.Errorf("couldn't save targeting of a promoted post: %w", err)
+			}
+			if err := s.client.HSet(ctx, s.cfg.Promotion, id, weight).Err(); err != nil {
+				return fmt.Errorf("couldn't put a promoted post into the rotation: %w", err)
+			}
+			zerolog.Ctx(ctx).Debug().Str("campaign", campaigns[i].ID).Msg("A campaign has been put into the rotation")
+		case !eligible && (weights[i] != nil || targets[i] != nil):
+			if err := s.client.HDel(ctx, s.cfg.Promotion, id).Err(); err != nil {
+				return fmt.Errorf("couldn't remove a post from the rotation: %w", err)
+			}
+			if err := s.client.HDel(ctx, s.cfg.Targets, id).Err(); err != nil {
+				return fmt.Errorf("couldn't remove targeting of a promoted post: %w", err)
+			}
+			zerolog.Ctx(ctx).Debug().Str("campaign", campaigns[i].ID).Msg("A campaign has been taken out of the rotation")
+		}
+	}
+	return nil
+}
+
+// eligible tells whether a campaign should be in the rotation: it's live, it isn't ahead of the pace of its daily
+// budget, and its post hasn't been removed by moderators. A campaign may run ahead of the pace by an interval
+// of the scheduler, since it's out of the rotation until the next run otherwise.
+func (s *service) eligible(campaign *protocol.Campaign, postBlob, spentVal interface{}, now time.Time) (bool, error) {
+	if !campaign.Live(now.Unix()) {
+		return false, nil
+	}
+	if spentVal, ok := spentVal.(string); ok {
+		spent, err := strconv.Atoi(spentVal)
+		if err != nil {
+			return false, fmt.Errorf("couldn't parse impressions: %w", err)
+		}
+		if int64(spent) >= storage.PacedBudget(campaign.DailyBudget, now, s.cfg.Interval) {
+			return false, nil
+		}
+	}
+
+	// A post may not be materialized yet.
+	blob, ok := postBlob.(string)
+	if !ok {
+		return false, nil
+	}
+	var post protocol.Post
+	if err := json.Unmarshal([]byte(blob), &post); err != nil {
+		return false, fmt.Errorf("couldn't unmarshal a saved post: %w", err)
+	}
+	return !post.Deleted && !post.Removed, nil
+}
+
+func (s *service) Interrupt(err error) {
+	s.cancel()
+}
+
+func NewService(ctx context.Context, cancel context.CancelFunc, client redis.Cmdable, cfg *Config) *service {
+	l := zerolog.Ctx(ctx).With().Str("service", "ads").Logger()
+	ctx = l.WithContext(ctx)
+
+	return &service{
+		ctx:    ctx,
+		cancel: cancel,
+		client: client,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
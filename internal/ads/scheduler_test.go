@@ -0,0 +1,153 @@
+package ads
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRedis struct {
+	redis.Cmdable
+
+	m *mock.Mock
+}
+
+func (m *mockRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	args := m.m.Called(ctx, key, value, expiration)
+	return args.Get(0).(*redis.BoolCmd)
+}
+
+func (m *mockRedis) HVals(ctx context.Context, key string) *redis.StringSliceCmd {
+	args := m.m.Called(ctx, key)
+	return args.Get(0).(*redis.StringSliceCmd)
+}
+
+func (m *mockRedis) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	args := m.m.Called(ctx, key, fields)
+	return args.Get(0).(*redis.SliceCmd)
+}
+
+func (m *mockRedis) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	args := m.m.Called(ctx, keys)
+	return args.Get(0).(*redis.SliceCmd)
+}
+
+func (m *mockRedis) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	args := m.m.Called(ctx, key, values)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	args := m.m.Called(ctx, key, fields)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func TestSchedule(t *testing.T) {
+	Convey("Test the ads scheduler", t, func() {
+		m := &mock.Mock{}
+		srv := service{
+			ctx: context.Background(),
+			cfg: &Config{
+				Interval:    time.Minute,
+				Lock:        "ads_scheduler",
+				Promotion:   "promotion_weights",
+				Targets:     "promotion_targets",
+				Posts:       "post_by_id",
+				Campaigns:   "campaign_by_id",
+				Impressions: "impressions",
+			},
+			client: &mockRedis{m: m},
+			now: func() time.Time {
+				return time.Unix(1612008000, 0)
+			},
+		}
+
+		Convey("Another replica holds the lock", func() {
+			m.
+				On("SetNX", mock.Anything, "ads_scheduler", 1, time.Minute).Return(redis.NewBoolResult(false, nil))
+
+			So(srv.schedule(srv.ctx), ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if campaigns cannot be loaded", func() {
+			m.
+				On("SetNX", mock.Anything, "ads_scheduler", 1, time.Minute).Return(redis.NewBoolResult(true, nil)).
+				On("HVals", mock.Anything, "campaign_by_id").Return(redis.NewStringSliceResult(nil, errors.New("error")))
+
+			err := srv.schedule(srv.ctx)
+
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldEqual, "couldn't load campaigns: error")
+		})
+
+		Convey("Successful story", func() {
+			// It's noon, so a campaign may have spent a half of its budget and a minute more.
+			campaigns := []string{
+				// It's live and on pace, but it isn't in the rotation yet.
+				`{"id":"1","post_id":"2","start":1612000000,"end":1613000000,"daily_budget":100}`,
+				// It's spent its budget.
+				`{"id":"3","post_id":"4","start":1612000000,"end":1613000000,"daily_budget":100}`,
+				// It's paused.
+				`{"id":"5","post_id":"6","start":1612000000,"end":1613000000,"daily_budget":100,"paused":true}`,
+				// Its post has been removed.
+				`{"id":"7","post_id":"8","start":1612000000,"end":1613000000,"daily_budget":100}`,
+				// It's live and already in the rotation.
+				`{"id":"9","post_id":"a","start":1612000000,"end":1613000000,"daily_budget":100}`,
+				// Its budget has been raised.
+				`{"id":"b","post_id":"c","start":1612000000,"end":1613000000,"daily_budget":200}`,
+				// It's ahead of the pace.
+				`{"id":"d","post_id":"e","start":1612000000,"end":1613000000,"daily_budget":100}`,
+				// Its targeting has been changed.
+				`{"id":"f","post_id":"10","start":1612000000,"end":1613000000,"daily_budget":100,"targeting":{"excluded_subreddits":["Games"]}}`,
+			}
+			postIDs := []string{"2", "4", "6", "8", "a", "c", "e", "10"}
+			posts := []interface{}{
+				`{"id":"2","promoted":true}`,
+				`{"id":"4","promoted":true}`,
+				`{"id":"6","promoted":true}`,
+				`{"id":"8","promoted":true,"removed":true}`,
+				`{"id":"a","promoted":true}`,
+				`{"id":"c","promoted":true}`,
+				`{"id":"e","promoted":true}`,
+				`{"id":"10","promoted":true}`,
+			}
+			impressions := []string{
+				"impressions:1:2021-01-30",
+				"impressions:3:2021-01-30",
+				"impressions:5:2021-01-30",
+				"impressions:7:2021-01-30",
+				"impressions:9:2021-01-30",
+				"impressions:b:2021-01-30",
+				"impressions:d:2021-01-30",
+				"impressions:f:2021-01-30",
+			}
+			m.
+				On("SetNX", mock.Anything, "ads_scheduler", 1, time.Minute).Return(redis.NewBoolResult(true, nil)).
+				On("HVals", mock.Anything, "campaign_by_id").Return(redis.NewStringSliceResult(campaigns, nil)).
+				On("HMGet", mock.Anything, "post_by_id", postIDs).Return(redis.NewSliceResult(posts, nil)).
+				On("MGet", mock.Anything, impressions).Return(redis.NewSliceResult([]interface{}{"10", "100", nil, nil, "5", "60", "60", nil}, nil)).
+				On("HMGet", mock.Anything, "promotion_weights", postIDs).Return(redis.NewSliceResult([]interface{}{nil, "100", "100", "100", "100", "100", "100", "100"}, nil)).
+				On("HMGet", mock.Anything, "promotion_targets", postIDs).Return(redis.NewSliceResult([]interface{}{nil, "{}", "{}", "{}", "{}", "{}", "{}", "{}"}, nil)).
+				On("HSet", mock.Anything, "promotion_targets", []interface{}{"2", "{}"}).Return(redis.NewIntResult(1, nil)).Once().
+				On("HSet", mock.Anything, "promotion_weights", []interface{}{"2", "100"}).Return(redis.NewIntResult(1, nil)).Once().
+				On("HSet", mock.Anything, "promotion_targets", []interface{}{"c", "{}"}).Return(redis.NewIntResult(0, nil)).Once().
+				On("HSet", mock.Anything, "promotion_weights", []interface{}{"c", "200"}).Return(redis.NewIntResult(0, nil)).Once().
+				On("HSet", mock.Anything, "promotion_targets", []interface{}{"10", `{"excluded_subreddits":["Games"]}`}).Return(redis.NewIntResult(0, nil)).Once().
+				On("HSet", mock.Anything, "promotion_weights", []interface{}{"10", "100"}).Return(redis.NewIntResult(0, nil)).Once()
+			for _, id := range []string{"4", "6", "8", "e"} {
+				m.
+					On("HDel", mock.Anything, "promotion_weights", []string{id}).Return(redis.NewIntResult(1, nil)).Once().
+					On("HDel", mock.Anything, "promotion_targets", []string{id}).Return(redis.NewIntResult(1, nil)).Once()
+			}
+
+			So(srv.schedule(srv.ctx), ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package chi_utils
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/render"
@@ -13,6 +14,8 @@ type errResponse struct {
 	HTTPStatusCode int `json:"-"` // http response status code
 
 	protocol.ErrorResponse
+	// Data refers to what the error is about, if anything.
+	Data interface{} `json:"data,omitempty"`
 }
 
 func (e *errResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -30,34 +33,102 @@ func (rr *responseRender) render(w http.ResponseWriter, r *http.Request, respons
 	}
 }
 
-func (rr *responseRender) InvalidRequest(w http.ResponseWriter, r *http.Request, err error) {
+func (rr *responseRender) fail(w http.ResponseWriter, r *http.Request, status int, description string) {
+	rr.failWithReason(w, r, status, "", description)
+}
+
+func (rr *responseRender) failWithReason(w http.ResponseWriter, r *http.Request, status int, reason, description string) {
 	rr.render(w, r, &errResponse{
-		HTTPStatusCode: http.StatusBadRequest,
+		HTTPStatusCode: status,
 		ErrorResponse: protocol.ErrorResponse{
 			Errors: []protocol.Error{
 				{
-					Code:        http.StatusBadRequest,
-					Description: err.Error(),
+					Code:        int32(status),
+					Description: description,
+					Reason:      reason,
 				},
 			},
 		},
 	})
 }
 
-func (rr *responseRender) InternalServerError(w http.ResponseWriter, r *http.Request, err error) {
+// InvalidRequest renders every invalid field as a separate error, so clients can highlight them.
+func (rr *responseRender) InvalidRequest(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrors protocol.ValidationErrors
+	var fieldError *protocol.FieldError
+	if errors.As(err, &fieldError) {
+		fieldErrors = protocol.ValidationErrors{*fieldError}
+	} else if !errors.As(err, &fieldErrors) || len(fieldErrors) == 0 {
+		rr.fail(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	errs := make([]protocol.Error, 0, len(fieldErrors))
+	for i := range fieldErrors {
+		fe := &fieldErrors[i]
+		errs = append(errs, protocol.Error{
+			Description: fe.Error(),
+			Code:        http.StatusBadRequest,
+			Reason:      fe.Reason,
+			Field:       fe.Field,
+			Rule:        fe.Rule,
+			Param:       fe.Param,
+		})
+	}
 	rr.render(w, r, &errResponse{
-		HTTPStatusCode: http.StatusInternalServerError,
+		HTTPStatusCode: http.StatusBadRequest,
+		ErrorResponse:  protocol.ErrorResponse{Errors: errs},
+	})
+}
+
+func (rr *responseRender) Unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	rr.fail(w, r, http.StatusUnauthorized, err.Error())
+}
+
+func (rr *responseRender) Forbidden(w http.ResponseWriter, r *http.Request, err error) {
+	rr.fail(w, r, http.StatusForbidden, err.Error())
+}
+
+// InsufficientScope is Forbidden caused by a credential lacking a scope. The reason follows RFC 6750.
+func (rr *responseRender) InsufficientScope(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="`+protocol.ReasonInsufficientScope+`"`)
+	rr.failWithReason(w, r, http.StatusForbidden, protocol.ReasonInsufficientScope, err.Error())
+}
+
+func (rr *responseRender) NotFound(w http.ResponseWriter, r *http.Request, err error) {
+	rr.fail(w, r, http.StatusNotFound, err.Error())
+}
+
+func (rr *responseRender) Conflict(w http.ResponseWriter, r *http.Request, err error) {
+	rr.fail(w, r, http.StatusConflict, err.Error())
+}
+
+// AlreadySubmitted is Conflict caused by a link submitted earlier. It refers to the existing post.
+func (rr *responseRender) AlreadySubmitted(w http.ResponseWriter, r *http.Request, err error, post protocol.PostRef) {
+	rr.render(w, r, &errResponse{
+		HTTPStatusCode: http.StatusConflict,
 		ErrorResponse: protocol.ErrorResponse{
 			Errors: []protocol.Error{
 				{
-					Code:        http.StatusInternalServerError,
-					Description: http.StatusText(http.StatusInternalServerError),
+					Code:        http.StatusConflict,
+					Description: err.Error(),
+					Reason:      protocol.ReasonAlreadySubmitted,
 				},
 			},
 		},
+		Data: &post,
 	})
 }
 
+func (rr *responseRender) TooManyRequests(w http.ResponseWriter, r *http.Request, err error) {
+	rr.failWithReason(w, r, http.StatusTooManyRequests, protocol.ReasonRateLimited, err.Error())
+}
+
+func (rr *responseRender) InternalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	rr.fail(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
 func NewRender() *responseRender {
 	return &responseRender{
 		r: render.Render,
@@ -3,6 +3,7 @@ package chi_utils
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -102,6 +103,195 @@ func TestResponseRender(t *testing.T) {
 			So(b, ShouldBeEmpty)
 		})
 
+		Convey("InvalidRequest with invalid fields", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusBadRequest,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusBadRequest,
+							Description: "title is required",
+							Reason:      protocol.ReasonRequired,
+							Field:       "title",
+							Rule:        "required",
+						},
+						{
+							Code:        http.StatusBadRequest,
+							Description: "the length of items.0.name should be at most 10",
+							Reason:      protocol.ReasonTooLong,
+							Field:       "items.0.name",
+							Rule:        "max",
+							Param:       "10",
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+
+			rr.InvalidRequest(w, r, fmt.Errorf("wrapped: %w", protocol.ValidationErrors{
+				{Field: "title", Rule: "required", Reason: protocol.ReasonRequired},
+				{Field: "items.0.name", Rule: "max", Param: "10", Reason: protocol.ReasonTooLong},
+			}))
+
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("InvalidRequest with an invalid field", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusBadRequest,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusBadRequest,
+							Description: "my error",
+							Reason:      protocol.ReasonInvalidValue,
+							Field:       "link",
+							Rule:        "public",
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+
+			rr.InvalidRequest(w, r, &protocol.FieldError{Field: "link", Rule: "public", Reason: protocol.ReasonInvalidValue, Description: "my error"})
+
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Unauthorized", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusUnauthorized,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusUnauthorized,
+							Description: "my error",
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.Unauthorized(w, r, errors.New("my error"))
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(w.Header().Get("WWW-Authenticate"), ShouldEqual, "Bearer")
+		})
+
+		Convey("Forbidden", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusForbidden,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusForbidden,
+							Description: "my error",
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.Forbidden(w, r, errors.New("my error"))
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("InsufficientScope", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusForbidden,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusForbidden,
+							Description: "my error",
+							Reason:      protocol.ReasonInsufficientScope,
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.InsufficientScope(w, r, errors.New("my error"))
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(w.Header().Get("WWW-Authenticate"), ShouldEqual, `Bearer error="insufficient_scope"`)
+		})
+
+		Convey("TooManyRequests", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusTooManyRequests,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusTooManyRequests,
+							Description: "my error",
+							Reason:      protocol.ReasonRateLimited,
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.TooManyRequests(w, r, errors.New("my error"))
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+		})
+
+		Convey("NotFound", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusNotFound,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusNotFound,
+							Description: "my error",
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.NotFound(w, r, errors.New("my error"))
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Conflict", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusConflict,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusConflict,
+							Description: "my error",
+						},
+					},
+				},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.Conflict(w, r, errors.New("my error"))
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("AlreadySubmitted", func() {
+			er := &errResponse{
+				HTTPStatusCode: http.StatusConflict,
+				ErrorResponse: protocol.ErrorResponse{
+					Errors: []protocol.Error{
+						{
+							Code:        http.StatusConflict,
+							Description: "my error",
+							Reason:      protocol.ReasonAlreadySubmitted,
+						},
+					},
+				},
+				Data: &protocol.PostRef{ID: "1a"},
+			}
+			m.On("Render", w, r, er).Return(nil).Run(func(args mock.Arguments) { w.WriteHeader(er.HTTPStatusCode) })
+			rr.AlreadySubmitted(w, r, errors.New("my error"), protocol.PostRef{ID: "1a"})
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Code, ShouldEqual, http.StatusConflict)
+		})
+
 		Convey("InternalServerError", func() {
 			er := &errResponse{
 				HTTPStatusCode: http.StatusInternalServerError,
@@ -0,0 +1,528 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/internal/validation"
+	"nanoreddit/pkg/protocol"
+)
+
+var errCampaignNotFound = errors.New("the campaign doesn't exist")
+
+// ownCampaign fetches a campaign referred by the URL and ensures the author of a request owns it. It renders
+// a response and returns nil otherwise.
+func (h *handler) ownCampaign(w http.ResponseWriter, r *http.Request) *protocol.Campaign {
+	ctx := r.Context()
+
+	owner := h.author(w, r, "")
+	if owner == "" {
+		return nil
+	}
+	campaign, err := h.storage.GetCampaign(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a campaign")
+		h.render.InternalServerError(w, r, err)
+		return nil
+	}
+	if campaign == nil {
+		h.render.NotFound(w, r, errCampaignNotFound)
+		return nil
+	}
+	if campaign.Owner != owner {
+		h.render.Forbidden(w, r, errors.New("only the owner of the campaign can manage it"))
+		return nil
+	}
+	return campaign
+}
+
+// advertiser ensures the author of a request is allowed to run campaigns: administrators and users they have made
+// advertisers are. It renders a response and returns an empty string otherwise.
+func (h *handler) advertiser(w http.ResponseWriter, r *http.Request) string {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return ""
+	}
+	for _, admin := range h.cfg.Admins {
+		if admin == user {
+			return user
+		}
+	}
+	ok, err := h.storage.IsAdvertiser(ctx, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't check an advertiser")
+		h.render.InternalServerError(w, r, err)
+		return ""
+	}
+	if ok {
+		return user
+	}
+	h.render.Forbidden(w, r, errors.New("only advertisers can run campaigns"))
+	return ""
+}
+
+// creative normalizes the link of a creative and ensures the creative is safe for work. It returns the domain
+// of the link. It renders a response and returns false if the creative is invalid.
+func (h *handler) creative(w http.ResponseWriter, r *http.Request, creative *protocol.Creative) (string, bool) {
+	var domain string
+	if creative.Link != "" {
+		link, err := validation.NormalizeLink(creative.Link)
+		if err != nil {
+			h.render.InvalidRequest(w, r, err)
+			return "", false
+		}
+		if err := h.domains.Check(link.Domain); err != nil {
+			h.render.InvalidRequest(w, r, err)
+			return "", false
+		}
+		creative.Link = link.URL
+		domain = link.Domain
+	}
+	if nsfw := h.nsfw(nil, creative.Title, creative.Content, domain); nsfw != nil {
+		h.render.InvalidRequest(w, r, fmt.Errorf("the creative should be safe for work, but it matches the %s %q", nsfw.Rule, nsfw.Match))
+		return "", false
+	}
+	return domain, true
+}
+
+// targeting checks that targeted subreddits exist and are safe for work, that excluded subreddits exist, and that
+// no subreddit is both targeted and excluded. It canonicalizes their names. It renders a response and returns false
+// if the targeting is invalid.
+func (h *handler) targeting(w http.ResponseWriter, r *http.Request, targeting *protocol.Targeting) bool {
+	targeted, ok := h.subreddits(w, r, targeting.Subreddits, false)
+	if !ok {
+		return false
+	}
+	excluded, ok := h.subreddits(w, r, targeting.ExcludedSubreddits, true)
+	if !ok {
+		return false
+	}
+	for _, name := range excluded {
+		for _, other := range targeted {
+			if name == other {
+				h.render.InvalidRequest(w, r, fmt.Errorf("the subreddit %s cannot be both targeted and excluded", name))
+				return false
+			}
+		}
+	}
+	targeting.Subreddits = targeted
+	targeting.ExcludedSubreddits = excluded
+	return true
+}
+
+// subreddits canonicalizes names of subreddits and drops duplicates. It renders a response and returns false
+// if a subreddit doesn't exist, or if it's NSFW and NSFW subreddits aren't allowed.
+func (h *handler) subreddits(w http.ResponseWriter, r *http.Request, names []string, nsfw bool) ([]string, bool) {
+	ctx := r.Context()
+
+	canonical := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+
+		subreddit, err := h.storage.GetSubreddit(ctx, name)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a subreddit")
+			h.render.InternalServerError(w, r, err)
+			return nil, false
+		}
+		if subreddit == nil {
+			h.render.InvalidRequest(w, r, fmt.Errorf("the subreddit %s doesn't exist", name))
+			return nil, false
+		}
+		if subreddit.NSFW && !nsfw {
+			h.render.InvalidRequest(w, r, fmt.Errorf("campaigns cannot target the NSFW subreddit %s", subreddit.Name))
+			return nil, false
+		}
+		canonical = append(canonical, subreddit.Name)
+	}
+	return canonical, true
+}
+
+// respondCampaign renders a campaign along with its current status.
+func (h *handler) respondCampaign(w http.ResponseWriter, r *http.Request, campaign *protocol.Campaign) {
+	campaign.Status = campaign.State(h.now().Unix())
+	render.Respond(w, r, &protocol.CampaignResponse{Data: *campaign})
+}
+
+// CreateCampaign starts a campaign promoting a post made of its creative. Creatives have to be safe for work,
+// since ads are never shown next to NSFW posts either.
+func (h *handler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.CampaignRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	owner := h.advertiser(w, r)
+	if owner == "" {
+		return
+	}
+
+	// These fields are maintained by the service, so clients aren't allowed to populate them.
+	now := h.now()
+	campaign := request.Campaign
+	campaign.ID = ""
+	campaign.Owner = owner
+	campaign.PostID = ""
+	campaign.Created = now.Unix()
+	campaign.Status = ""
+
+	if campaign.End <= campaign.Start {
+		h.render.InvalidRequest(w, r, protocol.ErrCampaignPeriod)
+		return
+	}
+	if campaign.End <= now.Unix() {
+		h.render.InvalidRequest(w, r, errors.New("the campaign should end in the future"))
+		return
+	}
+
+	domain, ok := h.creative(w, r, &campaign.Creative)
+	if !ok {
+		return
+	}
+	if !h.targeting(w, r, &campaign.Targeting) {
+		return
+	}
+
+	post := protocol.Post{
+		Title:    campaign.Creative.Title,
+		Author:   owner,
+		Link:     campaign.Creative.Link,
+		Domain:   domain,
+		Content:  campaign.Creative.Content,
+		Promoted: true,
+		Created:  campaign.Created,
+	}
+	// A promoted post belongs to the first targeted subreddit, if any.
+	if len(campaign.Targeting.Subreddits) != 0 {
+		post.Subreddit = campaign.Targeting.Subreddits[0]
+	}
+	if err := h.storage.AddCampaign(ctx, &campaign, &post); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't add a campaign")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.respondCampaign(w, r, &campaign)
+}
+
+// Campaigns lists campaigns of the author of a request, the newest first.
+func (h *handler) Campaigns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	owner := h.author(w, r, "")
+	if owner == "" {
+		return
+	}
+
+	campaigns, err := h.storage.GetCampaigns(ctx, owner)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch campaigns")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	now := h.now().Unix()
+	for i := range campaigns {
+		campaigns[i].Status = campaigns[i].State(now)
+	}
+	if campaigns == nil {
+		campaigns = []protocol.Campaign{}
+	}
+
+	render.Respond(w, r, campaigns)
+}
+
+func (h *handler) Campaign(w http.ResponseWriter, r *http.Request) {
+	campaign := h.ownCampaign(w, r)
+	if campaign == nil {
+		return
+	}
+
+	h.respondCampaign(w, r, campaign)
+}
+
+// EditCampaign changes a schedule, a budget or targeting of a campaign. The ads scheduler applies changes on
+// its next run.
+func (h *handler) EditCampaign(w http.ResponseWriter, r *http.Request) {
+	var request protocol.CampaignUpdate
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	campaign := h.ownCampaign(w, r)
+	if campaign == nil {
+		return
+	}
+
+	if request.Name != nil {
+		campaign.Name = *request.Name
+	}
+	if request.Start != nil {
+		campaign.Start = *request.Start
+	}
+	if request.End != nil {
+		if *request.End <= h.now().Unix() {
+			h.render.InvalidRequest(w, r, errors.New("the campaign should end in the future"))
+			return
+		}
+		campaign.End = *request.End
+	}
+	if campaign.End <= campaign.Start {
+		h.render.InvalidRequest(w, r, protocol.ErrCampaignPeriod)
+		return
+	}
+	if request.DailyBudget != nil {
+		campaign.DailyBudget = *request.DailyBudget
+	}
+	if request.Targeting != nil {
+		if !h.targeting(w, r, request.Targeting) {
+			return
+		}
+		campaign.Targeting = *request.Targeting
+	}
+
+	h.updateCampaign(w, r, campaign)
+}
+
+func (h *handler) PauseCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign := h.ownCampaign(w, r)
+	if campaign == nil {
+		return
+	}
+
+	campaign.Paused = true
+	h.updateCampaign(w, r, campaign)
+}
+
+func (h *handler) ResumeCampaign(w http.ResponseWriter, r *http.Request) {
+	campaign := h.ownCampaign(w, r)
+	if campaign == nil {
+		return
+	}
+
+	campaign.Paused = false
+	h.updateCampaign(w, r, campaign)
+}
+
+func (h *handler) updateCampaign(w http.ResponseWriter, r *http.Request, campaign *protocol.Campaign) {
+	ctx := r.Context()
+
+	campaign.Status = ""
+	if err := h.storage.UpdateCampaign(ctx, campaign); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't update a campaign")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.respondCampaign(w, r, campaign)
+}
+
+// maxStatsHours limits hourly breakdowns of stats to a month.
+const maxStatsHours = 31 * 24
+
+// CampaignStats reports impressions, clicks and unique viewers of a campaign overall and by hours within a range,
+// the last day of a campaign by default.
+func (h *handler) CampaignStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	campaign := h.ownCampaign(w, r)
+	if campaign == nil {
+		return
+	}
+
+	to := campaign.End - 1
+	if now := h.now().Unix(); now < to {
+		to = now
+	}
+	if toVal := r.FormValue("to"); toVal != "" {
+		v, err := strconv.ParseInt(toVal, 10, 64)
+		if err != nil {
+			h.render.InvalidRequest(w, r, fmt.Errorf("couldn't recognize the end of the range: %w", err))
+			return
+		}
+		to = v
+	}
+	from := to - 24*3600 + 1
+	if campaign.Start > from {
+		from = campaign.Start
+	}
+	if fromVal := r.FormValue("from"); fromVal != "" {
+		v, err := strconv.ParseInt(fromVal, 10, 64)
+		if err != nil {
+			h.render.InvalidRequest(w, r, fmt.Errorf("couldn't recognize the start of the range: %w", err))
+			return
+		}
+		from = v
+	}
+
+	var hours []int64
+	for hour := from - from%3600; hour <= to; hour += 3600 {
+		if len(hours) == maxStatsHours {
+			h.render.InvalidRequest(w, r, fmt.Errorf("the range should be at most %d hours", maxStatsHours))
+			return
+		}
+		hours = append(hours, hour)
+	}
+
+	stats, err := h.storage.GetCampaignStats(ctx, campaign.ID, hours)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch stats of a campaign")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.CampaignStatsResponse{Data: *stats})
+}
+
+// ClickPost redirects to the link of a post. A click on a promoted post of a campaign is counted if it refers to
+// the feed response which has shown the post to the viewer, once per viewer and campaign within an hour.
+func (h *handler) ClickPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	post := h.livePost(w, r)
+	if post == nil {
+		return
+	}
+	if post.Link == "" {
+		h.render.NotFound(w, r, errors.New("the post has no link"))
+		return
+	}
+
+	if post.Campaign != "" {
+		click := protocol.AdClicked{
+			Campaign:  post.Campaign,
+			PostID:    post.ID,
+			Viewer:    middleware.Viewer(r),
+			RequestID: r.FormValue("request_id"),
+			Created:   h.now().Unix(),
+		}
+		// A viewer follows a link anyway.
+		counted, err := h.storage.ClickAd(ctx, &click)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a click")
+		} else if !counted {
+			zerolog.Ctx(ctx).Debug().Str("campaign", click.Campaign).Msg("A click isn't counted")
+		}
+	}
+
+	http.Redirect(w, r, post.Link, http.StatusFound)
+}
+
+// Advertisers lists users allowed to run campaigns besides administrators.
+func (h *handler) Advertisers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.admin(w, r) == "" {
+		return
+	}
+
+	advertisers, err := h.storage.GetAdvertisers(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch advertisers")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if advertisers == nil {
+		advertisers = []string{}
+	}
+
+	render.Respond(w, r, &protocol.AdvertisersResponse{Data: advertisers})
+}
+
+// AddAdvertiser allows a user to run campaigns.
+func (h *handler) AddAdvertiser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.AdvertiserRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	if h.admin(w, r) == "" {
+		return
+	}
+
+	user, err := h.storage.GetUser(ctx, request.User)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a user")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if user == nil {
+		h.render.NotFound(w, r, errUserNotFound)
+		return
+	}
+	if err := h.storage.AddAdvertiser(ctx, user.ID); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't add an advertiser")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+// RemoveAdvertiser keeps a user from starting campaigns, running ones aren't stopped.
+func (h *handler) RemoveAdvertiser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.admin(w, r) == "" {
+		return
+	}
+
+	if err := h.storage.RemoveAdvertiser(ctx, chi.URLParam(r, "user")); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't remove an advertiser")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+// CreateHouseAd promotes a post of the site itself made of a creative. House ads take slots which no campaign may
+// take, and they're deleted like ordinary posts.
+func (h *handler) CreateHouseAd(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.HouseAdRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	admin := h.admin(w, r)
+	if admin == "" {
+		return
+	}
+	domain, ok := h.creative(w, r, &request.Creative)
+	if !ok {
+		return
+	}
+
+	post := protocol.Post{
+		Title:    request.Title,
+		Author:   admin,
+		Link:     request.Link,
+		Domain:   domain,
+		Content:  request.Content,
+		Promoted: true,
+		HouseAd:  true,
+		Created:  h.now().Unix(),
+	}
+	if err := h.storage.AddPost(ctx, &post); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a house ad")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
+}
@@ -0,0 +1,489 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestCreateCampaign(t *testing.T) {
+	Convey("Test CreateCampaign", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/ads/campaigns", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(req, "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("IsAdvertiser", mock.Anything, "t2_abcdefg2").Return(true, nil).Maybe()
+
+		Convey("It fails if the author isn't an advertiser", func() {
+			m.
+				On("IsAdvertiser", mock.Anything, "t2_abcdefg3").Return(false, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/ads/campaigns", bytes.NewBufferString(`{"name":"launch","creative":{"title":"Try Go"},"start":1612000000,"end":1612100000,"daily_budget":1000}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.CreateCampaign(w, withUser(req, "t2_abcdefg3"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"only advertisers can run campaigns"}]}`)
+		})
+
+		Convey("It fails if a campaign ends before it starts", func() {
+			handler.CreateCampaign(w, newRequest(`{"name":"launch","creative":{"title":"Try Go"},"start":1612010000,"end":1612000000,"daily_budget":1000}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":400,"description":"end fails the rule gtfield=start","reason":"invalid_value","field":"end","rule":"gtfield","param":"start"}]}`)
+		})
+
+		Convey("It fails if a creative is NSFW", func() {
+			handler.CreateCampaign(w, newRequest(`{"name":"launch","creative":{"title":"NSFW deals"},"start":1612000000,"end":1612100000,"daily_budget":1000}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a campaign targets an NSFW subreddit", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "gonewild").Return(&protocol.Subreddit{Name: "GoneWild", NSFW: true}, nil)
+
+			handler.CreateCampaign(w, newRequest(`{"name":"launch","creative":{"title":"Try Go"},"start":1612000000,"end":1612100000,"daily_budget":1000,"targeting":{"subreddits":["gonewild"]}}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a subreddit is both targeted and excluded", func() {
+			m.
+				On("GetSubreddit", mock.Anything, mock.Anything).Return(&protocol.Subreddit{Name: "GoLang"}, nil)
+
+			handler.CreateCampaign(w, newRequest(`{"name":"launch","creative":{"title":"Try Go"},"start":1612000000,"end":1612100000,"daily_budget":1000,"targeting":{"subreddits":["golang"],"excluded_subreddits":["GoLang"]}}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			campaign := &protocol.Campaign{
+				Owner:       "t2_abcdefg2",
+				Name:        "launch",
+				Creative:    protocol.Creative{Title: "Try Go", Link: "https://golang.org/"},
+				Start:       1612000000,
+				End:         1612100000,
+				DailyBudget: 1000,
+				Targeting:   protocol.Targeting{Subreddits: []string{"GoLang"}, ExcludedSubreddits: []string{"Games"}},
+				Created:     mockNow.Unix(),
+			}
+			post := &protocol.Post{
+				Title:     "Try Go",
+				Author:    "t2_abcdefg2",
+				Link:      "https://golang.org/",
+				Domain:    "golang.org",
+				Subreddit: "GoLang",
+				Promoted:  true,
+				Created:   mockNow.Unix(),
+			}
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil).
+				On("GetSubreddit", mock.Anything, "games").Return(&protocol.Subreddit{Name: "Games"}, nil).
+				On("AddCampaign", mock.Anything, campaign, post).Return(nil).Run(func(args mock.Arguments) {
+				args.Get(1).(*protocol.Campaign).ID = "1a"
+				args.Get(1).(*protocol.Campaign).PostID = "1b"
+			})
+
+			handler.CreateCampaign(w, newRequest(`{"name":"launch","creative":{"title":"Try Go","link":"HTTPS://GoLang.org"},"start":1612000000,"end":1612100000,"daily_budget":1000,"targeting":{"subreddits":["golang","GOLANG"],"excluded_subreddits":["games"]},"post_id":"1"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a","owner":"t2_abcdefg2","name":"launch","creative":{"title":"Try Go","link":"https://golang.org/"},"start":1612000000,"end":1612100000,"daily_budget":1000,"targeting":{"subreddits":["GoLang"],"excluded_subreddits":["Games"]},"paused":false,"post_id":"1b","created":1612008000,"status":"active"}}`)
+		})
+	})
+}
+
+func TestCreateHouseAd(t *testing.T) {
+	Convey("Test CreateHouseAd", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(user, body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/admin/house_ads", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(req, user)
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("Only administrators create house ads", func() {
+			handler.CreateHouseAd(w, newRequest("t2_abcdefg2", `{"title":"Try Go"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a creative is NSFW", func() {
+			handler.CreateHouseAd(w, newRequest("t2_abcdefg1", `{"title":"NSFW deals"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			post := &protocol.Post{
+				Title:    "Try Go",
+				Author:   "t2_abcdefg1",
+				Link:     "https://golang.org/",
+				Domain:   "golang.org",
+				Promoted: true,
+				HouseAd:  true,
+				Created:  mockNow.Unix(),
+			}
+			m.
+				On("AddPost", mock.Anything, post).Return(nil).Run(func(args mock.Arguments) {
+				args.Get(1).(*protocol.Post).ID = "1a"
+			})
+
+			handler.CreateHouseAd(w, newRequest("t2_abcdefg1", `{"title":"Try Go","link":"HTTPS://GoLang.org"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a"}}`)
+		})
+	})
+}
+
+func TestManageCampaign(t *testing.T) {
+	Convey("Test managing a campaign", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(user, body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPatch, "/ads/campaigns/1a", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), user)
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("GetCampaign", mock.Anything, "1a").Return(&protocol.Campaign{
+			ID:          "1a",
+			Owner:       "t2_abcdefg2",
+			Name:        "launch",
+			Creative:    protocol.Creative{Title: "Try Go"},
+			Start:       1612000000,
+			End:         1612100000,
+			DailyBudget: 1000,
+			PostID:      "1b",
+			Created:     1612000000,
+		}, nil)
+
+		Convey("It fails if a user doesn't own a campaign", func() {
+			handler.PauseCampaign(w, newRequest("t2_abcdefg3", ""))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a campaign would end before it starts", func() {
+			handler.EditCampaign(w, newRequest("t2_abcdefg2", `{"start":1612200000}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An owner changes a budget", func() {
+			m.
+				On("UpdateCampaign", mock.Anything, mock.MatchedBy(func(c *protocol.Campaign) bool {
+					return c.DailyBudget == 50 && !c.Paused
+				})).Return(nil)
+
+			handler.EditCampaign(w, newRequest("t2_abcdefg2", `{"daily_budget":50}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An owner pauses a campaign", func() {
+			m.
+				On("UpdateCampaign", mock.Anything, mock.MatchedBy(func(c *protocol.Campaign) bool {
+					return c.Paused
+				})).Return(nil)
+
+			handler.PauseCampaign(w, newRequest("t2_abcdefg2", ""))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a","owner":"t2_abcdefg2","name":"launch","creative":{"title":"Try Go"},"start":1612000000,"end":1612100000,"daily_budget":1000,"targeting":{},"paused":true,"post_id":"1b","created":1612000000,"status":"paused"}}`)
+		})
+	})
+}
+
+func TestCampaignStats(t *testing.T) {
+	Convey("Test CampaignStats", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(query string) *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/ads/campaigns/1a/stats"+query, nil)
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("GetCampaign", mock.Anything, "1a").Return(&protocol.Campaign{
+			ID:          "1a",
+			Owner:       "t2_abcdefg2",
+			Start:       1612000000,
+			End:         1612100000,
+			DailyBudget: 1000,
+			PostID:      "1b",
+		}, nil)
+
+		Convey("It fails if a range is too long", func() {
+			handler.CampaignStats(w, newRequest("?from=1600000000"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a range cannot be recognized", func() {
+			handler.CampaignStats(w, newRequest("?to=yesterday"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			// The campaign started less than a day ago, so the range starts with it.
+			hours := []int64{1611997200, 1612000800, 1612004400, 1612008000}
+			m.
+				On("GetCampaignStats", mock.Anything, "1a", hours).Return(&protocol.CampaignStats{
+				Campaign: "1a",
+				AdStats:  protocol.AdStats{Impressions: 30, Clicks: 2, UniqueViewers: 12},
+				Hourly: []protocol.HourlyAdStats{
+					{Hour: 1611997200},
+					{Hour: 1612000800, AdStats: protocol.AdStats{Impressions: 10, UniqueViewers: 4}},
+					{Hour: 1612004400, AdStats: protocol.AdStats{Impressions: 20, Clicks: 2, UniqueViewers: 9}},
+					{Hour: 1612008000},
+				},
+			}, nil)
+
+			handler.CampaignStats(w, newRequest(""))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
+
+func TestClickPost(t *testing.T) {
+	Convey("Test ClickPost", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := withURLParams(httptest.NewRequest(http.MethodGet, "/posts/1b/click?request_id=abc", nil), map[string]string{"id": "1b"})
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a post has no link", func() {
+			m.
+				On("GetPost", mock.Anything, "1b").Return(&protocol.Post{ID: "1b", Content: "text"}, nil)
+
+			handler.ClickPost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A click on an organic post isn't counted", func() {
+			m.
+				On("GetPost", mock.Anything, "1b").Return(&protocol.Post{ID: "1b", Link: "https://golang.org/"}, nil)
+
+			handler.ClickPost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusFound)
+			So(resp.Header.Get("Location"), ShouldEqual, "https://golang.org/")
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A click on a promoted post is counted", func() {
+			m.
+				On("GetPost", mock.Anything, "1b").Return(&protocol.Post{ID: "1b", Link: "https://golang.org/", Promoted: true, Campaign: "1a"}, nil).
+				On("ClickAd", mock.Anything, &protocol.AdClicked{
+					Campaign:  "1a",
+					PostID:    "1b",
+					Viewer:    "ip:192.0.2.1",
+					RequestID: "abc",
+					Created:   mockNow.Unix(),
+				}).Return(true, nil)
+
+			handler.ClickPost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusFound)
+			So(resp.Header.Get("Location"), ShouldEqual, "https://golang.org/")
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A viewer follows a link even if a click isn't counted", func() {
+			m.
+				On("GetPost", mock.Anything, "1b").Return(&protocol.Post{ID: "1b", Link: "https://golang.org/", Promoted: true, Campaign: "1a"}, nil).
+				On("ClickAd", mock.Anything, mock.Anything).Return(false, nil)
+
+			handler.ClickPost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusFound)
+			So(resp.Header.Get("Location"), ShouldEqual, "https://golang.org/")
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
+
+func TestAdvertisers(t *testing.T) {
+	Convey("Test advertisers", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		newRequest := func(method, target, body, user string) *http.Request {
+			req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(req, user)
+		}
+
+		Convey("Only administrators manage advertisers", func() {
+			handler.AddAdvertiser(w, newRequest(http.MethodPost, "/admin/advertisers", `{"user":"t2_abcdefg3"}`, "t2_abcdefg2"))
+
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a user doesn't exist", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg3").Return((*protocol.User)(nil), nil)
+
+			handler.AddAdvertiser(w, newRequest(http.MethodPost, "/admin/advertisers", `{"user":"t2_abcdefg3"}`, "t2_abcdefg1"))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An administrator adds an advertiser", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg3").Return(&protocol.User{ID: "t2_abcdefg3"}, nil).
+				On("AddAdvertiser", mock.Anything, "t2_abcdefg3").Return(nil)
+
+			handler.AddAdvertiser(w, newRequest(http.MethodPost, "/admin/advertisers", `{"user":"t2_abcdefg3"}`, "t2_abcdefg1"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An administrator lists advertisers", func() {
+			m.
+				On("GetAdvertisers", mock.Anything).Return([]string{"t2_abcdefg3"}, nil)
+
+			handler.Advertisers(w, newRequest(http.MethodGet, "/admin/advertisers", "", "t2_abcdefg1"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"data":["t2_abcdefg3"]}`)
+		})
+
+		Convey("An administrator removes an advertiser", func() {
+			m.
+				On("RemoveAdvertiser", mock.Anything, "t2_abcdefg3").Return(nil)
+
+			req := newRequest(http.MethodDelete, "/admin/advertisers/t2_abcdefg3", "", "t2_abcdefg1")
+			handler.RemoveAdvertiser(w, withURLParams(req, map[string]string{"user": "t2_abcdefg3"}))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
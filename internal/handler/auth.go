@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+// author resolves an author of a request by the credential. A request may claim an author explicitly,
+// but the claim must match the credential. It renders a response and returns an empty string on a failure.
+func (h *handler) author(w http.ResponseWriter, r *http.Request, claimed string) string {
+	user := middleware.User(r.Context())
+	if user == "" {
+		h.render.Unauthorized(w, r, errors.New("authentication is required"))
+		return ""
+	}
+	if claimed != "" && claimed != user {
+		h.render.Forbidden(w, r, errors.New("the author doesn't match the credential"))
+		return ""
+	}
+	return user
+}
+
+func (h *handler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+
+	token, expires := h.sessions.Issue(user)
+
+	render.Respond(w, r, &protocol.SessionResponse{Data: protocol.Session{Token: token, Expires: expires}})
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateSession(t *testing.T) {
+	Convey("Test CreateSession", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/sessions", nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a request is anonymous", func() {
+			handler.CreateSession(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("Issue", "t2_abcdefg2").Return("payload.signature", mockNow.Unix()+3600)
+
+			handler.CreateSession(w, withUser(req, "t2_abcdefg2"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"data":{"token":"payload.signature","expires":1612011600}}`)
+		})
+	})
+}
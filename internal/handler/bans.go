@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+var errBanNotFound = errors.New("the user isn't banned")
+
+// admin ensures the author of a request is an administrator of the site. It renders a response and returns
+// an empty string if the author isn't an administrator.
+func (h *handler) admin(w http.ResponseWriter, r *http.Request) string {
+	user := h.author(w, r, "")
+	if user == "" {
+		return ""
+	}
+	for _, admin := range h.cfg.Admins {
+		if admin == user {
+			return user
+		}
+	}
+	h.render.Forbidden(w, r, errors.New("only administrators can do this"))
+	return ""
+}
+
+// activeBan returns a ban keeping a user from posting to a subreddit, a site-wide ban goes first. It returns nil
+// if the user isn't banned. It renders a response and returns false if the lookup fails.
+func (h *handler) activeBan(w http.ResponseWriter, r *http.Request, subreddit, user string) (*protocol.Ban, bool) {
+	ctx := r.Context()
+
+	for _, name := range []string{"", subreddit} {
+		ban, err := h.storage.GetBan(ctx, name, user)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a ban")
+			h.render.InternalServerError(w, r, err)
+			return nil, false
+		}
+		if ban != nil && ban.Active(h.now().Unix()) {
+			return ban, true
+		}
+	}
+	return nil, true
+}
+
+// banError explains a ban to the banned user.
+func banError(ban *protocol.Ban) error {
+	message := "the author is banned"
+	if ban.Subreddit != "" {
+		message += " from r/" + ban.Subreddit
+	}
+	if ban.Expires != 0 {
+		message += " until " + time.Unix(ban.Expires, 0).UTC().Format(time.RFC3339)
+	}
+	if ban.Reason != "" {
+		message += ": " + ban.Reason
+	}
+	return errors.New(message)
+}
+
+// listBans responds with bans of a subreddit or site-wide ones which are still in effect.
+func (h *handler) listBans(w http.ResponseWriter, r *http.Request, subreddit string) {
+	ctx := r.Context()
+
+	bans, err := h.storage.GetBans(ctx, subreddit)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch bans")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	active := make([]protocol.Ban, 0, len(bans))
+	for _, ban := range bans {
+		if ban.Active(h.now().Unix()) {
+			active = append(active, ban)
+		}
+	}
+
+	render.Respond(w, r, active)
+}
+
+// ban bans a user from a subreddit or site-wide. Bans from subreddits are written to the moderation log.
+func (h *handler) ban(w http.ResponseWriter, r *http.Request, request *protocol.BanRequest, subreddit, by string) {
+	ctx := r.Context()
+
+	user, err := h.storage.GetUser(ctx, request.User)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a user")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if user == nil {
+		h.render.NotFound(w, r, errUserNotFound)
+		return
+	}
+
+	now := h.now()
+	ban := protocol.Ban{
+		User:      user.ID,
+		Subreddit: subreddit,
+		Reason:    request.Reason,
+		Shadow:    request.Shadow,
+		By:        by,
+		Created:   now.Unix(),
+	}
+	if request.Duration != 0 {
+		ban.Expires = now.Add(time.Duration(request.Duration) * time.Second).Unix()
+	}
+	var action *protocol.ModAction
+	if subreddit != "" {
+		action = &protocol.ModAction{
+			Subreddit: subreddit,
+			Moderator: by,
+			Action:    protocol.ModBanUser,
+			Target:    user.ID,
+			Reason:    request.Reason,
+			Created:   now.Unix(),
+		}
+	}
+	if err := h.storage.BanUser(ctx, &ban, action); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't ban a user")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.BanResponse{Data: ban})
+}
+
+// unban lifts a ban of the user referred by the URL.
+func (h *handler) unban(w http.ResponseWriter, r *http.Request, subreddit, by string) {
+	ctx := r.Context()
+
+	user := chi.URLParam(r, "user")
+	ban, err := h.storage.GetBan(ctx, subreddit, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a ban")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if ban == nil {
+		h.render.NotFound(w, r, errBanNotFound)
+		return
+	}
+
+	var action *protocol.ModAction
+	if subreddit != "" {
+		action = &protocol.ModAction{
+			Subreddit: subreddit,
+			Moderator: by,
+			Action:    protocol.ModUnbanUser,
+			Target:    user,
+			Created:   h.now().Unix(),
+		}
+	}
+	if err := h.storage.UnbanUser(ctx, subreddit, user, action); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't unban a user")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+func (h *handler) SubredditBans(w http.ResponseWriter, r *http.Request) {
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	if h.moderator(w, r, subreddit) == "" {
+		return
+	}
+
+	h.listBans(w, r, subreddit.Name)
+}
+
+func (h *handler) BanFromSubreddit(w http.ResponseWriter, r *http.Request) {
+	var request protocol.BanRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	moderator := h.moderator(w, r, subreddit)
+	if moderator == "" {
+		return
+	}
+	if request.User == subreddit.Creator {
+		h.render.Forbidden(w, r, errors.New("the creator of the subreddit cannot be banned"))
+		return
+	}
+
+	h.ban(w, r, &request, subreddit.Name, moderator)
+}
+
+func (h *handler) UnbanFromSubreddit(w http.ResponseWriter, r *http.Request) {
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	moderator := h.moderator(w, r, subreddit)
+	if moderator == "" {
+		return
+	}
+
+	h.unban(w, r, subreddit.Name, moderator)
+}
+
+// Bans lists site-wide bans.
+func (h *handler) Bans(w http.ResponseWriter, r *http.Request) {
+	if h.admin(w, r) == "" {
+		return
+	}
+
+	h.listBans(w, r, "")
+}
+
+// BanUser bans a user site-wide.
+func (h *handler) BanUser(w http.ResponseWriter, r *http.Request) {
+	var request protocol.BanRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	admin := h.admin(w, r)
+	if admin == "" {
+		return
+	}
+	if request.User == admin {
+		h.render.InvalidRequest(w, r, errors.New("administrators cannot ban themselves"))
+		return
+	}
+
+	h.ban(w, r, &request, "", admin)
+}
+
+func (h *handler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	admin := h.admin(w, r)
+	if admin == "" {
+		return
+	}
+
+	h.unban(w, r, "", admin)
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestSubmitBans(t *testing.T) {
+	Convey("Test bans on submission", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{"title":"title","subreddit":"golang"}`))
+		req.Header.Add("Content-Type", "application/json")
+		req = withUser(req, "t2_abcdefg2")
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil)
+
+		Convey("A banned author gets an explanation", func() {
+			ban := &protocol.Ban{User: "t2_abcdefg2", Subreddit: "GoLang", Reason: "trolling", Expires: mockNow.Unix() + 3600}
+			m.
+				On("GetBan", mock.Anything, "", "t2_abcdefg2").Return((*protocol.Ban)(nil), nil).
+				On("GetBan", mock.Anything, "GoLang", "t2_abcdefg2").Return(ban, nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"the author is banned from r/GoLang until 2021-01-30T13:00:00Z: trolling"}]}`)
+		})
+
+		Convey("An expired ban doesn't matter", func() {
+			m.
+				On("GetBan", mock.Anything, "", "t2_abcdefg2").Return(&protocol.Ban{User: "t2_abcdefg2", Expires: mockNow.Unix()}, nil).
+				On("GetBan", mock.Anything, "GoLang", "t2_abcdefg2").Return((*protocol.Ban)(nil), nil).
+				On("Score", mock.Anything, mock.Anything).Return(&protocol.SpamDecision{Outcome: protocol.SpamAccept}, nil).
+				On("AddPost", mock.Anything, mock.Anything).Return(nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A shadowbanned author sees success", func() {
+			m.
+				On("GetBan", mock.Anything, "", "t2_abcdefg2").Return(&protocol.Ban{User: "t2_abcdefg2", Shadow: true}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(&protocol.SpamDecision{Outcome: protocol.SpamAccept}, nil).
+				On("AddPost", mock.Anything, mock.Anything).Return(nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
+
+func TestBanUser(t *testing.T) {
+	Convey("Test managing bans", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		newRequest := func(user, body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/admin/bans", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"subreddit": "golang"}), user)
+		}
+
+		Convey("Only administrators ban users site-wide", func() {
+			handler.BanUser(w, newRequest("t2_abcdefg2", `{"user":"t2_abcdefg3"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An administrator shadowbans a user", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg3").Return(&protocol.User{ID: "t2_abcdefg3"}, nil).
+				On("BanUser", mock.Anything, &protocol.Ban{
+					User:    "t2_abcdefg3",
+					Shadow:  true,
+					By:      "t2_abcdefg1",
+					Created: mockNow.Unix(),
+				}, (*protocol.ModAction)(nil)).Return(nil)
+
+			handler.BanUser(w, newRequest("t2_abcdefg1", `{"user":"t2_abcdefg3","shadow":true}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A moderator bans a user from a subreddit for a while", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang", Creator: "t2_abcdefg2"}, nil).
+				On("GetUser", mock.Anything, "t2_abcdefg3").Return(&protocol.User{ID: "t2_abcdefg3"}, nil).
+				On("BanUser", mock.Anything, &protocol.Ban{
+					User:      "t2_abcdefg3",
+					Subreddit: "GoLang",
+					Reason:    "trolling",
+					By:        "t2_abcdefg2",
+					Created:   mockNow.Unix(),
+					Expires:   mockNow.Unix() + 86400,
+				}, &protocol.ModAction{
+					Subreddit: "GoLang",
+					Moderator: "t2_abcdefg2",
+					Action:    protocol.ModBanUser,
+					Target:    "t2_abcdefg3",
+					Reason:    "trolling",
+					Created:   mockNow.Unix(),
+				}).Return(nil)
+
+			handler.BanFromSubreddit(w, newRequest("t2_abcdefg2", `{"user":"t2_abcdefg3","duration":86400,"reason":"trolling"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails to lift a missing ban", func() {
+			m.
+				On("GetBan", mock.Anything, "", "t2_abcdefg3").Return((*protocol.Ban)(nil), nil)
+
+			req := httptest.NewRequest(http.MethodDelete, "/admin/bans/t2_abcdefg3", nil)
+			handler.UnbanUser(w, withUser(withURLParams(req, map[string]string{"user": "t2_abcdefg3"}), "t2_abcdefg1"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
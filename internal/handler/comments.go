@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+var errCommentNotFound = errors.New("the comment is not found")
+
+// intParam parses an optional positive query parameter bounded by the maximum.
+func intParam(r *http.Request, name string, max int) (int, error) {
+	val := r.FormValue(name)
+	if val == "" {
+		return max, nil
+	}
+	v, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't recognize the %s: %w", name, err)
+	}
+	if v < 1 || v > max {
+		return 0, fmt.Errorf("the %s should be between 1 and %d", name, max)
+	}
+	return v, nil
+}
+
+func (h *handler) Comments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := protocol.CommentsQuery{
+		PostID: chi.URLParam(r, "id"),
+		Sort:   protocol.CommentSortBest,
+		Budget: h.cfg.CommentsBudget,
+	}
+	if sort := r.FormValue("sort"); sort != "" {
+		switch sort {
+		case protocol.CommentSortBest, protocol.CommentSortTop, protocol.CommentSortNew:
+			query.Sort = sort
+		default:
+			h.render.InvalidRequest(w, r, fmt.Errorf("unknown sort: %q", sort))
+			return
+		}
+	}
+	var err error
+	if query.Limit, err = intParam(r, "limit", h.cfg.CommentsLimit); err != nil {
+		h.render.InvalidRequest(w, r, err)
+		return
+	}
+	if query.Depth, err = intParam(r, "depth", h.cfg.CommentsDepth); err != nil {
+		h.render.InvalidRequest(w, r, err)
+		return
+	}
+	if token := r.FormValue("more"); token != "" {
+		if query.ParentID, query.Offset, err = protocol.DecodeCommentsToken(token); err != nil {
+			h.render.InvalidRequest(w, r, err)
+			return
+		}
+	}
+
+	post, err := h.storage.GetPost(ctx, query.PostID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if post == nil {
+		h.render.NotFound(w, r, errPostNotFound)
+		return
+	}
+
+	// A token continues replies to the post or to one of its comments only.
+	if query.ParentID != "" && query.ParentID != post.ID {
+		parent, err := h.storage.GetComment(ctx, query.ParentID)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a comment")
+			h.render.InternalServerError(w, r, err)
+			return
+		}
+		if parent == nil || parent.PostID != post.ID {
+			h.render.InvalidRequest(w, r, errors.New("the token doesn't belong to the post"))
+			return
+		}
+	}
+
+	comments, more, err := h.storage.GetComments(ctx, &query)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch comments")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if comments == nil {
+		comments = []protocol.Comment{}
+	}
+
+	render.Respond(w, r, &protocol.CommentsResponse{Comments: comments, More: more})
+}
+
+func (h *handler) AddComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.CommentRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	author := h.author(w, r, request.Author)
+	if author == "" {
+		return
+	}
+
+	post, err := h.storage.GetPost(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if post == nil || post.Deleted {
+		h.render.NotFound(w, r, errPostNotFound)
+		return
+	}
+	if request.ParentID != "" {
+		parent, err := h.storage.GetComment(ctx, request.ParentID)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a comment")
+			h.render.InternalServerError(w, r, err)
+			return
+		}
+		if parent == nil || parent.PostID != post.ID {
+			h.render.InvalidRequest(w, r, errors.New("the parent comment doesn't belong to the post"))
+			return
+		}
+	}
+
+	comment := protocol.Comment{
+		PostID:   post.ID,
+		ParentID: request.ParentID,
+		Author:   author,
+		Body:     request.Body,
+		Created:  h.now().Unix(),
+	}
+	if err := h.storage.AddComment(ctx, &comment); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a comment")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.CommentResponse{Data: protocol.PostRef{ID: comment.ID}})
+}
+
+func (h *handler) VoteComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.VoteRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	voter := h.author(w, r, request.Author)
+	if voter == "" {
+		return
+	}
+
+	comment, err := h.storage.GetComment(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a comment")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if comment == nil {
+		h.render.NotFound(w, r, errCommentNotFound)
+		return
+	}
+
+	vote := protocol.CommentVoted{
+		CommentID: comment.ID,
+		Voter:     voter,
+		Direction: *request.Direction,
+	}
+	if err := h.storage.VoteComment(ctx, &vote); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a vote")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.CommentResponse{Data: protocol.PostRef{ID: comment.ID}})
+}
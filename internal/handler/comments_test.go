@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestComments(t *testing.T) {
+	Convey("Test Comments", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(url string) *http.Request {
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			return withURLParams(req, map[string]string{"id": "1a"})
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a sort is unknown", func() {
+			handler.Comments(w, newRequest("/posts/1a/comments?sort=hot"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":400,"description":"unknown sort: \"hot\""}]}`)
+		})
+
+		Convey("It fails if a limit exceeds the maximum", func() {
+			handler.Comments(w, newRequest("/posts/1a/comments?limit=51"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":400,"description":"the limit should be between 1 and 50"}]}`)
+		})
+
+		Convey("It fails if a token is malformed", func() {
+			handler.Comments(w, newRequest("/posts/1a/comments?more=abc"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("It fails if a post doesn't exist", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return((*protocol.Post)(nil), nil)
+
+			handler.Comments(w, newRequest("/posts/1a/comments"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("GetComments", mock.Anything, mock.Anything).Return([]protocol.Comment(nil), (*protocol.MoreComments)(nil), errors.New("storage error"))
+
+			handler.Comments(w, newRequest("/posts/1a/comments"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Defaults are used unless parameters are specified", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("GetComments", mock.Anything, &protocol.CommentsQuery{
+					PostID: "1a",
+					Sort:   protocol.CommentSortBest,
+					Limit:  50,
+					Depth:  8,
+					Budget: 200,
+				}).Return([]protocol.Comment(nil), (*protocol.MoreComments)(nil), nil)
+
+			handler.Comments(w, newRequest("/posts/1a/comments"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"comments":[]}`)
+		})
+
+		Convey("It fails if a token continues another post", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("GetComment", mock.Anything, "2b").Return(&protocol.Comment{ID: "2b", PostID: "1b"}, nil)
+
+			handler.Comments(w, newRequest("/posts/1a/comments?more="+protocol.EncodeCommentsToken("2b", 10)))
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"errors":[{"code":400,"description":"the token doesn't belong to the post"}]}`)
+		})
+
+		Convey("Successful story (a continuation)", func() {
+			token := protocol.EncodeCommentsToken("2b", 10)
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("GetComment", mock.Anything, "2b").Return(&protocol.Comment{ID: "2b", PostID: "1a"}, nil).
+				On("GetComments", mock.Anything, &protocol.CommentsQuery{
+					PostID:   "1a",
+					ParentID: "2b",
+					Sort:     protocol.CommentSortNew,
+					Offset:   10,
+					Limit:    2,
+					Depth:    1,
+					Budget:   200,
+				}).Return(
+				[]protocol.Comment{
+					{ID: "3c", PostID: "1a", ParentID: "2b", Author: "t2_abcdefg2", Body: "first", NumReplies: 4, More: &protocol.MoreComments{Count: 4, Token: "t1"}},
+					{ID: "3d", PostID: "1a", ParentID: "2b", Author: "t2_abcdefg2", Body: "second"},
+				},
+				&protocol.MoreComments{Count: 5, Token: "t2"},
+				nil,
+			)
+
+			handler.Comments(w, newRequest("/posts/1a/comments?sort=new&limit=2&depth=1&more="+token))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{
+				"comments": [
+					{"id":"3c","post_id":"1a","parent_id":"2b","author":"t2_abcdefg2","body":"first","score":0,"ups":0,"downs":0,"num_replies":4,"more":{"count":4,"token":"t1"}},
+					{"id":"3d","post_id":"1a","parent_id":"2b","author":"t2_abcdefg2","body":"second","score":0,"ups":0,"downs":0,"num_replies":0}
+				],
+				"more": {"count":5,"token":"t2"}
+			}`)
+		})
+	})
+}
+
+func TestAddComment(t *testing.T) {
+	Convey("Test AddComment", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/posts/1a/comments", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a body is empty", func() {
+			handler.AddComment(w, newRequest(`{"author":"t2_abcdefg2"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post is deleted", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Deleted: true}, nil)
+
+			handler.AddComment(w, newRequest(`{"author":"t2_abcdefg2","body":"hello"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a parent comment belongs to another post", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("GetComment", mock.Anything, "2b").Return(&protocol.Comment{ID: "2b", PostID: "1b"}, nil)
+
+			handler.AddComment(w, newRequest(`{"author":"t2_abcdefg2","body":"hello","parent_id":"2b"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":400,"description":"the parent comment doesn't belong to the post"}]}`)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("AddComment", mock.Anything, mock.Anything).Return(errors.New("storage error"))
+
+			handler.AddComment(w, newRequest(`{"author":"t2_abcdefg2","body":"hello"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("GetComment", mock.Anything, "2b").Return(&protocol.Comment{ID: "2b", PostID: "1a"}, nil).
+				On("AddComment", mock.Anything, &protocol.Comment{
+					PostID:   "1a",
+					ParentID: "2b",
+					Author:   "t2_abcdefg2",
+					Body:     "hello",
+					Created:  mockNow.Unix(),
+				}).
+				Run(func(args mock.Arguments) { args.Get(1).(*protocol.Comment).ID = "3c" }).
+				Return(nil)
+
+			handler.AddComment(w, newRequest(`{"author":"t2_abcdefg2","body":"hello","parent_id":"2b"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"3c"}}`)
+		})
+	})
+}
+
+func TestVoteComment(t *testing.T) {
+	Convey("Test VoteComment", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/comments/3c/vote", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "3c"}), "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a direction is missing", func() {
+			handler.VoteComment(w, newRequest(`{"author":"t2_abcdefg2"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("It fails if a direction is out of range", func() {
+			handler.VoteComment(w, newRequest(`{"author":"t2_abcdefg2","dir":2}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("It fails if a comment doesn't exist", func() {
+			m.
+				On("GetComment", mock.Anything, "3c").Return((*protocol.Comment)(nil), nil)
+
+			handler.VoteComment(w, newRequest(`{"author":"t2_abcdefg2","dir":1}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story (a vote can be withdrawn)", func() {
+			m.
+				On("GetComment", mock.Anything, "3c").Return(&protocol.Comment{ID: "3c"}, nil).
+				On("VoteComment", mock.Anything, &protocol.CommentVoted{CommentID: "3c", Voter: "t2_abcdefg2", Direction: 0}).Return(nil)
+
+			handler.VoteComment(w, newRequest(`{"author":"t2_abcdefg2","dir":0}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
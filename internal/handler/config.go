@@ -0,0 +1,30 @@
+package handler
+
+import "time"
+
+type Config struct {
+	EditWindow time.Duration `env:"POST_EDIT_WINDOW,default=1h"`
+	// Both are defaults and upper bounds of a thread which can be requested at once.
+	CommentsLimit int `env:"COMMENTS_LIMIT,default=50"`
+	CommentsDepth int `env:"COMMENTS_DEPTH,default=8"`
+	// CommentsBudget is a number of comments a response may have at all.
+	CommentsBudget int `env:"COMMENTS_BUDGET,default=200"`
+	// A lifetime of an access token if a client doesn't ask for a specific one and its upper bound.
+	TokenTTL    time.Duration `env:"OAUTH_TOKEN_TTL,default=1h"`
+	TokenMaxTTL time.Duration `env:"OAUTH_TOKEN_MAX_TTL,default=720h"`
+	// Links can point only to allowed domains unless the list is empty. Denied domains are never allowed.
+	AllowedDomains []string `env:"LINK_ALLOW_DOMAINS"`
+	DeniedDomains  []string `env:"LINK_DENY_DOMAINS"`
+	// A link cannot be submitted to a subreddit again within the window unless a user insists. Zero disables it.
+	DuplicateWindow time.Duration `env:"DUPLICATE_WINDOW,default=720h"`
+	// Posts mentioning keywords or linking domains are NSFW whatever their authors say.
+	NSFWKeywords []string `env:"NSFW_KEYWORDS"`
+	NSFWDomains  []string `env:"NSFW_DOMAINS"`
+	// Live connections are pinged every heartbeat and dropped if a write takes longer than the timeout.
+	StreamHeartbeat    time.Duration `env:"STREAM_HEARTBEAT,default=30s"`
+	StreamWriteTimeout time.Duration `env:"STREAM_WRITE_TIMEOUT,default=10s"`
+	// PublicURL is where clients reach the service, links of RSS and Atom feeds start with it.
+	PublicURL string `env:"PUBLIC_URL,default=http://localhost:8080"`
+	// Administrators manage site-wide bans.
+	Admins []string `env:"ADMINS"`
+}
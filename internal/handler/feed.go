@@ -5,32 +5,68 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/go-chi/render"
+	"github.com/go-chi/chi"
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
 )
 
-func (h *handler) Feed(w http.ResponseWriter, r *http.Request) {
+func (h *handler) feed(w http.ResponseWriter, r *http.Request, query *protocol.FeedQuery) {
 	ctx := r.Context()
 
-	var page int
-	{
-		pageVal := r.FormValue("page")
-		if pageVal != "" {
-			v, err := strconv.Atoi(pageVal)
-			if err != nil {
-				h.render.InvalidRequest(w, r, fmt.Errorf("couldn't recognize the page number: %w", err))
-				return
-			}
-			page = v
-		}
+	format, ok := h.format(w, r, true)
+	if !ok {
+		return
+	}
+	if query.Page, ok = h.page(w, r); !ok {
+		return
 	}
+	if !h.filter(w, r, &query.Filter) {
+		return
+	}
+	query.Viewer = middleware.Viewer(r)
+	if id, ok := hlog.IDFromRequest(r); ok {
+		query.RequestID = id.String()
+	}
+	query.NoAds = format == formatRSS || format == formatAtom
 
-	feed, err := h.storage.GetFeed(ctx, page)
+	feed, more, err := h.storage.GetFeed(ctx, query)
 	if err != nil {
 		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a feed")
 		h.render.InternalServerError(w, r, err)
 		return
 	}
 
-	render.Respond(w, r, feed)
+	h.respondListing(w, r, format, query, feed, more)
+}
+
+func (h *handler) Feed(w http.ResponseWriter, r *http.Request) {
+	var query protocol.FeedQuery
+	if homeVal := r.FormValue("home"); homeVal != "" {
+		v, err := strconv.ParseBool(homeVal)
+		if err != nil {
+			h.render.InvalidRequest(w, r, fmt.Errorf("couldn't recognize the home flag: %w", err))
+			return
+		}
+		query.Home = v
+	}
+	// Subscriptions are private, so a home feed is only the authenticated user's own.
+	if query.Home {
+		if query.User = h.author(w, r, ""); query.User == "" {
+			return
+		}
+	}
+
+	h.feed(w, r, &query)
+}
+
+func (h *handler) SubredditFeed(w http.ResponseWriter, r *http.Request) {
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+
+	h.feed(w, r, &protocol.FeedQuery{Subreddit: subreddit.Name})
 }
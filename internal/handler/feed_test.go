@@ -46,7 +46,7 @@ func TestFeed(t *testing.T) {
 				req.Header.Add("Content-Type", "application/json")
 
 				m.
-					On("GetFeed", mock.Anything, 0).Return([]protocol.Post(nil), nil)
+					On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 0, Viewer: "ip:192.0.2.1"}).Return([]protocol.Post(nil), false, nil)
 
 				handler.Feed(w, req)
 
@@ -63,7 +63,7 @@ func TestFeed(t *testing.T) {
 				req.Header.Add("Content-Type", "application/json")
 
 				m.
-					On("GetFeed", mock.Anything, 123).Return([]protocol.Post(nil), nil)
+					On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 123, Viewer: "ip:192.0.2.1"}).Return([]protocol.Post(nil), false, nil)
 
 				handler.Feed(w, req)
 
@@ -77,12 +77,63 @@ func TestFeed(t *testing.T) {
 			})
 		})
 
+		Convey("A home feed", func() {
+			Convey("It fails if a flag is invalid", func() {
+				req := httptest.NewRequest(http.MethodGet, "/feed?home=maybe", nil)
+
+				handler.Feed(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("It fails if a viewer isn't authenticated", func() {
+				req := httptest.NewRequest(http.MethodGet, "/feed?home=true", nil)
+
+				handler.Feed(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("A user is the authenticated one whoever is asked for", func() {
+				req := withUser(httptest.NewRequest(http.MethodGet, "/feed?home=true&user=t2_abcdefg2", nil), "t2_abcdefg3")
+
+				m.
+					On("GetPreferences", mock.Anything, "t2_abcdefg3").Return(&protocol.Preferences{}, nil).
+					On("GetFeed", mock.Anything, &protocol.FeedQuery{
+						Home: true, User: "t2_abcdefg3", Viewer: "t2_abcdefg3", Filter: protocol.FeedFilter{User: "t2_abcdefg3"},
+					}).Return([]protocol.Post{}, false, nil)
+
+				handler.Feed(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+		})
+
+		Convey("Preferences of the viewer filter a feed", func() {
+			req := withUser(httptest.NewRequest(http.MethodGet, "/feed", nil), "t2_abcdefg3")
+
+			m.
+				On("GetPreferences", mock.Anything, "t2_abcdefg3").Return(&protocol.Preferences{Over18: true, HiddenSubreddits: []string{"Games"}}, nil).
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{
+					Viewer: "t2_abcdefg3",
+					Filter: protocol.FeedFilter{Over18: true, User: "t2_abcdefg3", HiddenSubreddits: []string{"Games"}},
+				}).Return([]protocol.Post{}, false, nil)
+
+			handler.Feed(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
 		Convey("It fails if an storage has been failed", func() {
 			req := httptest.NewRequest(http.MethodPost, "/feed?page=123", nil)
 			req.Header.Add("Content-Type", "application/json")
 
 			m.
-				On("GetFeed", mock.Anything, 123).Return([]protocol.Post(nil), errors.New("storage error"))
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 123, Viewer: "ip:192.0.2.1"}).Return([]protocol.Post(nil), false, errors.New("storage error"))
 
 			handler.Feed(w, req)
 
@@ -100,7 +151,7 @@ func TestFeed(t *testing.T) {
 			req.Header.Add("Content-Type", "application/json")
 
 			m.
-				On("GetFeed", mock.Anything, 123).Return([]protocol.Post{}, nil)
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 123, Viewer: "ip:192.0.2.1"}).Return([]protocol.Post{}, false, nil)
 
 			handler.Feed(w, req)
 
@@ -114,3 +165,40 @@ func TestFeed(t *testing.T) {
 		})
 	})
 }
+
+func TestSubredditFeed(t *testing.T) {
+	Convey("Test SubredditFeed", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/r/golang?page=1", nil)
+		req = withURLParams(req, map[string]string{"subreddit": "golang"})
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a subreddit doesn't exist", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return((*protocol.Subreddit)(nil), nil)
+
+			handler.SubredditFeed(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil).
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 1, Subreddit: "GoLang", Viewer: "ip:192.0.2.1"}).Return([]protocol.Post{{ID: "1a", Subreddit: "GoLang"}}, false, nil)
+
+			handler.SubredditFeed(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `[{"id":"1a","title":"","author":"","subreddit":"GoLang","score":0,"promoted":false,"nsfw":false,"num_comments":0}]`)
+		})
+	})
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/render"
+	"github.com/gorilla/websocket"
 
 	"nanoreddit/internal/chi_utils"
 	"nanoreddit/internal/validation"
@@ -18,33 +20,143 @@ type requestBinder interface {
 
 type responseRender interface {
 	InvalidRequest(w http.ResponseWriter, r *http.Request, err error)
+	Unauthorized(w http.ResponseWriter, r *http.Request, err error)
+	Forbidden(w http.ResponseWriter, r *http.Request, err error)
+	NotFound(w http.ResponseWriter, r *http.Request, err error)
+	Conflict(w http.ResponseWriter, r *http.Request, err error)
+	AlreadySubmitted(w http.ResponseWriter, r *http.Request, err error, post protocol.PostRef)
 	InternalServerError(w http.ResponseWriter, r *http.Request, err error)
 }
 
 type storage interface {
 	AddPost(ctx context.Context, post *protocol.Post) error
-	GetFeed(ctx context.Context, page int) ([]protocol.Post, error)
+	EditPost(ctx context.Context, edit *protocol.PostEdited) error
+	DeletePost(ctx context.Context, deletion *protocol.PostDeleted) error
+	// GetPost returns nil if a post doesn't exist.
+	GetPost(ctx context.Context, id string) (*protocol.Post, error)
+	GetFeed(ctx context.Context, query *protocol.FeedQuery) ([]protocol.Post, bool, error)
+	VotePost(ctx context.Context, vote *protocol.PostVoted) error
+
+	AddComment(ctx context.Context, comment *protocol.Comment) error
+	VoteComment(ctx context.Context, vote *protocol.CommentVoted) error
+	// GetComment returns nil if a comment doesn't exist.
+	GetComment(ctx context.Context, id string) (*protocol.Comment, error)
+	GetComments(ctx context.Context, query *protocol.CommentsQuery) ([]protocol.Comment, *protocol.MoreComments, error)
+
+	// AddSubreddit returns false if a name is already taken.
+	AddSubreddit(ctx context.Context, subreddit *protocol.Subreddit) (bool, error)
+	// GetSubreddit returns nil if a subreddit doesn't exist.
+	GetSubreddit(ctx context.Context, name string) (*protocol.Subreddit, error)
+
+	Subscribe(ctx context.Context, user, subreddit string) error
+	Unsubscribe(ctx context.Context, user, subreddit string) error
+	GetSubscriptions(ctx context.Context, user string) ([]string, error)
+	GetPreferences(ctx context.Context, user string) (*protocol.Preferences, error)
+	SetPreferences(ctx context.Context, user string, prefs *protocol.Preferences) error
+	SavePost(ctx context.Context, user, id string, saved int64) error
+	UnsavePost(ctx context.Context, user, id string) error
+	GetSaved(ctx context.Context, user string, page int) ([]protocol.Post, bool, error)
+	HidePost(ctx context.Context, user, id string) error
+	UnhidePost(ctx context.Context, user, id string) error
+	GetHidden(ctx context.Context, user string, ids []string) (map[string]bool, error)
+
+	// AddUser returns false if a name is already taken.
+	AddUser(ctx context.Context, user *protocol.User) (bool, error)
+	// GetUser returns nil if a user doesn't exist.
+	GetUser(ctx context.Context, id string) (*protocol.User, error)
+	// GetUsernames returns names of the users by their identifiers, users who don't exist are left out.
+	GetUsernames(ctx context.Context, ids []string) (map[string]string, error)
+	GetSubmitted(ctx context.Context, author string, own bool, page int) ([]protocol.Post, bool, error)
+	// GetDuplicate returns nil if the link hasn't been submitted to the subreddit since the moment.
+	GetDuplicate(ctx context.Context, subreddit, link string, since int64) (*protocol.Post, error)
+	GetDuplicates(ctx context.Context, link, except string, page int) ([]protocol.Post, bool, error)
+
+	ReportPost(ctx context.Context, report *protocol.PostReported) error
+	ModeratePost(ctx context.Context, action *protocol.ModAction) error
+	IsModerator(ctx context.Context, subreddit, user string) (bool, error)
+	// GetAppointed returns nil if a user isn't a moderator of a subreddit.
+	GetAppointed(ctx context.Context, subreddit, user string) (*int64, error)
+	GetModerators(ctx context.Context, subreddit string) ([]string, error)
+	AddModerator(ctx context.Context, action *protocol.ModAction) error
+	RemoveModerator(ctx context.Context, action *protocol.ModAction) error
+	GetModQueue(ctx context.Context, subreddit string, page int) ([]protocol.ModQueueItem, error)
+	// GetModLog returns entries following the given one, the newest first.
+	GetModLog(ctx context.Context, subreddit, after string) ([]protocol.ModAction, error)
+
+	// BanUser and UnbanUser write an action to the moderation log unless it's nil.
+	BanUser(ctx context.Context, ban *protocol.Ban, action *protocol.ModAction) error
+	UnbanUser(ctx context.Context, subreddit, user string, action *protocol.ModAction) error
+	// GetBan returns nil if a user has never been banned. An empty subreddit stands for site-wide bans.
+	GetBan(ctx context.Context, subreddit, user string) (*protocol.Ban, error)
+	GetBans(ctx context.Context, subreddit string) ([]protocol.Ban, error)
+
+	// AddCampaign issues identifiers of a campaign and its post, and it publishes the post.
+	AddCampaign(ctx context.Context, campaign *protocol.Campaign, post *protocol.Post) error
+	UpdateCampaign(ctx context.Context, campaign *protocol.Campaign) error
+	// GetCampaign returns nil if a campaign doesn't exist.
+	GetCampaign(ctx context.Context, id string) (*protocol.Campaign, error)
+	GetCampaigns(ctx context.Context, owner string) ([]protocol.Campaign, error)
+	// ClickAd returns false if a click doesn't follow an impression or repeats one of the viewer within the hour.
+	ClickAd(ctx context.Context, click *protocol.AdClicked) (bool, error)
+	IsAdvertiser(ctx context.Context, user string) (bool, error)
+	GetAdvertisers(ctx context.Context) ([]string, error)
+	AddAdvertiser(ctx context.Context, user string) error
+	RemoveAdvertiser(ctx context.Context, user string) error
+	// GetCampaignStats returns stats of a campaign overall and of the given hours.
+	GetCampaignStats(ctx context.Context, campaign string, hours []int64) (*protocol.CampaignStats, error)
+
+	AddToken(ctx context.Context, grant *protocol.AccessToken) (string, error)
+	RevokeToken(ctx context.Context, user, token string) error
+}
+
+type spamScorer interface {
+	Score(ctx context.Context, post *protocol.Post) (*protocol.SpamDecision, error)
+}
+
+type feedUpdates interface {
+	// Subscribe returns a channel of updates of the feeds and a function which cancels the subscription. The channel
+	// is closed when the subscription ends.
+	Subscribe() (<-chan protocol.FeedUpdate, func())
+}
+
+type sessionIssuer interface {
+	// Issue returns a session token of the user and its expiration time.
+	Issue(user string) (string, int64)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
 type handler struct {
-	binder  requestBinder
-	render  responseRender
-	storage storage
+	cfg      *Config
+	binder   requestBinder
+	render   responseRender
+	storage  storage
+	sessions sessionIssuer
+	spam     spamScorer
+	updates  feedUpdates
+	upgrader websocket.Upgrader
+	domains  *validation.DomainPolicy
+	now      func() time.Time
 }
 
-func NewHandler(storage storage) (*handler, error) {
+func NewHandler(cfg *Config, storage storage, sessions sessionIssuer, spam spamScorer, updates feedUpdates) (*handler, error) {
 	validateStruct, err := validation.NewValidator()
 	if err != nil {
 		return nil, fmt.Errorf("couldn't create a validator: %w", err)
 	}
+
 	render := chi_utils.NewRender()
 	binder := chi_utils.NewBinder(validateStruct, render.InvalidRequest)
 
 	return &handler{
-		render:  render,
-		binder:  binder,
-		storage: storage,
+		cfg:      cfg,
+		render:   render,
+		binder:   binder,
+		storage:  storage,
+		sessions: sessions,
+		spam:     spam,
+		updates:  updates,
+		domains:  &validation.DomainPolicy{Allow: cfg.AllowedDomains, Deny: cfg.DeniedDomains},
+		now:      time.Now,
 	}, nil
 }
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// Formats of listings.
+const (
+	formatJSON   = "json"
+	formatReddit = "reddit"
+	formatRSS    = "rss"
+	formatAtom   = "atom"
+)
+
+// format picks a format of a listing. The .json suffix of a path, like /feed.json, and ?format=reddit stand for
+// the format of the Reddit API. Feeds are syndicated as well, so they can be requested by suffixes like /feed.rss or
+// by the Accept header. It renders a response and returns false if a format is unknown.
+func (h *handler) format(w http.ResponseWriter, r *http.Request, syndicated bool) (string, bool) {
+	suffix, _ := r.Context().Value(chimiddleware.URLFormatCtxKey).(string)
+	switch {
+	case suffix == "json":
+		return formatReddit, true
+	case syndicated && (suffix == formatRSS || suffix == formatAtom):
+		return suffix, true
+	case suffix != "":
+		h.render.NotFound(w, r, fmt.Errorf("the format %s isn't supported", suffix))
+		return "", false
+	}
+	if formatVal := r.FormValue("format"); formatVal != "" {
+		if formatVal != formatReddit {
+			h.render.InvalidRequest(w, r, fmt.Errorf("the format %s isn't supported", formatVal))
+			return "", false
+		}
+		return formatReddit, true
+	}
+	if !syndicated {
+		return formatJSON, true
+	}
+	w.Header().Add("Vary", "Accept")
+	return negotiate(r.Header.Get("Accept")), true
+}
+
+// negotiate picks the most preferred format accepted by a client. JSON is the default.
+func negotiate(accept string) string {
+	format, quality := formatJSON, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		var candidate string
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case "application/json":
+			candidate = formatJSON
+		case protocol.ContentTypeRSS:
+			candidate = formatRSS
+		case protocol.ContentTypeAtom:
+			candidate = formatAtom
+		default:
+			continue
+		}
+		if q > quality {
+			format, quality = candidate, q
+		}
+	}
+	return format
+}
+
+// page reads the number of a page of a listing. Cursors of listings in the format of the Reddit API are page
+// numbers, so after and before stand for the page too. It renders a response and returns false if the number is
+// invalid.
+func (h *handler) page(w http.ResponseWriter, r *http.Request) (int, bool) {
+	pageVal := r.FormValue("page")
+	for _, name := range []string{"after", "before"} {
+		if pageVal == "" {
+			pageVal = r.FormValue(name)
+		}
+	}
+	if pageVal == "" {
+		return 0, true
+	}
+	v, err := strconv.Atoi(pageVal)
+	if err != nil {
+		h.render.InvalidRequest(w, r, fmt.Errorf("couldn't recognize the page number: %w", err))
+		return 0, false
+	}
+	return v, true
+}
+
+// respondListing renders posts of a feed in the given format. More tells whether the feed has more pages.
+func (h *handler) respondListing(w http.ResponseWriter, r *http.Request, format string, query *protocol.FeedQuery,
+	posts []protocol.Post, more bool) {
+	switch format {
+	case formatRSS:
+		h.respondXML(w, r, protocol.ContentTypeRSS, h.rss(query, posts))
+	case formatAtom:
+		h.respondXML(w, r, protocol.ContentTypeAtom, h.atom(r, query, posts))
+	default:
+		h.respondPosts(w, r, format, query.Page, posts, more)
+	}
+}
+
+// respondPosts renders a page of posts in the given format. Listings are public, so they never have spam decisions.
+// More tells whether the listing has more pages.
+func (h *handler) respondPosts(w http.ResponseWriter, r *http.Request, format string, page int, posts []protocol.Post,
+	more bool) {
+	if format == formatReddit {
+		ctx := r.Context()
+		names, err := h.authorNames(ctx, posts)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch names of authors")
+			h.render.InternalServerError(w, r, err)
+			return
+		}
+		render.Respond(w, r, h.redditListing(page, posts, more, names))
+		return
+	}
+	render.Respond(w, r, protocol.PublicPosts(posts))
+}
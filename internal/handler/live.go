@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// liveFeed selects updates which a live connection is interested in.
+type liveFeed struct {
+	// An empty list stands for all subreddits.
+	subreddits []string
+	filter     protocol.FeedFilter
+	storage    storage
+}
+
+// matches tells whether an update should be pushed. Hidden subreddits are pushed only if they are asked for explicitly,
+// just as their own feeds show them. Hidden posts are looked up for every update, so posts the viewer hides while
+// connected are left out too.
+func (f *liveFeed) matches(ctx context.Context, update *protocol.FeedUpdate) (bool, error) {
+	subreddit := ""
+	if len(f.subreddits) != 0 {
+		for _, name := range f.subreddits {
+			if strings.EqualFold(name, update.Subreddit) {
+				subreddit = name
+				break
+			}
+		}
+		if subreddit == "" {
+			return false, nil
+		}
+	}
+	post := update.Post
+	if post == nil {
+		post = &protocol.Post{ID: update.ID, Subreddit: update.Subreddit}
+	}
+	if f.filter.Hides(post, subreddit) {
+		return false, nil
+	}
+	if f.filter.User == "" {
+		return true, nil
+	}
+	hidden, err := f.storage.GetHidden(ctx, f.filter.User, []string{update.ID})
+	if err != nil {
+		return false, fmt.Errorf("couldn't fetch hidden posts: %w", err)
+	}
+	return !hidden[update.ID], nil
+}
+
+// liveFeed reads subreddits of a live connection and preferences of the viewer. It renders a response and returns
+// nil if they cannot be applied.
+func (h *handler) liveFeed(w http.ResponseWriter, r *http.Request) *liveFeed {
+	feed := liveFeed{storage: h.storage}
+	if subredditsVal := r.FormValue("subreddits"); subredditsVal != "" {
+		names, ok := h.subreddits(w, r, strings.Split(subredditsVal, ","), true)
+		if !ok {
+			return nil
+		}
+		feed.subreddits = names
+	}
+	if !h.filter(w, r, &feed.filter) {
+		return nil
+	}
+	return &feed
+}
+
+// FeedStream pushes updates of the feeds as Server-Sent Events. Events are named after types of updates. The stream
+// ends if the connection falls behind, and clients are expected to reconnect and refetch the feed.
+func (h *handler) FeedStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.render.InternalServerError(w, r, errors.New("streaming isn't supported"))
+		return
+	}
+	feed := h.liveFeed(w, r)
+	if feed == nil {
+		return
+	}
+
+	updates, unsubscribe := h.updates.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.cfg.StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			var push bool
+			if push, err = feed.matches(ctx, &update); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't filter a feed update")
+				return
+			}
+			if !push {
+				continue
+			}
+			var blob []byte
+			if blob, err = json.Marshal(&update); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't marshal a feed update")
+				return
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, blob)
+		case <-heartbeat.C:
+			// Comments keep proxies from closing an idle connection.
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// FeedSocket pushes updates of the feeds as JSON messages over a WebSocket. The server closes the connection with
+// the "try again later" code if it falls behind.
+func (h *handler) FeedSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	feed := h.liveFeed(w, r)
+	if feed == nil {
+		return
+	}
+
+	// The upgrader responds by itself if a request isn't a proper handshake.
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		zerolog.Ctx(ctx).Debug().Err(err).Msg("Couldn't upgrade a connection")
+		return
+	}
+	defer conn.Close()
+
+	updates, unsubscribe := h.updates.Subscribe()
+	defer unsubscribe()
+
+	// Clients aren't supposed to send anything, but control frames are handled only while reading, and reading is
+	// how a closed connection is noticed.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(h.cfg.StreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+		case update, ok := <-updates:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "")
+				_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(h.cfg.StreamWriteTimeout))
+				return
+			}
+			var push bool
+			if push, err = feed.matches(ctx, &update); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't filter a feed update")
+				return
+			}
+			if !push {
+				continue
+			}
+			if err = conn.SetWriteDeadline(time.Now().Add(h.cfg.StreamWriteTimeout)); err == nil {
+				err = conn.WriteJSON(&update)
+			}
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.cfg.StreamWriteTimeout))
+		}
+		if err != nil {
+			zerolog.Ctx(ctx).Debug().Err(err).Msg("Couldn't write to a live connection")
+			return
+		}
+	}
+}
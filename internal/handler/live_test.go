@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// endedSubscription returns a subscription which has already got the updates and has ended.
+func endedSubscription(updates ...protocol.FeedUpdate) <-chan protocol.FeedUpdate {
+	ch := make(chan protocol.FeedUpdate, len(updates))
+	for _, update := range updates {
+		ch <- update
+	}
+	close(ch)
+	return ch
+}
+
+var liveUpdates = []protocol.FeedUpdate{
+	{Type: "new", ID: "1", Subreddit: "golang", Post: &protocol.Post{ID: "1", Subreddit: "golang", Title: "Go 1.16"}},
+	// It's NSFW.
+	{Type: "new", ID: "2", Subreddit: "golang", Post: &protocol.Post{ID: "2", Subreddit: "golang", NSFW: true}},
+	// It's hidden.
+	{Type: "score", ID: "3", Subreddit: "golang", Post: &protocol.Post{ID: "3", Subreddit: "golang", Score: 2}},
+	// The subreddit hasn't been asked for.
+	{Type: "new", ID: "4", Subreddit: "rust", Post: &protocol.Post{ID: "4", Subreddit: "rust"}},
+	// The subreddit is hidden, but it's been asked for.
+	{Type: "removal", ID: "5", Subreddit: "Games"},
+}
+
+func TestFeedStream(t *testing.T) {
+	Convey("Test FeedStream", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a subreddit doesn't exist", func() {
+			m.On("GetSubreddit", mock.Anything, "nowhere").Return((*protocol.Subreddit)(nil), nil)
+
+			handler.FeedStream(w, httptest.NewRequest(http.MethodGet, "/feed/stream?subreddits=nowhere", nil))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			unsubscribed := false
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("GetSubreddit", mock.Anything, "games").Return(&protocol.Subreddit{Name: "Games"}, nil).
+				On("GetPreferences", mock.Anything, "t2_abcdefg2").Return(&protocol.Preferences{HiddenSubreddits: []string{"Games"}}, nil).
+				On("GetHidden", mock.Anything, "t2_abcdefg2", []string{"1"}).Return(map[string]bool{}, nil).
+				On("GetHidden", mock.Anything, "t2_abcdefg2", []string{"3"}).Return(map[string]bool{"3": true}, nil).
+				On("GetHidden", mock.Anything, "t2_abcdefg2", []string{"5"}).Return(map[string]bool{}, nil).
+				On("Subscribe").Return(endedSubscription(liveUpdates...), func() { unsubscribed = true })
+
+			req := httptest.NewRequest(http.MethodGet, "/feed/stream?subreddits=golang,games", nil)
+			handler.FeedStream(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(unsubscribed, ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), ShouldEqual, "event: new\n"+
+				`data: {"type":"new","id":"1","subreddit":"golang","post":{"id":"1","title":"Go 1.16","author":"","subreddit":"golang","score":0,"promoted":false,"nsfw":false,"num_comments":0}}`+"\n\n"+
+				"event: removal\n"+
+				`data: {"type":"removal","id":"5","subreddit":"Games"}`+"\n\n")
+		})
+	})
+}
+
+func TestFeedSocket(t *testing.T) {
+	Convey("Test FeedSocket", t, func() {
+		m := &mock.Mock{}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+		srv := httptest.NewServer(http.HandlerFunc(handler.FeedSocket))
+		defer srv.Close()
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/feed/ws"
+
+		Convey("It fails if a subreddit doesn't exist", func() {
+			m.On("GetSubreddit", mock.Anything, "nowhere").Return((*protocol.Subreddit)(nil), nil)
+
+			_, resp, err := websocket.DefaultDialer.Dial(url+"?subreddits=nowhere", nil)
+
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("Subscribe").Return(endedSubscription(liveUpdates...), func() {})
+
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			// Anonymous viewers don't see NSFW posts, and they see the rest.
+			var ids []string
+			for {
+				var update protocol.FeedUpdate
+				if err := conn.ReadJSON(&update); err != nil {
+					So(websocket.IsCloseError(err, websocket.CloseTryAgainLater), ShouldBeTrue)
+					break
+				}
+				ids = append(ids, update.ID)
+			}
+			So(ids, ShouldResemble, []string{"1", "3", "4", "5"})
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/stretchr/testify/mock"
 
 	"nanoreddit/internal/chi_utils"
+	"nanoreddit/internal/middleware"
 	"nanoreddit/internal/validation"
 	"nanoreddit/pkg/protocol"
 )
@@ -32,6 +35,22 @@ func (m *mockRender) InvalidRequest(w http.ResponseWriter, r *http.Request, err
 	m.m.Called(w, r, err)
 }
 
+func (m *mockRender) Unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	m.m.Called(w, r, err)
+}
+
+func (m *mockRender) Forbidden(w http.ResponseWriter, r *http.Request, err error) {
+	m.m.Called(w, r, err)
+}
+
+func (m *mockRender) NotFound(w http.ResponseWriter, r *http.Request, err error) {
+	m.m.Called(w, r, err)
+}
+
+func (m *mockRender) Conflict(w http.ResponseWriter, r *http.Request, err error) {
+	m.m.Called(w, r, err)
+}
+
 func (m *mockRender) InternalServerError(w http.ResponseWriter, r *http.Request, err error) {
 	m.m.Called(w, r, err)
 }
@@ -47,13 +66,304 @@ func (m *mockStorage) AddPost(ctx context.Context, post *protocol.Post) error {
 	return args.Error(0)
 }
 
-func (m *mockStorage) GetFeed(ctx context.Context, page int) ([]protocol.Post, error) {
-	args := m.m.Called(ctx, page)
-	return args.Get(0).([]protocol.Post), args.Error(1)
+func (m *mockStorage) EditPost(ctx context.Context, edit *protocol.PostEdited) error {
+	args := m.m.Called(ctx, edit)
+	return args.Error(0)
+}
+
+func (m *mockStorage) DeletePost(ctx context.Context, deletion *protocol.PostDeleted) error {
+	args := m.m.Called(ctx, deletion)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetPost(ctx context.Context, id string) (*protocol.Post, error) {
+	args := m.m.Called(ctx, id)
+	return args.Get(0).(*protocol.Post), args.Error(1)
+}
+
+func (m *mockStorage) GetFeed(ctx context.Context, query *protocol.FeedQuery) ([]protocol.Post, bool, error) {
+	args := m.m.Called(ctx, query)
+	return args.Get(0).([]protocol.Post), args.Bool(1), args.Error(2)
+}
+
+func (m *mockStorage) VotePost(ctx context.Context, vote *protocol.PostVoted) error {
+	args := m.m.Called(ctx, vote)
+	return args.Error(0)
+}
+
+func (m *mockStorage) AddComment(ctx context.Context, comment *protocol.Comment) error {
+	args := m.m.Called(ctx, comment)
+	return args.Error(0)
+}
+
+func (m *mockStorage) VoteComment(ctx context.Context, vote *protocol.CommentVoted) error {
+	args := m.m.Called(ctx, vote)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetComment(ctx context.Context, id string) (*protocol.Comment, error) {
+	args := m.m.Called(ctx, id)
+	return args.Get(0).(*protocol.Comment), args.Error(1)
+}
+
+func (m *mockStorage) GetComments(ctx context.Context, query *protocol.CommentsQuery) ([]protocol.Comment, *protocol.MoreComments, error) {
+	args := m.m.Called(ctx, query)
+	return args.Get(0).([]protocol.Comment), args.Get(1).(*protocol.MoreComments), args.Error(2)
+}
+
+func (m *mockStorage) AddSubreddit(ctx context.Context, subreddit *protocol.Subreddit) (bool, error) {
+	args := m.m.Called(ctx, subreddit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetSubreddit(ctx context.Context, name string) (*protocol.Subreddit, error) {
+	args := m.m.Called(ctx, name)
+	return args.Get(0).(*protocol.Subreddit), args.Error(1)
+}
+
+func (m *mockStorage) Subscribe(ctx context.Context, user, subreddit string) error {
+	args := m.m.Called(ctx, user, subreddit)
+	return args.Error(0)
+}
+
+func (m *mockStorage) Unsubscribe(ctx context.Context, user, subreddit string) error {
+	args := m.m.Called(ctx, user, subreddit)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetSubscriptions(ctx context.Context, user string) ([]string, error) {
+	args := m.m.Called(ctx, user)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) AddUser(ctx context.Context, user *protocol.User) (bool, error) {
+	args := m.m.Called(ctx, user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetUser(ctx context.Context, id string) (*protocol.User, error) {
+	args := m.m.Called(ctx, id)
+	return args.Get(0).(*protocol.User), args.Error(1)
+}
+
+func (m *mockStorage) GetUsernames(ctx context.Context, ids []string) (map[string]string, error) {
+	args := m.m.Called(ctx, ids)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (m *mockStorage) GetSubmitted(ctx context.Context, author string, own bool, page int) ([]protocol.Post, bool, error) {
+	args := m.m.Called(ctx, author, own, page)
+	return args.Get(0).([]protocol.Post), args.Bool(1), args.Error(2)
+}
+
+func (m *mockStorage) GetDuplicate(ctx context.Context, subreddit, link string, since int64) (*protocol.Post, error) {
+	args := m.m.Called(ctx, subreddit, link, since)
+	return args.Get(0).(*protocol.Post), args.Error(1)
+}
+
+func (m *mockStorage) GetDuplicates(ctx context.Context, link, except string, page int) ([]protocol.Post, bool, error) {
+	args := m.m.Called(ctx, link, except, page)
+	return args.Get(0).([]protocol.Post), args.Bool(1), args.Error(2)
+}
+
+func (m *mockStorage) ReportPost(ctx context.Context, report *protocol.PostReported) error {
+	args := m.m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *mockStorage) ModeratePost(ctx context.Context, action *protocol.ModAction) error {
+	args := m.m.Called(ctx, action)
+	return args.Error(0)
+}
+
+func (m *mockStorage) IsModerator(ctx context.Context, subreddit, user string) (bool, error) {
+	args := m.m.Called(ctx, subreddit, user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetAppointed(ctx context.Context, subreddit, user string) (*int64, error) {
+	args := m.m.Called(ctx, subreddit, user)
+	return args.Get(0).(*int64), args.Error(1)
+}
+
+func (m *mockStorage) GetModerators(ctx context.Context, subreddit string) ([]string, error) {
+	args := m.m.Called(ctx, subreddit)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) AddModerator(ctx context.Context, action *protocol.ModAction) error {
+	args := m.m.Called(ctx, action)
+	return args.Error(0)
+}
+
+func (m *mockStorage) RemoveModerator(ctx context.Context, action *protocol.ModAction) error {
+	args := m.m.Called(ctx, action)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetModQueue(ctx context.Context, subreddit string, page int) ([]protocol.ModQueueItem, error) {
+	args := m.m.Called(ctx, subreddit, page)
+	return args.Get(0).([]protocol.ModQueueItem), args.Error(1)
+}
+
+func (m *mockStorage) GetModLog(ctx context.Context, subreddit, after string) ([]protocol.ModAction, error) {
+	args := m.m.Called(ctx, subreddit, after)
+	return args.Get(0).([]protocol.ModAction), args.Error(1)
+}
+
+func (m *mockStorage) BanUser(ctx context.Context, ban *protocol.Ban, action *protocol.ModAction) error {
+	args := m.m.Called(ctx, ban, action)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnbanUser(ctx context.Context, subreddit, user string, action *protocol.ModAction) error {
+	args := m.m.Called(ctx, subreddit, user, action)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetBan(ctx context.Context, subreddit, user string) (*protocol.Ban, error) {
+	args := m.m.Called(ctx, subreddit, user)
+	return args.Get(0).(*protocol.Ban), args.Error(1)
+}
+
+func (m *mockStorage) GetBans(ctx context.Context, subreddit string) ([]protocol.Ban, error) {
+	args := m.m.Called(ctx, subreddit)
+	return args.Get(0).([]protocol.Ban), args.Error(1)
+}
+
+func (m *mockStorage) IsAdvertiser(ctx context.Context, user string) (bool, error) {
+	args := m.m.Called(ctx, user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetAdvertisers(ctx context.Context) ([]string, error) {
+	args := m.m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockStorage) AddAdvertiser(ctx context.Context, user string) error {
+	args := m.m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *mockStorage) RemoveAdvertiser(ctx context.Context, user string) error {
+	args := m.m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *mockStorage) AddCampaign(ctx context.Context, campaign *protocol.Campaign, post *protocol.Post) error {
+	args := m.m.Called(ctx, campaign, post)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UpdateCampaign(ctx context.Context, campaign *protocol.Campaign) error {
+	args := m.m.Called(ctx, campaign)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetCampaign(ctx context.Context, id string) (*protocol.Campaign, error) {
+	args := m.m.Called(ctx, id)
+	return args.Get(0).(*protocol.Campaign), args.Error(1)
+}
+
+func (m *mockStorage) GetCampaigns(ctx context.Context, owner string) ([]protocol.Campaign, error) {
+	args := m.m.Called(ctx, owner)
+	return args.Get(0).([]protocol.Campaign), args.Error(1)
+}
+
+func (m *mockStorage) ClickAd(ctx context.Context, click *protocol.AdClicked) (bool, error) {
+	args := m.m.Called(ctx, click)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockStorage) GetCampaignStats(ctx context.Context, campaign string, hours []int64) (*protocol.CampaignStats, error) {
+	args := m.m.Called(ctx, campaign, hours)
+	return args.Get(0).(*protocol.CampaignStats), args.Error(1)
+}
+
+func (m *mockStorage) GetPreferences(ctx context.Context, user string) (*protocol.Preferences, error) {
+	args := m.m.Called(ctx, user)
+	return args.Get(0).(*protocol.Preferences), args.Error(1)
+}
+
+func (m *mockStorage) SetPreferences(ctx context.Context, user string, prefs *protocol.Preferences) error {
+	args := m.m.Called(ctx, user, prefs)
+	return args.Error(0)
+}
+
+func (m *mockStorage) SavePost(ctx context.Context, user, id string, saved int64) error {
+	args := m.m.Called(ctx, user, id, saved)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnsavePost(ctx context.Context, user, id string) error {
+	args := m.m.Called(ctx, user, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetSaved(ctx context.Context, user string, page int) ([]protocol.Post, bool, error) {
+	args := m.m.Called(ctx, user, page)
+	return args.Get(0).([]protocol.Post), args.Bool(1), args.Error(2)
+}
+
+func (m *mockStorage) HidePost(ctx context.Context, user, id string) error {
+	args := m.m.Called(ctx, user, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) UnhidePost(ctx context.Context, user, id string) error {
+	args := m.m.Called(ctx, user, id)
+	return args.Error(0)
+}
+
+func (m *mockStorage) GetHidden(ctx context.Context, user string, ids []string) (map[string]bool, error) {
+	args := m.m.Called(ctx, user, ids)
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+func (m *mockStorage) AddToken(ctx context.Context, grant *protocol.AccessToken) (string, error) {
+	args := m.m.Called(ctx, grant)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockStorage) RevokeToken(ctx context.Context, user, token string) error {
+	args := m.m.Called(ctx, user, token)
+	return args.Error(0)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type mockSessions struct {
+	m *mock.Mock
+}
+
+type mockSpam struct {
+	m *mock.Mock
+}
+
+func (m *mockSpam) Score(ctx context.Context, post *protocol.Post) (*protocol.SpamDecision, error) {
+	args := m.m.Called(ctx, post)
+	return args.Get(0).(*protocol.SpamDecision), args.Error(1)
+}
+
+type mockUpdates struct {
+	m *mock.Mock
+}
+
+func (m *mockUpdates) Subscribe() (<-chan protocol.FeedUpdate, func()) {
+	args := m.m.Called()
+	return args.Get(0).(<-chan protocol.FeedUpdate), args.Get(1).(func())
+}
+
+func (m *mockSessions) Issue(user string) (string, int64) {
+	args := m.m.Called(user)
+	return args.String(0), args.Get(1).(int64)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 
+var mockNow = time.Date(2021, time.January, 30, 12, 0, 0, 0, time.UTC)
+
 func mockHandler(m *mock.Mock) (*handler, error) {
 	validateStruct, err := validation.NewValidator()
 	if err != nil {
@@ -64,8 +374,32 @@ func mockHandler(m *mock.Mock) (*handler, error) {
 	binder := chi_utils.NewBinder(validateStruct, render.InvalidRequest)
 
 	return &handler{
-		binder:  binder,
-		render:  render,
-		storage: &mockStorage{m: m},
+		cfg: &Config{
+			EditWindow: time.Hour, CommentsLimit: 50, CommentsDepth: 8, CommentsBudget: 200, TokenTTL: time.Hour, TokenMaxTTL: 24 * time.Hour,
+			NSFWKeywords: []string{"nsfw", "porn"}, NSFWDomains: []string{"pornhub.com"}, Admins: []string{"t2_abcdefg1"},
+			StreamHeartbeat: time.Hour, StreamWriteTimeout: time.Second, PublicURL: "https://nanoreddit.example",
+		},
+		binder:   binder,
+		render:   render,
+		storage:  &mockStorage{m: m},
+		sessions: &mockSessions{m: m},
+		spam:     &mockSpam{m: m},
+		updates:  &mockUpdates{m: m},
+		domains:  &validation.DomainPolicy{Deny: []string{"evil.com"}},
+		now:      func() time.Time { return mockNow },
 	}, nil
 }
+
+// withURLParams emulates chi routing so that handlers are able to read URL parameters.
+func withURLParams(r *http.Request, params map[string]string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+// withUser emulates the authentication middleware.
+func withUser(r *http.Request, user string) *http.Request {
+	return r.WithContext(middleware.WithUser(r.Context(), user))
+}
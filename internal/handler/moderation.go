@@ -0,0 +1,325 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// moderator ensures the author of a request moderates a subreddit. The creator of a subreddit is always its
+// moderator. It renders a response and returns an empty string if the author isn't a moderator.
+func (h *handler) moderator(w http.ResponseWriter, r *http.Request, subreddit *protocol.Subreddit) string {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return ""
+	}
+	if user == subreddit.Creator {
+		return user
+	}
+	ok, err := h.storage.IsModerator(ctx, subreddit.Name, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't check a moderator")
+		h.render.InternalServerError(w, r, err)
+		return ""
+	}
+	if !ok {
+		h.render.Forbidden(w, r, errors.New("only moderators of the subreddit can do this"))
+		return ""
+	}
+	return user
+}
+
+// livePost fetches a post referred by the URL. It renders a response and returns nil if there is no such post.
+func (h *handler) livePost(w http.ResponseWriter, r *http.Request) *protocol.Post {
+	ctx := r.Context()
+
+	post, err := h.storage.GetPost(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return nil
+	}
+	if post == nil || post.Deleted {
+		h.render.NotFound(w, r, errPostNotFound)
+		return nil
+	}
+	return post
+}
+
+func (h *handler) ReportPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.ReportRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	reporter := h.author(w, r, "")
+	if reporter == "" {
+		return
+	}
+	post := h.livePost(w, r)
+	if post == nil {
+		return
+	}
+
+	report := protocol.PostReported{
+		ID:       post.ID,
+		Reporter: reporter,
+		Reason:   request.Reason,
+		Reported: h.now().Unix(),
+	}
+	if err := h.storage.ReportPost(ctx, &report); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a report")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
+}
+
+func (h *handler) ApprovePost(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, protocol.ModApprove)
+}
+
+func (h *handler) RemovePost(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, protocol.ModRemove)
+}
+
+// SpamPost removes a post like RemovePost does, but it spoils the reputation of the domain of the post as well.
+func (h *handler) SpamPost(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, protocol.ModSpam)
+}
+
+func (h *handler) moderate(w http.ResponseWriter, r *http.Request, action string) {
+	ctx := r.Context()
+
+	// A body is optional, since a reason is optional.
+	var request protocol.ModerateRequest
+	if r.ContentLength != 0 {
+		if err := h.binder.Bind(w, r, &request); err != nil {
+			return
+		}
+	}
+
+	post := h.livePost(w, r)
+	if post == nil {
+		return
+	}
+	subreddit := h.subreddit(w, r, post.Subreddit)
+	if subreddit == nil {
+		return
+	}
+	moderator := h.moderator(w, r, subreddit)
+	if moderator == "" {
+		return
+	}
+
+	if err := h.storage.ModeratePost(ctx, &protocol.ModAction{
+		Subreddit: subreddit.Name,
+		Moderator: moderator,
+		Action:    action,
+		Target:    post.ID,
+		Reason:    request.Reason,
+		Created:   h.now().Unix(),
+	}); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a moderation action")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
+}
+
+// ModQueue lists posts of a subreddit waiting for moderators: reported posts and posts suspected to be spam.
+func (h *handler) ModQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var page int
+	if pageVal := r.FormValue("page"); pageVal != "" {
+		v, err := strconv.Atoi(pageVal)
+		if err != nil {
+			h.render.InvalidRequest(w, r, fmt.Errorf("couldn't recognize the page number: %w", err))
+			return
+		}
+		page = v
+	}
+
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	if h.moderator(w, r, subreddit) == "" {
+		return
+	}
+
+	queue, err := h.storage.GetModQueue(ctx, subreddit.Name, page)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a moderation queue")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, queue)
+}
+
+// ModLog lists actions of moderators of a subreddit, the newest first.
+func (h *handler) ModLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	if h.moderator(w, r, subreddit) == "" {
+		return
+	}
+
+	actions, err := h.storage.GetModLog(ctx, subreddit.Name, r.FormValue("after"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a moderation log")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, actions)
+}
+
+// Moderators lists moderators of a subreddit, the creator first.
+func (h *handler) Moderators(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+
+	appointed, err := h.storage.GetModerators(ctx, subreddit.Name)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch moderators")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	moderators := []string{subreddit.Creator}
+	for _, moderator := range appointed {
+		if moderator != subreddit.Creator {
+			moderators = append(moderators, moderator)
+		}
+	}
+
+	render.Respond(w, r, &protocol.ModeratorsResponse{Data: moderators})
+}
+
+func (h *handler) AddModerator(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.ModeratorRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	moderator := h.moderator(w, r, subreddit)
+	if moderator == "" {
+		return
+	}
+
+	user, err := h.storage.GetUser(ctx, request.User)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a user")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if user == nil {
+		h.render.NotFound(w, r, errUserNotFound)
+		return
+	}
+
+	if err := h.storage.AddModerator(ctx, &protocol.ModAction{
+		Subreddit: subreddit.Name,
+		Moderator: moderator,
+		Action:    protocol.ModAddModerator,
+		Target:    user.ID,
+		Created:   h.now().Unix(),
+	}); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't add a moderator")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.Moderators(w, r)
+}
+
+// outranks ensures a moderator may dismiss the target: the creator dismisses anyone, other moderators dismiss
+// themselves and moderators appointed after them. It renders a response and returns false otherwise.
+func (h *handler) outranks(w http.ResponseWriter, r *http.Request, subreddit *protocol.Subreddit, moderator, target string) bool {
+	ctx := r.Context()
+
+	appointed := make([]*int64, 0, 2)
+	for _, user := range []string{moderator, target} {
+		since, err := h.storage.GetAppointed(ctx, subreddit.Name, user)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't check a moderator")
+			h.render.InternalServerError(w, r, err)
+			return false
+		}
+		appointed = append(appointed, since)
+	}
+	if appointed[1] == nil {
+		h.render.NotFound(w, r, errors.New("the user isn't a moderator of the subreddit"))
+		return false
+	}
+	if moderator == subreddit.Creator || moderator == target {
+		return true
+	}
+	if appointed[0] == nil || *appointed[0] >= *appointed[1] {
+		h.render.Forbidden(w, r, errors.New("only moderators appointed earlier can remove the moderator"))
+		return false
+	}
+	return true
+}
+
+func (h *handler) RemoveModerator(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+	moderator := h.moderator(w, r, subreddit)
+	if moderator == "" {
+		return
+	}
+	target := chi.URLParam(r, "user")
+	if target == subreddit.Creator {
+		h.render.Forbidden(w, r, errors.New("the creator of the subreddit cannot be removed"))
+		return
+	}
+	if !h.outranks(w, r, subreddit, moderator, target) {
+		return
+	}
+
+	if err := h.storage.RemoveModerator(ctx, &protocol.ModAction{
+		Subreddit: subreddit.Name,
+		Moderator: moderator,
+		Action:    protocol.ModRemoveModerator,
+		Target:    target,
+		Created:   h.now().Unix(),
+	}); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't remove a moderator")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.Moderators(w, r)
+}
@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestReportPost(t *testing.T) {
+	Convey("Test ReportPost", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/posts/1a/report", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg3")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a reason is unknown", func() {
+			handler.ReportPost(w, newRequest(`{"reason":"boring"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post is deleted", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Deleted: true}, nil)
+
+			handler.ReportPost(w, newRequest(`{"reason":"spam"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Subreddit: "golang"}, nil).
+				On("ReportPost", mock.Anything, &protocol.PostReported{
+					ID:       "1a",
+					Reporter: "t2_abcdefg3",
+					Reason:   protocol.ReportSpam,
+					Reported: mockNow.Unix(),
+				}).Return(nil)
+
+			handler.ReportPost(w, newRequest(`{"reason":"spam"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
+
+func TestModeratePost(t *testing.T) {
+	Convey("Test moderating posts", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(user, body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/posts/1a/remove", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), user)
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Subreddit: "golang"}, nil).
+			On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang", Creator: "t2_abcdefg2"}, nil)
+
+		Convey("It fails if a user isn't a moderator", func() {
+			m.
+				On("IsModerator", mock.Anything, "GoLang", "t2_abcdefg3").Return(false, nil)
+
+			handler.RemovePost(w, newRequest("t2_abcdefg3", ""))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"only moderators of the subreddit can do this"}]}`)
+		})
+
+		Convey("It fails if an action cannot be published", func() {
+			m.
+				On("ModeratePost", mock.Anything, mock.Anything).Return(errors.New("storage error"))
+
+			handler.RemovePost(w, newRequest("t2_abcdefg2", ""))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("The creator of a subreddit moderates it", func() {
+			m.
+				On("ModeratePost", mock.Anything, &protocol.ModAction{
+					Subreddit: "GoLang",
+					Moderator: "t2_abcdefg2",
+					Action:    protocol.ModSpam,
+					Target:    "1a",
+					Reason:    "link farm",
+					Created:   mockNow.Unix(),
+				}).Return(nil)
+
+			handler.SpamPost(w, newRequest("t2_abcdefg2", `{"reason":"link farm"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An appointed moderator moderates a subreddit", func() {
+			m.
+				On("IsModerator", mock.Anything, "GoLang", "t2_abcdefg3").Return(true, nil).
+				On("ModeratePost", mock.Anything, &protocol.ModAction{
+					Subreddit: "GoLang",
+					Moderator: "t2_abcdefg3",
+					Action:    protocol.ModApprove,
+					Target:    "1a",
+					Created:   mockNow.Unix(),
+				}).Return(nil)
+
+			handler.ApprovePost(w, newRequest("t2_abcdefg3", ""))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
+
+func TestModQueue(t *testing.T) {
+	Convey("Test ModQueue", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/r/golang/about/modqueue?page=1", nil)
+		req = withUser(withURLParams(req, map[string]string{"subreddit": "golang"}), "t2_abcdefg2")
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang", Creator: "t2_abcdefg2"}, nil)
+
+		Convey("It fails if a storage has been failed", func() {
+			m.
+				On("GetModQueue", mock.Anything, "GoLang", 1).Return(([]protocol.ModQueueItem)(nil), errors.New("storage error"))
+
+			handler.ModQueue(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			queue := []protocol.ModQueueItem{{
+				Post:    protocol.Post{ID: "1a", Title: "title", Author: "t2_abcdefg3", Subreddit: "GoLang", Created: 100},
+				Reports: map[string]int{protocol.ReportSpam: 2},
+			}}
+			m.
+				On("GetModQueue", mock.Anything, "GoLang", 1).Return(queue, nil)
+
+			handler.ModQueue(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `[{"id":"1a","title":"title","author":"t2_abcdefg3","subreddit":"GoLang","score":0,"promoted":false,"nsfw":false,"num_comments":0,"created":100,"reports":{"spam":2}}]`)
+		})
+	})
+}
+
+func TestModerators(t *testing.T) {
+	Convey("Test managing moderators", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		m.
+			On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang", Creator: "t2_abcdefg2"}, nil)
+
+		Convey("The creator is listed first", func() {
+			m.
+				On("GetModerators", mock.Anything, "GoLang").Return([]string{"t2_abcdefg3"}, nil)
+
+			req := withURLParams(httptest.NewRequest(http.MethodGet, "/r/golang/about/moderators", nil), map[string]string{"subreddit": "golang"})
+			handler.Moderators(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":["t2_abcdefg2","t2_abcdefg3"]}`)
+		})
+
+		Convey("A moderator appoints an existing user", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg3").Return(&protocol.User{ID: "t2_abcdefg3"}, nil).
+				On("AddModerator", mock.Anything, &protocol.ModAction{
+					Subreddit: "GoLang",
+					Moderator: "t2_abcdefg2",
+					Action:    protocol.ModAddModerator,
+					Target:    "t2_abcdefg3",
+					Created:   mockNow.Unix(),
+				}).Return(nil).
+				On("GetModerators", mock.Anything, "GoLang").Return([]string{"t2_abcdefg3"}, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/r/golang/about/moderators", bytes.NewBufferString(`{"user":"t2_abcdefg3"}`))
+			req.Header.Add("Content-Type", "application/json")
+			req = withUser(withURLParams(req, map[string]string{"subreddit": "golang"}), "t2_abcdefg2")
+			handler.AddModerator(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("The creator cannot be removed", func() {
+			req := httptest.NewRequest(http.MethodDelete, "/r/golang/about/moderators/t2_abcdefg2", nil)
+			req = withUser(withURLParams(req, map[string]string{"subreddit": "golang", "user": "t2_abcdefg2"}), "t2_abcdefg2")
+			handler.RemoveModerator(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		remove := func(moderator, target string) *http.Request {
+			req := httptest.NewRequest(http.MethodDelete, "/r/golang/about/moderators/"+target, nil)
+			return withUser(withURLParams(req, map[string]string{"subreddit": "golang", "user": target}), moderator)
+		}
+		appointed := func(user string, since int64) {
+			m.
+				On("IsModerator", mock.Anything, "GoLang", user).Return(true, nil).Maybe().
+				On("GetAppointed", mock.Anything, "GoLang", user).Return(&since, nil).Maybe()
+		}
+
+		Convey("A moderator cannot remove one appointed earlier", func() {
+			appointed("t2_abcdefg3", 200)
+			appointed("t2_abcdefg4", 100)
+
+			handler.RemoveModerator(w, remove("t2_abcdefg3", "t2_abcdefg4"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"only moderators appointed earlier can remove the moderator"}]}`)
+		})
+
+		Convey("Moderators appointed at once cannot remove each other", func() {
+			appointed("t2_abcdefg3", 0)
+			appointed("t2_abcdefg4", 0)
+
+			handler.RemoveModerator(w, remove("t2_abcdefg3", "t2_abcdefg4"))
+
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("It fails if the target isn't a moderator", func() {
+			appointed("t2_abcdefg3", 100)
+			m.On("GetAppointed", mock.Anything, "GoLang", "t2_abcdefg4").Return((*int64)(nil), nil)
+
+			handler.RemoveModerator(w, remove("t2_abcdefg3", "t2_abcdefg4"))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		for _, c := range []struct {
+			name      string
+			moderator string
+			target    string
+		}{
+			{name: "A moderator removes one appointed later", moderator: "t2_abcdefg3", target: "t2_abcdefg4"},
+			{name: "A moderator steps down", moderator: "t2_abcdefg4", target: "t2_abcdefg4"},
+			{name: "The creator removes anyone", moderator: "t2_abcdefg2", target: "t2_abcdefg3"},
+		} {
+			c := c
+			Convey(c.name, func() {
+				appointed("t2_abcdefg3", 100)
+				appointed("t2_abcdefg4", 200)
+				m.On("GetAppointed", mock.Anything, "GoLang", "t2_abcdefg2").Return((*int64)(nil), nil).Maybe()
+				m.
+					On("RemoveModerator", mock.Anything, &protocol.ModAction{
+						Subreddit: "GoLang",
+						Moderator: c.moderator,
+						Action:    protocol.ModRemoveModerator,
+						Target:    c.target,
+						Created:   mockNow.Unix(),
+					}).Return(nil).
+					On("GetModerators", mock.Anything, "GoLang").Return([]string{"t2_abcdefg3"}, nil)
+
+				handler.RemoveModerator(w, remove(c.moderator, c.target))
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+		}
+	})
+}
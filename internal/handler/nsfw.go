@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"strings"
+	"unicode"
+
+	"nanoreddit/internal/validation"
+	"nanoreddit/pkg/protocol"
+)
+
+// nsfw tells why a post has to be NSFW. Ads are never placed next to NSFW posts, so the service doesn't rely on
+// authors only. It returns nil if nothing makes a post NSFW. A subreddit is optional.
+func (h *handler) nsfw(subreddit *protocol.Subreddit, title, content, domain string) *protocol.NSFWOverride {
+	if subreddit != nil && subreddit.NSFW {
+		return &protocol.NSFWOverride{Rule: protocol.NSFWRuleSubreddit, Match: subreddit.Name}
+	}
+	if d := validation.MatchDomain(h.cfg.NSFWDomains, domain); domain != "" && d != "" {
+		return &protocol.NSFWOverride{Rule: protocol.NSFWRuleDomain, Match: d}
+	}
+
+	// Keywords are matched by whole words, so "sex" doesn't match "Essex".
+	text := words(title + "\n" + content)
+	for _, keyword := range h.cfg.NSFWKeywords {
+		if k := words(keyword); strings.TrimSpace(k) != "" && strings.Contains(text, k) {
+			return &protocol.NSFWOverride{Rule: protocol.NSFWRuleKeyword, Match: strings.TrimSpace(k)}
+		}
+	}
+	return nil
+}
+
+// words brings a text to lowercase words separated and surrounded by single spaces.
+func words(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestNSFW(t *testing.T) {
+	Convey("Test nsfw", t, func() {
+		handler, err := mockHandler(&mock.Mock{})
+		So(err, ShouldBeNil)
+
+		Convey("A post of an NSFW subreddit is NSFW", func() {
+			So(handler.nsfw(&protocol.Subreddit{Name: "GoLang", NSFW: true}, "title", "", ""), ShouldResemble,
+				&protocol.NSFWOverride{Rule: protocol.NSFWRuleSubreddit, Match: "GoLang"})
+		})
+
+		Convey("A link to an NSFW domain or its subdomain is NSFW", func() {
+			So(handler.nsfw(&protocol.Subreddit{Name: "golang"}, "title", "", "de.pornhub.com"), ShouldResemble,
+				&protocol.NSFWOverride{Rule: protocol.NSFWRuleDomain, Match: "pornhub.com"})
+		})
+
+		Convey("Keywords are matched by whole words ignoring case", func() {
+			So(handler.nsfw(nil, "Some title", "Not safe: NSFW!", ""), ShouldResemble,
+				&protocol.NSFWOverride{Rule: protocol.NSFWRuleKeyword, Match: "nsfw"})
+			So(handler.nsfw(nil, "Pornography of violence", "", ""), ShouldBeNil)
+		})
+
+		Convey("A clean post isn't NSFW", func() {
+			So(handler.nsfw(&protocol.Subreddit{Name: "golang"}, "title", "content", "reddit.com"), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+var errPostNotFound = errors.New("the post is not found")
+
+// visible tells whether a post can be shown to the user of a request. Shadowbanned posts are shown to their authors
+// only.
+func visible(r *http.Request, post *protocol.Post) bool {
+	return !post.Shadowbanned || post.Author == middleware.User(r.Context())
+}
+
+// ownPost fetches a post referred by the URL and ensures it can be changed by the author of the request.
+// It renders a response and returns nil if the post cannot be changed.
+func (h *handler) ownPost(w http.ResponseWriter, r *http.Request, claimed string) *protocol.Post {
+	ctx := r.Context()
+
+	author := h.author(w, r, claimed)
+	if author == "" {
+		return nil
+	}
+
+	post, err := h.storage.GetPost(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return nil
+	}
+	if post == nil || post.Deleted {
+		h.render.NotFound(w, r, errPostNotFound)
+		return nil
+	}
+	if post.Author != author {
+		h.render.Forbidden(w, r, errors.New("only the author can change the post"))
+		return nil
+	}
+	return post
+}
+
+func (h *handler) Post(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	post, err := h.storage.GetPost(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if post == nil || !visible(r, post) {
+		h.render.NotFound(w, r, errPostNotFound)
+		return
+	}
+
+	render.Respond(w, r, post.Public())
+}
+
+func (h *handler) EditPost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.EditRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+
+	post := h.ownPost(w, r, request.Author)
+	if post == nil {
+		return
+	}
+	now := h.now()
+	if deadline := post.Created + int64(h.cfg.EditWindow.Seconds()); now.Unix() > deadline {
+		h.render.Forbidden(w, r, fmt.Errorf("the post can be edited only within %s after submission", h.cfg.EditWindow))
+		return
+	}
+	if request.Content != nil && len(*request.Content) != 0 && len(post.Link) != 0 {
+		h.render.InvalidRequest(w, r, protocol.ErrLinkContentConflict)
+		return
+	}
+
+	edit := protocol.PostEdited{
+		ID:      post.ID,
+		Title:   request.Title,
+		Content: request.Content,
+		Edited:  now.Unix(),
+	}
+	// The edited post is checked like a new one, so an edit doesn't sneak in what a submission couldn't.
+	edited := *post
+	if request.Title != nil {
+		edited.Title = *request.Title
+	}
+	if request.Content != nil {
+		edited.Content = *request.Content
+	}
+	if !post.NSFW {
+		subreddit, err := h.storage.GetSubreddit(ctx, post.Subreddit)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a subreddit")
+			h.render.InternalServerError(w, r, err)
+			return
+		}
+		edit.NSFWOverride = h.nsfw(subreddit, edited.Title, edited.Content, post.Domain)
+	}
+	var err error
+	if edit.Spam, err = h.spam.Score(ctx, &edited); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't score a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	// Even a rejected edit is published, so the decision can be audited. The materializer drops it.
+	if err := h.storage.EditPost(ctx, &edit); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish an edit")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if edit.Spam.Outcome == protocol.SpamReject {
+		h.render.Forbidden(w, r, errors.New("the edit looks like spam"))
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
+}
+
+func (h *handler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// A body is optional, since the author comes from the credential.
+	var request protocol.DeleteRequest
+	if r.ContentLength != 0 {
+		if err := h.binder.Bind(w, r, &request); err != nil {
+			return
+		}
+	}
+
+	post := h.ownPost(w, r, request.Author)
+	if post == nil {
+		return
+	}
+
+	deletion := protocol.PostDeleted{
+		ID:      post.ID,
+		Deleted: h.now().Unix(),
+	}
+	if err := h.storage.DeletePost(ctx, &deletion); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a deletion")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
+}
+
+func (h *handler) VotePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.VoteRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	voter := h.author(w, r, request.Author)
+	if voter == "" {
+		return
+	}
+
+	post, err := h.storage.GetPost(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if post == nil || post.Deleted {
+		h.render.NotFound(w, r, errPostNotFound)
+		return
+	}
+
+	vote := protocol.PostVoted{
+		PostID:    post.ID,
+		Voter:     voter,
+		Direction: *request.Direction,
+	}
+	if err := h.storage.VotePost(ctx, &vote); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a vote")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
+}
+
+// Duplicates lists other submissions of the link of a post, the newest first.
+func (h *handler) Duplicates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, ok := h.format(w, r, false)
+	if !ok {
+		return
+	}
+	page, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+
+	post, err := h.storage.GetPost(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if post == nil || post.Deleted {
+		h.render.NotFound(w, r, errPostNotFound)
+		return
+	}
+
+	duplicates, more := []protocol.Post{}, false
+	if post.Link != "" {
+		posts, next, err := h.storage.GetDuplicates(ctx, post.Link, post.ID, page)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch duplicates")
+			h.render.InternalServerError(w, r, err)
+			return
+		}
+		if posts != nil {
+			duplicates = posts
+		}
+		more = next
+	}
+
+	h.respondPosts(w, r, format, page, duplicates, more)
+}
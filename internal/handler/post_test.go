@@ -0,0 +1,603 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+func TestPost(t *testing.T) {
+	Convey("Test Post", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/posts/1a", nil)
+		req = withURLParams(req, map[string]string{"id": "1a"})
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return((*protocol.Post)(nil), errors.New("storage error"))
+
+			handler.Post(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post doesn't exist", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return((*protocol.Post)(nil), nil)
+
+			handler.Post(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":404,"description":"the post is not found"}]}`)
+		})
+
+		Convey("A spam decision isn't rendered", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{
+				ID:    "1a",
+				Title: "title",
+				Spam:  &protocol.SpamDecision{Outcome: protocol.SpamQueue, Score: 2},
+			}, nil)
+
+			handler.Post(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"id":"1a","title":"title","author":"","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":0}`)
+		})
+
+		Convey("A shadowbanned post is hidden from others", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Author: "t2_abcdefg2", Shadowbanned: true}, nil)
+
+			handler.Post(w, req.WithContext(middleware.WithUser(req.Context(), "t2_abcdefg1")))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A shadowbanned post is shown to its author as usual", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Author: "t2_abcdefg2", Shadowbanned: true}, nil)
+
+			handler.Post(w, req.WithContext(middleware.WithUser(req.Context(), "t2_abcdefg2")))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"id":"1a","title":"","author":"t2_abcdefg2","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":0}`)
+		})
+
+		Convey("A deleted post is still rendered", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{
+				ID:      "1a",
+				Title:   protocol.DeletedMarker,
+				Author:  protocol.DeletedMarker,
+				Content: protocol.DeletedMarker,
+				Deleted: true,
+			}, nil)
+
+			handler.Post(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"id":"1a","title":"[deleted]","author":"[deleted]","subreddit":"","content":"[deleted]","score":0,"promoted":false,"nsfw":false,"num_comments":0,"deleted":true}`)
+		})
+	})
+}
+
+func TestDuplicates(t *testing.T) {
+	Convey("Test Duplicates", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/duplicates/1a?page=1", nil)
+		req = withURLParams(req, map[string]string{"id": "1a"})
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a page is invalid", func() {
+			req := httptest.NewRequest(http.MethodGet, "/duplicates/1a?page=first", nil)
+
+			handler.Duplicates(w, withURLParams(req, map[string]string{"id": "1a"}))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post doesn't exist", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Deleted: true}, nil)
+
+			handler.Duplicates(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A self post has no duplicates", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Content: "content"}, nil)
+
+			handler.Duplicates(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `[]`)
+		})
+
+		Convey("It fails if duplicates cannot be fetched", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Link: "https://reddit.com/"}, nil).
+				On("GetDuplicates", mock.Anything, "https://reddit.com/", "1a", 1).Return([]protocol.Post(nil), false, errors.New("storage error"))
+
+			handler.Duplicates(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Link: "https://reddit.com/"}, nil).
+				On("GetDuplicates", mock.Anything, "https://reddit.com/", "1a", 1).Return([]protocol.Post{
+				{ID: "1c", Title: "title", Link: "https://reddit.com/", Subreddit: "programming", Domain: "reddit.com"},
+			}, false, nil)
+
+			handler.Duplicates(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `[{"id":"1c","title":"title","author":"","link":"https://reddit.com/","subreddit":"programming","domain":"reddit.com","score":0,"promoted":false,"nsfw":false,"num_comments":0}]`)
+		})
+	})
+}
+
+func TestEditPost(t *testing.T) {
+	Convey("Test EditPost", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPatch, "/posts/1a", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg2")
+		}
+		post := &protocol.Post{
+			ID:        "1a",
+			Title:     "title",
+			Author:    "t2_abcdefg2",
+			Subreddit: "golang",
+			Content:   "content",
+			Created:   mockNow.Add(-time.Minute).Unix(),
+		}
+		accepted := &protocol.SpamDecision{Outcome: protocol.SpamAccept}
+		golang := &protocol.Subreddit{Name: "golang"}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if an edit is empty", func() {
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post doesn't exist", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return((*protocol.Post)(nil), nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post is deleted", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Deleted: true}, nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a claimed author doesn't match the credential", func() {
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg3","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"the author doesn't match the credential"}]}`)
+		})
+
+		Convey("It fails if a post belongs to someone else", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Author: "t2_abcdefg3", Created: mockNow.Unix()}, nil)
+
+			handler.EditPost(w, newRequest(`{"title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"only the author can change the post"}]}`)
+		})
+
+		Convey("It fails if the grace window has expired", func() {
+			post.Created = mockNow.Add(-2 * time.Hour).Unix()
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"the post can be edited only within 1h0m0s after submission"}]}`)
+		})
+
+		Convey("It fails if content is added to a link post", func() {
+			post.Link = "https://reddit.com"
+			post.Content = ""
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","content":"new content"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a subreddit cannot be fetched", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("GetSubreddit", mock.Anything, "golang").Return((*protocol.Subreddit)(nil), errors.New("storage error"))
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an edit cannot be scored", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("GetSubreddit", mock.Anything, "golang").Return(golang, nil).
+				On("Score", mock.Anything, mock.Anything).Return((*protocol.SpamDecision)(nil), errors.New("storage error"))
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("GetSubreddit", mock.Anything, "golang").Return(golang, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("EditPost", mock.Anything, mock.Anything).Return(errors.New("storage error"))
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			title := "new title"
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("GetSubreddit", mock.Anything, "golang").Return(golang, nil).
+				On("Score", mock.Anything, mock.MatchedBy(func(edited *protocol.Post) bool {
+					return edited.ID == "1a" && edited.Title == title && edited.Content == "content"
+				})).Return(accepted, nil).
+				On("EditPost", mock.Anything, &protocol.PostEdited{
+					ID:     "1a",
+					Title:  &title,
+					Edited: mockNow.Unix(),
+					Spam:   accepted,
+				}).Return(nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a"}}`)
+		})
+
+		Convey("An edit mentioning an NSFW keyword makes a post NSFW", func() {
+			title := "new NSFW title"
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("GetSubreddit", mock.Anything, "golang").Return(golang, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("EditPost", mock.Anything, &protocol.PostEdited{
+					ID:           "1a",
+					Title:        &title,
+					Edited:       mockNow.Unix(),
+					NSFWOverride: &protocol.NSFWOverride{Rule: protocol.NSFWRuleKeyword, Match: "nsfw"},
+					Spam:         accepted,
+				}).Return(nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new NSFW title"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An edit of a post in an NSFW subreddit or of an NSFW domain makes it NSFW", func() {
+			post.Link = "https://pornhub.com/1"
+			post.Domain = "pornhub.com"
+			post.Content = ""
+
+			Convey("The subreddit comes first", func() {
+				m.
+					On("GetPost", mock.Anything, "1a").Return(post, nil).
+					On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang", NSFW: true}, nil).
+					On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+					On("EditPost", mock.Anything, mock.MatchedBy(func(edit *protocol.PostEdited) bool {
+						return *edit.NSFWOverride == protocol.NSFWOverride{Rule: protocol.NSFWRuleSubreddit, Match: "golang"}
+					})).Return(nil)
+
+				handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("The domain of the link is checked too", func() {
+				m.
+					On("GetPost", mock.Anything, "1a").Return(post, nil).
+					On("GetSubreddit", mock.Anything, "golang").Return(golang, nil).
+					On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+					On("EditPost", mock.Anything, mock.MatchedBy(func(edit *protocol.PostEdited) bool {
+						return *edit.NSFWOverride == protocol.NSFWOverride{Rule: protocol.NSFWRuleDomain, Match: "pornhub.com"}
+					})).Return(nil)
+
+				handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"new title"}`))
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+		})
+
+		Convey("An edit which looks like spam is published for the audit but refused", func() {
+			rejected := &protocol.SpamDecision{Outcome: protocol.SpamReject, Score: 3}
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("GetSubreddit", mock.Anything, "golang").Return(golang, nil).
+				On("Score", mock.Anything, mock.Anything).Return(rejected, nil).
+				On("EditPost", mock.Anything, mock.MatchedBy(func(edit *protocol.PostEdited) bool { return edit.Spam == rejected })).Return(nil)
+
+			handler.EditPost(w, newRequest(`{"author":"t2_abcdefg2","title":"casino"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"the edit looks like spam"}]}`)
+		})
+	})
+}
+
+func TestDeletePost(t *testing.T) {
+	Convey("Test DeletePost", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodDelete, "/posts/1a", bytes.NewBufferString(`{"author":"t2_abcdefg2"}`))
+		req.Header.Add("Content-Type", "application/json")
+		req = withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg2")
+
+		post := &protocol.Post{ID: "1a", Author: "t2_abcdefg2", Created: mockNow.Add(-24 * time.Hour).Unix()}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a post belongs to someone else", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Author: "t2_abcdefg3"}, nil)
+
+			handler.DeletePost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("DeletePost", mock.Anything, mock.Anything).Return(errors.New("storage error"))
+
+			handler.DeletePost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A body is optional", func() {
+			req := withUser(withURLParams(httptest.NewRequest(http.MethodDelete, "/posts/1a", nil), map[string]string{"id": "1a"}), "t2_abcdefg2")
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("DeletePost", mock.Anything, &protocol.PostDeleted{ID: "1a", Deleted: mockNow.Unix()}).Return(nil)
+
+			handler.DeletePost(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story (a post can be deleted any time)", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(post, nil).
+				On("DeletePost", mock.Anything, &protocol.PostDeleted{ID: "1a", Deleted: mockNow.Unix()}).Return(nil)
+
+			handler.DeletePost(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a"}}`)
+		})
+	})
+}
+
+func TestVotePost(t *testing.T) {
+	Convey("Test VotePost", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/posts/1a/vote", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg3")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a direction is invalid", func() {
+			for _, body := range []string{`{"author":"t2_abcdefg3"}`, `{"author":"t2_abcdefg3","dir":2}`} {
+				w := httptest.NewRecorder()
+				handler.VotePost(w, newRequest(body))
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			}
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post is deleted", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Deleted: true}, nil)
+
+			handler.VotePost(w, newRequest(`{"author":"t2_abcdefg3","dir":1}`))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("VotePost", mock.Anything, mock.Anything).Return(errors.New("storage error"))
+
+			handler.VotePost(w, newRequest(`{"author":"t2_abcdefg3","dir":1}`))
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story (a vote is withdrawn)", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("VotePost", mock.Anything, &protocol.PostVoted{PostID: "1a", Voter: "t2_abcdefg3", Direction: 0}).Return(nil)
+
+			handler.VotePost(w, newRequest(`{"author":"t2_abcdefg3","dir":0}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a"}}`)
+		})
+	})
+}
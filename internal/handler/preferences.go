@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+// filter derives a feed filter from preferences of the viewer. Anonymous viewers get the defaults. It renders
+// a response and returns false if preferences cannot be fetched.
+func (h *handler) filter(w http.ResponseWriter, r *http.Request, filter *protocol.FeedFilter) bool {
+	ctx := r.Context()
+
+	user := middleware.User(ctx)
+	if user == "" {
+		return true
+	}
+	prefs, err := h.storage.GetPreferences(ctx, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch preferences")
+		h.render.InternalServerError(w, r, err)
+		return false
+	}
+
+	filter.Over18 = prefs.Over18
+	// Hidden posts are looked up along with the posts they might hide.
+	filter.User = user
+	filter.HiddenSubreddits = prefs.HiddenSubreddits
+	return true
+}
+
+func (h *handler) Preferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+
+	prefs, err := h.storage.GetPreferences(ctx, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch preferences")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if prefs.HiddenSubreddits == nil {
+		prefs.HiddenSubreddits = []string{}
+	}
+
+	render.Respond(w, r, &protocol.PreferencesResponse{Data: *prefs})
+}
+
+func (h *handler) EditPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.PreferencesRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+
+	prefs, err := h.storage.GetPreferences(ctx, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch preferences")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if request.Over18 != nil {
+		prefs.Over18 = *request.Over18
+	}
+	if request.HiddenSubreddits != nil {
+		// NSFW subreddits may be hidden too.
+		names, ok := h.subreddits(w, r, request.HiddenSubreddits, true)
+		if !ok {
+			return
+		}
+		prefs.HiddenSubreddits = names
+	}
+	if err := h.storage.SetPreferences(ctx, user, prefs); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't save preferences")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.Preferences(w, r)
+}
+
+func (h *handler) SavePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+	post := h.livePost(w, r)
+	if post == nil {
+		return
+	}
+
+	if err := h.storage.SavePost(ctx, user, post.ID, h.now().Unix()); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't save a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+// UnsavePost drops a post from saved ones. The post may have been deleted since it was saved.
+func (h *handler) UnsavePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+
+	if err := h.storage.UnsavePost(ctx, user, chi.URLParam(r, "id")); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't unsave a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+// HidePost leaves a post out of feeds viewed by the user.
+func (h *handler) HidePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+	post := h.livePost(w, r)
+	if post == nil {
+		return
+	}
+
+	if err := h.storage.HidePost(ctx, user, post.ID); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't hide a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+func (h *handler) UnhidePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+
+	if err := h.storage.UnhidePost(ctx, user, chi.URLParam(r, "id")); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't unhide a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
+
+// Saved lists posts saved by a user, the most recently saved first. Saved posts are private to the user.
+func (h *handler) Saved(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, ok := h.format(w, r, false)
+	if !ok {
+		return
+	}
+	page, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+
+	user := h.author(w, r, chi.URLParam(r, "id"))
+	if user == "" {
+		return
+	}
+
+	posts, more, err := h.storage.GetSaved(ctx, user, page)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch saved posts")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if posts == nil {
+		posts = []protocol.Post{}
+	}
+
+	h.respondPosts(w, r, format, page, posts, more)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestEditPreferences(t *testing.T) {
+	Convey("Test EditPreferences", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPatch, "/prefs", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(req, "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if nothing changes", func() {
+			handler.EditPreferences(w, newRequest(`{}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a hidden subreddit doesn't exist", func() {
+			m.
+				On("GetPreferences", mock.Anything, "t2_abcdefg2").Return(&protocol.Preferences{}, nil).
+				On("GetSubreddit", mock.Anything, "nowhere").Return((*protocol.Subreddit)(nil), nil)
+
+			handler.EditPreferences(w, newRequest(`{"hidden_subreddits":["nowhere"]}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			prefs := &protocol.Preferences{Over18: true, HiddenSubreddits: []string{"GoneWild"}}
+			m.
+				On("GetPreferences", mock.Anything, "t2_abcdefg2").Return(&protocol.Preferences{HiddenSubreddits: []string{"Games"}}, nil).Once().
+				On("GetSubreddit", mock.Anything, "gonewild").Return(&protocol.Subreddit{Name: "GoneWild", NSFW: true}, nil).
+				On("SetPreferences", mock.Anything, "t2_abcdefg2", prefs).Return(nil).
+				On("GetPreferences", mock.Anything, "t2_abcdefg2").Return(prefs, nil).Once()
+
+			handler.EditPreferences(w, newRequest(`{"over_18":true,"hidden_subreddits":["gonewild"]}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"over_18":true,"hidden_subreddits":["GoneWild"]}}`)
+		})
+	})
+}
+
+func TestSaveAndHide(t *testing.T) {
+	Convey("Test saving and hiding posts", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(path string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			return withUser(withURLParams(req, map[string]string{"id": "1a"}), "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("A deleted post cannot be saved", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a", Deleted: true}, nil)
+
+			handler.SavePost(w, newRequest("/posts/1a/save"))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A user saves a post", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("SavePost", mock.Anything, "t2_abcdefg2", "1a", mockNow.Unix()).Return(nil)
+
+			handler.SavePost(w, newRequest("/posts/1a/save"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A user hides a post", func() {
+			m.
+				On("GetPost", mock.Anything, "1a").Return(&protocol.Post{ID: "1a"}, nil).
+				On("HidePost", mock.Anything, "t2_abcdefg2", "1a").Return(nil)
+
+			handler.HidePost(w, newRequest("/posts/1a/hide"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSaved(t *testing.T) {
+	Convey("Test Saved", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("Saved posts are private", func() {
+			req := httptest.NewRequest(http.MethodGet, "/user/t2_abcdefg3/saved", nil)
+			handler.Saved(w, withUser(withURLParams(req, map[string]string{"id": "t2_abcdefg3"}), "t2_abcdefg2"))
+
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetSaved", mock.Anything, "t2_abcdefg2", 1).Return([]protocol.Post{{ID: "1a", Title: "title", Subreddit: "GoLang"}}, false, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/user/t2_abcdefg2/saved?page=1", nil)
+			handler.Saved(w, withUser(withURLParams(req, map[string]string{"id": "t2_abcdefg2"}), "t2_abcdefg2"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `[{"id":"1a","title":"title","author":"","subreddit":"GoLang","score":0,"promoted":false,"nsfw":false,"num_comments":0}]`)
+		})
+	})
+}
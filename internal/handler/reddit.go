@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// redditListing puts a page of posts into a listing of the Reddit API. More tells whether the listing has more
+// pages, and names are names of the authors by their identifiers.
+func (h *handler) redditListing(page int, posts []protocol.Post, more bool, names map[string]string) *protocol.RedditListing {
+	children := make([]protocol.RedditThing, 0, len(posts))
+	for i := range posts {
+		children = append(children, protocol.RedditThing{Kind: protocol.RedditKindLink, Data: h.redditLink(&posts[i], names)})
+	}
+
+	listing := &protocol.RedditListing{
+		Kind: protocol.RedditKindListing,
+		Data: protocol.RedditListingData{Children: children, Dist: len(children)},
+	}
+	if more {
+		after := strconv.Itoa(page + 1)
+		listing.Data.After = &after
+	}
+	if page > 0 {
+		before := strconv.Itoa(page - 1)
+		listing.Data.Before = &before
+	}
+	return listing
+}
+
+// redditLink maps a post to the names of fields of the Reddit API. Self posts link to themselves, and authors who
+// don't exist anymore are deleted, like they are there.
+func (h *handler) redditLink(post *protocol.Post, names map[string]string) protocol.RedditLink {
+	link := protocol.RedditLink{
+		ID:                    post.ID,
+		Name:                  protocol.RedditKindLink + "_" + post.ID,
+		Title:                 post.Title,
+		Author:                "[deleted]",
+		AuthorFullname:        post.Author,
+		Subreddit:             post.Subreddit,
+		SubredditNamePrefixed: "r/" + post.Subreddit,
+		URL:                   post.Link,
+		Permalink:             "/posts/" + post.ID,
+		Domain:                post.Domain,
+		Selftext:              post.Content,
+		Score:                 post.Score,
+		Ups:                   post.Score,
+		NumComments:           post.NumComments,
+		Over18:                post.NSFW,
+		Promoted:              post.Promoted,
+		Created:               float64(post.Created),
+		CreatedUTC:            float64(post.Created),
+		Edited:                false,
+	}
+	if post.Link == "" {
+		link.IsSelf = true
+		link.URL = h.permalink(post)
+		link.Domain = "self." + post.Subreddit
+	}
+	if name, ok := names[post.Author]; ok {
+		link.Author = name
+	}
+	if post.Flair != "" {
+		flair := post.Flair
+		link.LinkFlairText = &flair
+	}
+	if post.Edited != 0 {
+		link.Edited = float64(post.Edited)
+	}
+	return link
+}
+
+// authorNames returns names of the authors of posts by their identifiers.
+func (h *handler) authorNames(ctx context.Context, posts []protocol.Post) (map[string]string, error) {
+	ids := make([]string, 0, len(posts))
+	seen := make(map[string]bool, len(posts))
+	for i := range posts {
+		if author := posts[i].Author; author != "" && !seen[author] {
+			seen[author] = true
+			ids = append(ids, author)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return h.storage.GetUsernames(ctx, ids)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestRedditListing(t *testing.T) {
+	Convey("Test listings in the format of the Reddit API", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		posts := []protocol.Post{
+			{ID: "1a", Title: "Ask me anything", Author: "t2_abcdefg2", Subreddit: "golang", Content: "I'm a gopher", Flair: "AMA", Created: 1612000000, Edited: 1612001000, Score: 5, NumComments: 2},
+			{ID: "1b", Title: "Go 1.16", Author: "t2_abcdefg3", Link: "https://golang.org/doc/go1.16", Domain: "golang.org", Subreddit: "golang", NSFW: true, Created: 1611000000, Score: 3},
+		}
+
+		Convey("It fails if a format is unknown", func() {
+			handler.Feed(w, httptest.NewRequest(http.MethodGet, "/feed?format=old", nil))
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Listings other than feeds aren't syndicated", func() {
+			req := withURLParams(httptest.NewRequest(http.MethodGet, "/user/t2_abcdefg2/submitted.rss", nil), map[string]string{"id": "t2_abcdefg2"})
+
+			handler.Submitted(w, withURLFormat(req, "rss"))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A feed is chosen by a parameter and paged by cursors", func() {
+			m.
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 3, Viewer: "ip:192.0.2.1"}).Return(posts, true, nil).
+				On("GetUsernames", mock.Anything, []string{"t2_abcdefg2", "t2_abcdefg3"}).Return(map[string]string{"t2_abcdefg2": "gopher"}, nil)
+
+			handler.Feed(w, httptest.NewRequest(http.MethodGet, "/feed?format=reddit&after=3", nil))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"kind":"Listing","data":{"after":"4","before":"2","dist":2,"children":[
+				{"kind":"t3","data":{"id":"1a","name":"t3_1a","title":"Ask me anything","author":"gopher","author_fullname":"t2_abcdefg2","subreddit":"golang",
+					"subreddit_name_prefixed":"r/golang","url":"https://nanoreddit.example/posts/1a","permalink":"/posts/1a",
+					"domain":"self.golang","is_self":true,"selftext":"I'm a gopher","link_flair_text":"AMA","score":5,"ups":5,
+					"num_comments":2,"over_18":false,"promoted":false,"created":1612000000,"created_utc":1612000000,"edited":1612001000}},
+				{"kind":"t3","data":{"id":"1b","name":"t3_1b","title":"Go 1.16","author":"[deleted]","author_fullname":"t2_abcdefg3","subreddit":"golang",
+					"subreddit_name_prefixed":"r/golang","url":"https://golang.org/doc/go1.16","permalink":"/posts/1b",
+					"domain":"golang.org","is_self":false,"selftext":"","link_flair_text":null,"score":3,"ups":3,
+					"num_comments":0,"over_18":true,"promoted":false,"created":1611000000,"created_utc":1611000000,"edited":false}}
+			]}}`)
+		})
+
+		Convey("A page which isn't full is the last one", func() {
+			m.
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Page: 1, Viewer: "ip:192.0.2.1"}).Return(posts[:1], false, nil).
+				On("GetUsernames", mock.Anything, []string{"t2_abcdefg2"}).Return(map[string]string{"t2_abcdefg2": "gopher"}, nil)
+
+			handler.Feed(w, httptest.NewRequest(http.MethodGet, "/feed?format=reddit&page=1", nil))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			var listing protocol.RedditListing
+			So(json.Unmarshal(w.Body.Bytes(), &listing), ShouldBeNil)
+			So(listing.Data.After, ShouldBeNil)
+			So(*listing.Data.Before, ShouldEqual, "0")
+			So(listing.Data.Children[0].Data.Author, ShouldEqual, "gopher")
+		})
+
+		Convey("It fails if names of authors cannot be fetched", func() {
+			m.
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Viewer: "ip:192.0.2.1"}).Return(posts, false, nil).
+				On("GetUsernames", mock.Anything, []string{"t2_abcdefg2", "t2_abcdefg3"}).Return(map[string]string(nil), errors.New("storage error"))
+
+			handler.Feed(w, httptest.NewRequest(http.MethodGet, "/feed?format=reddit", nil))
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Submitted posts are chosen by the suffix", func() {
+			req := withURLParams(httptest.NewRequest(http.MethodGet, "/user/t2_abcdefg2/submitted.json", nil), map[string]string{"id": "t2_abcdefg2"})
+
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{ID: "t2_abcdefg2"}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", false, 0).Return(([]protocol.Post)(nil), false, nil)
+
+			handler.Submitted(w, withURLFormat(req, "json"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"kind":"Listing","data":{"after":null,"before":null,"dist":0,"children":[]}}`)
+		})
+	})
+}
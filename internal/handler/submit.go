@@ -1,13 +1,32 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/go-chi/render"
 	"github.com/rs/zerolog"
 
+	"nanoreddit/internal/validation"
 	"nanoreddit/pkg/protocol"
 )
 
+// duplicate looks for a post of the same link submitted to the same subreddit within the window. It returns nil
+// if there is no such post. It renders a response and returns false if the lookup fails.
+func (h *handler) duplicate(w http.ResponseWriter, r *http.Request, post *protocol.Post) (*protocol.Post, bool) {
+	ctx := r.Context()
+
+	since := post.Created - int64(h.cfg.DuplicateWindow.Seconds())
+	duplicate, err := h.storage.GetDuplicate(ctx, post.Subreddit, post.Link, since)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch duplicates")
+		h.render.InternalServerError(w, r, err)
+		return nil, false
+	}
+	return duplicate, true
+}
+
 func (h *handler) Submit(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -15,9 +34,101 @@ func (h *handler) Submit(w http.ResponseWriter, r *http.Request) {
 	if err := h.binder.Bind(w, r, &request); err != nil {
 		return
 	}
+	author := h.author(w, r, request.Author)
+	if author == "" {
+		return
+	}
+
+	// These fields are maintained by the service, so clients aren't allowed to populate them.
+	post := request.Post
+	post.ID = ""
+	post.Author = author
+	post.Created = h.now().Unix()
+	post.Edited = 0
+	post.Score = 0
+	post.Deleted = false
+	post.Removed = false
+	post.Approved = false
+	post.NumComments = 0
+	post.Domain = ""
+	post.Promoted = false
+	post.HouseAd = false
+	post.Campaign = ""
+	post.Shadowbanned = false
+
+	if post.Link != "" {
+		link, err := validation.NormalizeLink(post.Link)
+		if err != nil {
+			h.render.InvalidRequest(w, r, err)
+			return
+		}
+		if err := h.domains.Check(link.Domain); err != nil {
+			h.render.InvalidRequest(w, r, err)
+			return
+		}
+		post.Link = link.URL
+		post.Domain = link.Domain
+	}
 
-	if err := h.storage.AddPost(ctx, &request.Post); err != nil {
+	subreddit, err := h.storage.GetSubreddit(ctx, post.Subreddit)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a subreddit")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if subreddit == nil {
+		h.render.InvalidRequest(w, r, errors.New("the subreddit doesn't exist"))
+		return
+	}
+	if !subreddit.Allows(&post) {
+		h.render.InvalidRequest(w, r, fmt.Errorf("the subreddit allows only %s posts", subreddit.SubmissionType))
+		return
+	}
+	post.Subreddit = subreddit.Name
+
+	// A shadowbanned author doesn't notice the ban. The materializer keeps their posts out of listings.
+	ban, ok := h.activeBan(w, r, subreddit.Name, author)
+	if !ok {
+		return
+	}
+	if ban != nil && !ban.Shadow {
+		h.render.Forbidden(w, r, banError(ban))
+		return
+	}
+
+	post.NSFWOverride = nil
+	if !post.NSFW {
+		if post.NSFWOverride = h.nsfw(subreddit, post.Title, post.Content, post.Domain); post.NSFWOverride != nil {
+			post.NSFW = true
+		}
+	}
+
+	if post.Link != "" && !request.Resubmit && h.cfg.DuplicateWindow > 0 {
+		duplicate, ok := h.duplicate(w, r, &post)
+		if !ok {
+			return
+		}
+		if duplicate != nil {
+			h.render.AlreadySubmitted(w, r, errors.New("the link has already been submitted to the subreddit"), protocol.PostRef{ID: duplicate.ID})
+			return
+		}
+	}
+
+	// Even a rejected post is published, so the decision can be audited. The materializer drops it.
+	if post.Spam, err = h.spam.Score(ctx, &post); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't score a post")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if err := h.storage.AddPost(ctx, &post); err != nil {
 		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't publish a request")
 		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if post.Spam.Outcome == protocol.SpamReject {
+		h.render.Forbidden(w, r, errors.New("the post looks like spam"))
+		return
 	}
+
+	render.Respond(w, r, &protocol.SubmitResponse{Data: protocol.PostRef{ID: post.ID}})
 }
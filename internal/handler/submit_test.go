@@ -7,10 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/smartystreets/assertions"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
 )
 
 func TestSubmit(t *testing.T) {
@@ -24,17 +27,24 @@ func TestSubmit(t *testing.T) {
 			"title": "title 1",
 			"author": "t2_abcdefg2",
 			"link": "https://reddit.com/3",
-			"subreddit": "subreddit 4",
+			"subreddit": "golang",
 			"score": 123,
-			"promoted": false,
+			"promoted": true,
 			"nsfw": false
 		}`
 		req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(body))
 		req.Header.Add("Content-Type", "application/json")
+		req = withUser(req, "t2_abcdefg2")
 
 		handler, err := mockHandler(m)
 		So(err, ShouldBeNil)
 
+		accepted := &protocol.SpamDecision{Outcome: protocol.SpamAccept}
+
+		// Nobody is banned here, bans are tested separately.
+		m.
+			On("GetBan", mock.Anything, mock.Anything, "t2_abcdefg2").Return((*protocol.Ban)(nil), nil).Maybe()
+
 		Convey("It fails if binding has been failed", func() {
 			handler.binder = &mockBinder{m: m}
 
@@ -59,8 +69,199 @@ func TestSubmit(t *testing.T) {
 			So(string(resBbody), assertions.ShouldEqualJSON, `{"message":"hello"}`)
 		})
 
+		Convey("Invalid fields are reported one by one", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{
+				"title": "title 1",
+				"author": "somebody",
+				"link": "reddit",
+				"subreddit": "golang"
+			}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.Submit(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[
+				{"description":"author has an invalid format","code":400,"reason":"invalid_format","field":"author","rule":"author"},
+				{"description":"link has an invalid format","code":400,"reason":"invalid_format","field":"link","rule":"link"}
+			]}`)
+		})
+
+		Convey("It fails if a post has both a link and content", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{
+				"title": "title 1",
+				"link": "https://reddit.com/3",
+				"content": "content",
+				"subreddit": "golang"
+			}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.Submit(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"content cannot be populated along with link","code":400,"reason":"mutually_exclusive","field":"content","rule":"excluded_with","param":"link"}]}`)
+		})
+
+		Convey("It fails if a request is anonymous", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an author doesn't match the credential", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.Submit(w, withUser(req, "t2_abcdefg3"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a subreddit cannot be fetched", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return((*protocol.Subreddit)(nil), errors.New("storage error"))
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a subreddit doesn't exist", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return((*protocol.Subreddit)(nil), nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"the subreddit doesn't exist","code":400}]}`)
+		})
+
+		Convey("It fails if a subreddit doesn't allow the kind of a post", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang", SubmissionType: protocol.SubmissionTypeSelf}, nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"the subreddit allows only self posts","code":400}]}`)
+		})
+
+		Convey("A post gets the canonical name and NSFW flag of a subreddit", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang", NSFW: true, SubmissionType: protocol.SubmissionTypeLink}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("AddPost", mock.Anything, &protocol.Post{
+					Title:     "title 1",
+					Author:    "t2_abcdefg2",
+					Link:      "https://reddit.com/3",
+					Subreddit: "GoLang",
+					Domain:    "reddit.com",
+					NSFW:      true,
+					Created:   mockNow.Unix(),
+					Spam:      accepted,
+					NSFWOverride: &protocol.NSFWOverride{
+						Rule:  protocol.NSFWRuleSubreddit,
+						Match: "GoLang",
+					},
+				}).
+				Return(nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a post cannot be scored", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return((*protocol.SpamDecision)(nil), errors.New("storage error"))
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A rejected post is published but the request fails", func() {
+			rejected := &protocol.SpamDecision{
+				Outcome: protocol.SpamReject,
+				Score:   2,
+				Reasons: []protocol.SpamReason{{Check: "blocklist", Score: 2, Description: "the post contains the blocked keyword \"casino\""}},
+			}
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(rejected, nil).
+				On("AddPost", mock.Anything, mock.MatchedBy(func(post *protocol.Post) bool { return post.Spam == rejected })).Return(nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"the post looks like spam","code":403}]}`)
+		})
+
+		Convey("A queued post is accepted", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(&protocol.SpamDecision{Outcome: protocol.SpamQueue, Score: 1}, nil).
+				On("AddPost", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { args.Get(1).(*protocol.Post).ID = "1a" }).Return(nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a"}}`)
+		})
+
 		Convey("It fails if an storage has been failed", func() {
 			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
 				On("AddPost", mock.Anything, mock.Anything).Return(errors.New("storage error"))
 
 			handler.Submit(w, req)
@@ -76,17 +277,208 @@ func TestSubmit(t *testing.T) {
 
 		Convey("Successful story", func() {
 			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("AddPost", mock.Anything, &protocol.Post{
+					Title:     "title 1",
+					Author:    "t2_abcdefg2",
+					Link:      "https://reddit.com/3",
+					Subreddit: "golang",
+					Domain:    "reddit.com",
+					Created:   mockNow.Unix(),
+					Spam:      accepted,
+				}).
+				Run(func(args mock.Arguments) { args.Get(1).(*protocol.Post).ID = "1a" }).
+				Return(nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1a"}}`)
+		})
+
+		Convey("It fails if a link has recently been submitted to the subreddit", func() {
+			handler.cfg.DuplicateWindow = 24 * time.Hour
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil).
+				On("GetDuplicate", mock.Anything, "GoLang", "https://reddit.com/3", mockNow.Unix()-24*60*60).Return(&protocol.Post{ID: "1b", Subreddit: "golang"}, nil)
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"the link has already been submitted to the subreddit","code":409,"reason":"already_submitted"}],"data":{"id":"1b"}}`)
+		})
+
+		Convey("It fails if duplicates cannot be fetched", func() {
+			handler.cfg.DuplicateWindow = 24 * time.Hour
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("GetDuplicate", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return((*protocol.Post)(nil), errors.New("storage error"))
+
+			handler.Submit(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A link can be submitted to another subreddit", func() {
+			handler.cfg.DuplicateWindow = 24 * time.Hour
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("GetDuplicate", mock.Anything, "golang", "https://reddit.com/3", mockNow.Unix()-24*60*60).Return((*protocol.Post)(nil), nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
 				On("AddPost", mock.Anything, mock.Anything).Return(nil)
 
 			handler.Submit(w, req)
 
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A link can be resubmitted on purpose", func() {
+			handler.cfg.DuplicateWindow = 24 * time.Hour
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{
+				"title": "title 1",
+				"link": "https://reddit.com/3",
+				"subreddit": "golang",
+				"resubmit": true
+			}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("AddPost", mock.Anything, mock.Anything).Return(nil)
+
+			handler.Submit(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A link is normalized and its domain is kept", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{
+				"title": "title 1",
+				"link": "HTTPS://WWW.Reddit.com:443?utm_source=x&b=2&a=1",
+				"subreddit": "golang"
+			}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("AddPost", mock.Anything, &protocol.Post{
+					Title:     "title 1",
+					Author:    "t2_abcdefg2",
+					Link:      "https://www.reddit.com/?a=1&b=2",
+					Subreddit: "golang",
+					Domain:    "reddit.com",
+					Created:   mockNow.Unix(),
+					Spam:      accepted,
+				}).
+				Return(nil)
+
+			handler.Submit(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a link points to a private address", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{
+				"title": "title 1",
+				"link": "http://127.0.0.1:8080/admin",
+				"subreddit": "golang"
+			}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.Submit(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"link should point to a public host","code":400,"reason":"invalid_value","field":"link","rule":"public"}]}`)
+		})
+
+		Convey("It fails if a domain is denied", func() {
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(`{
+				"title": "title 1",
+				"link": "https://www.Evil.com/",
+				"subreddit": "golang"
+			}`))
+			req.Header.Add("Content-Type", "application/json")
+
+			handler.Submit(w, withUser(req, "t2_abcdefg2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"description":"links to evil.com aren't allowed","code":400,"reason":"domain_not_allowed","field":"link","rule":"domain","param":"evil.com"}]}`)
+		})
+
+		Convey("Fields maintained by the service are overwritten and an author comes from the credential", func() {
+			const body = `{
+				"id": "zzz",
+				"title": "title 1",
+				"subreddit": "golang",
+				"created": 1,
+				"edited": 2,
+				"deleted": true,
+				"domain": "evil.com"
+			}`
+			req := httptest.NewRequest(http.MethodPost, "/submit", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			req = withUser(req, "t2_abcdefg2")
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("Score", mock.Anything, mock.Anything).Return(accepted, nil).
+				On("AddPost", mock.Anything, &protocol.Post{
+					Title:     "title 1",
+					Author:    "t2_abcdefg2",
+					Subreddit: "golang",
+					Created:   mockNow.Unix(),
+					Spam:      accepted,
+				}).
+				Run(func(args mock.Arguments) { args.Get(1).(*protocol.Post).ID = "1b" }).
+				Return(nil)
+
+			handler.Submit(w, req)
+
 			resp := w.Result()
 			defer resp.Body.Close()
 			So(resp.StatusCode, ShouldEqual, http.StatusOK)
 			So(m.AssertExpectations(t), ShouldBeTrue)
 			resBbody, err := ioutil.ReadAll(resp.Body)
 			So(err, ShouldBeNil)
-			So(resBbody, ShouldBeEmpty)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"1b"}}`)
 		})
 	})
 }
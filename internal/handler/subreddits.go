@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+var errSubredditNotFound = errors.New("the subreddit is not found")
+
+// subreddit fetches a subreddit by a name. It renders a response and returns nil if there is no such subreddit.
+func (h *handler) subreddit(w http.ResponseWriter, r *http.Request, name string) *protocol.Subreddit {
+	ctx := r.Context()
+
+	subreddit, err := h.storage.GetSubreddit(ctx, name)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a subreddit")
+		h.render.InternalServerError(w, r, err)
+		return nil
+	}
+	if subreddit == nil {
+		h.render.NotFound(w, r, errSubredditNotFound)
+		return nil
+	}
+	return subreddit
+}
+
+func (h *handler) CreateSubreddit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.SubredditRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	creator := h.author(w, r, request.Creator)
+	if creator == "" {
+		return
+	}
+
+	subreddit := request.Subreddit
+	subreddit.Creator = creator
+	subreddit.Created = h.now().Unix()
+
+	ok, err := h.storage.AddSubreddit(ctx, &subreddit)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't save a subreddit")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		h.render.Conflict(w, r, errors.New("the subreddit already exists"))
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubredditResponse{Data: subreddit})
+}
+
+func (h *handler) Subreddit(w http.ResponseWriter, r *http.Request) {
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubredditResponse{Data: *subreddit})
+}
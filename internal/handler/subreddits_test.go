@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestCreateSubreddit(t *testing.T) {
+	Convey("Test CreateSubreddit", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/subreddits", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(req, "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a name is invalid", func() {
+			for _, name := range []string{"go", "golang-nuts", "a_very_long_subreddit_name"} {
+				w := httptest.NewRecorder()
+				handler.CreateSubreddit(w, newRequest(`{"name":"`+name+`","creator":"t2_abcdefg2"}`))
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			}
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a submission type is unknown", func() {
+			handler.CreateSubreddit(w, newRequest(`{"name":"golang","creator":"t2_abcdefg2","submission_type":"image"}`))
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a name is taken", func() {
+			m.
+				On("AddSubreddit", mock.Anything, mock.Anything).Return(false, nil)
+
+			handler.CreateSubreddit(w, newRequest(`{"name":"golang","creator":"t2_abcdefg2"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":409,"description":"the subreddit already exists"}]}`)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("AddSubreddit", mock.Anything, mock.Anything).Return(false, errors.New("storage error"))
+
+			handler.CreateSubreddit(w, newRequest(`{"name":"golang","creator":"t2_abcdefg2"}`))
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("AddSubreddit", mock.Anything, &protocol.Subreddit{
+					Name:           "Go_Lang",
+					Title:          "The Go Programming Language",
+					NSFW:           true,
+					SubmissionType: protocol.SubmissionTypeAny,
+					Creator:        "t2_abcdefg2",
+					Created:        mockNow.Unix(),
+				}).Return(true, nil)
+
+			handler.CreateSubreddit(w, newRequest(`{"name":"Go_Lang","title":"The Go Programming Language","nsfw":true,"creator":"t2_abcdefg2"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"name":"Go_Lang","title":"The Go Programming Language","description":"","nsfw":true,"submission_type":"any","creator":"t2_abcdefg2","created":1612008000}}`)
+		})
+	})
+}
+
+func TestSubreddit(t *testing.T) {
+	Convey("Test Subreddit", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/r/golang/about", nil)
+		req = withURLParams(req, map[string]string{"subreddit": "golang"})
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a subreddit doesn't exist", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return((*protocol.Subreddit)(nil), nil)
+
+			handler.Subreddit(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang", SubmissionType: protocol.SubmissionTypeSelf}, nil)
+
+			handler.Subreddit(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"data":{"name":"golang","title":"","description":"","nsfw":false,"submission_type":"self","creator":""}}`)
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func (h *handler) Subscriptions(w http.ResponseWriter, r *http.Request) {
+	user := h.author(w, r, chi.URLParam(r, "id"))
+	if user == "" {
+		return
+	}
+
+	h.respondSubscriptions(w, r, user)
+}
+
+// respondSubscriptions renders subreddits the user subscribes to.
+func (h *handler) respondSubscriptions(w http.ResponseWriter, r *http.Request, user string) {
+	ctx := r.Context()
+
+	subreddits, err := h.storage.GetSubscriptions(ctx, user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch subscriptions")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.SubscriptionsResponse{Data: subreddits})
+}
+
+func (h *handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.SubscribeRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	user := h.author(w, r, chi.URLParam(r, "id"))
+	if user == "" {
+		return
+	}
+	subreddit := h.subreddit(w, r, request.Subreddit)
+	if subreddit == nil {
+		return
+	}
+
+	if err := h.storage.Subscribe(ctx, user, subreddit.Name); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't subscribe")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.respondSubscriptions(w, r, user)
+}
+
+func (h *handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := h.author(w, r, chi.URLParam(r, "id"))
+	if user == "" {
+		return
+	}
+
+	subreddit := h.subreddit(w, r, chi.URLParam(r, "subreddit"))
+	if subreddit == nil {
+		return
+	}
+
+	if err := h.storage.Unsubscribe(ctx, user, subreddit.Name); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't unsubscribe")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	h.respondSubscriptions(w, r, user)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestSubscriptions(t *testing.T) {
+	Convey("Test subscriptions", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("Subscriptions", func() {
+			req := httptest.NewRequest(http.MethodGet, "/users/t2_abcdefg2/subscriptions", nil)
+			req = withURLParams(req, map[string]string{"id": "t2_abcdefg2"})
+
+			Convey("It fails if a user isn't authenticated", func() {
+				handler.Subscriptions(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("Only the user sees their subscriptions", func() {
+				handler.Subscriptions(w, withUser(req, "t2_abcdefg3"))
+
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			req = withUser(req, "t2_abcdefg2")
+
+			Convey("It fails if an storage has been failed", func() {
+				m.
+					On("GetSubscriptions", mock.Anything, "t2_abcdefg2").Return([]string(nil), errors.New("storage error"))
+
+				handler.Subscriptions(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("Successful story", func() {
+				m.
+					On("GetSubscriptions", mock.Anything, "t2_abcdefg2").Return([]string{"GoLang", "rust"}, nil)
+
+				handler.Subscriptions(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				So(w.Body.String(), assertions.ShouldEqualJSON, `{"data":["GoLang","rust"]}`)
+			})
+		})
+
+		Convey("Subscribe", func() {
+			req := httptest.NewRequest(http.MethodPost, "/users/t2_abcdefg2/subscriptions", bytes.NewBufferString(`{"subreddit":"golang"}`))
+			req.Header.Add("Content-Type", "application/json")
+			req = withUser(withURLParams(req, map[string]string{"id": "t2_abcdefg2"}), "t2_abcdefg2")
+
+			Convey("It fails if a subreddit doesn't exist", func() {
+				m.
+					On("GetSubreddit", mock.Anything, "golang").Return((*protocol.Subreddit)(nil), nil)
+
+				handler.Subscribe(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("It fails if an storage has been failed", func() {
+				m.
+					On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil).
+					On("Subscribe", mock.Anything, "t2_abcdefg2", "GoLang").Return(errors.New("storage error"))
+
+				handler.Subscribe(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("Successful story", func() {
+				m.
+					On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil).
+					On("Subscribe", mock.Anything, "t2_abcdefg2", "GoLang").Return(nil).
+					On("GetSubscriptions", mock.Anything, "t2_abcdefg2").Return([]string{"GoLang"}, nil)
+
+				handler.Subscribe(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				So(w.Body.String(), assertions.ShouldEqualJSON, `{"data":["GoLang"]}`)
+			})
+		})
+
+		Convey("Unsubscribe", func() {
+			req := httptest.NewRequest(http.MethodDelete, "/users/t2_abcdefg2/subscriptions/golang", nil)
+			req = withUser(withURLParams(req, map[string]string{"id": "t2_abcdefg2", "subreddit": "golang"}), "t2_abcdefg2")
+
+			Convey("Successful story", func() {
+				m.
+					On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "GoLang"}, nil).
+					On("Unsubscribe", mock.Anything, "t2_abcdefg2", "GoLang").Return(nil).
+					On("GetSubscriptions", mock.Anything, "t2_abcdefg2").Return([]string{}, nil)
+
+				handler.Unsubscribe(w, req)
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				So(w.Body.String(), assertions.ShouldEqualJSON, `{"data":[]}`)
+			})
+		})
+	})
+}
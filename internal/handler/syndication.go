@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func (h *handler) respondXML(w http.ResponseWriter, r *http.Request, contentType string, v interface{}) {
+	ctx := r.Context()
+
+	blob, err := xml.Marshal(v)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't marshal a feed")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(append([]byte(xml.Header), blob...)); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Couldn't write a feed")
+	}
+}
+
+// listingTitle and listingURL describe the HTML-less page a feed stands for.
+func (h *handler) listingTitle(query *protocol.FeedQuery) string {
+	switch {
+	case query.Subreddit != "":
+		return "r/" + query.Subreddit
+	case query.Home:
+		return "Home feed of " + query.User
+	default:
+		return "nanoreddit"
+	}
+}
+
+func (h *handler) listingURL(query *protocol.FeedQuery) string {
+	if query.Subreddit != "" {
+		return h.cfg.PublicURL + "/r/" + query.Subreddit
+	}
+	return h.cfg.PublicURL + "/feed"
+}
+
+// permalink is where a post lives, links of link posts point elsewhere.
+func (h *handler) permalink(post *protocol.Post) string {
+	return h.cfg.PublicURL + "/posts/" + post.ID
+}
+
+func (h *handler) rss(query *protocol.FeedQuery, posts []protocol.Post) *protocol.RSS {
+	items := make([]protocol.RSSItem, 0, len(posts))
+	for i := range posts {
+		post := &posts[i]
+		link := post.Link
+		if link == "" {
+			link = h.permalink(post)
+		}
+		items = append(items, protocol.RSSItem{
+			Title:       post.Title,
+			Link:        link,
+			GUID:        protocol.RSSGUID{Value: post.ID},
+			PubDate:     time.Unix(post.Created, 0).UTC().Format(time.RFC1123Z),
+			Description: post.Content,
+			Category:    post.Subreddit,
+			Comments:    h.permalink(post),
+		})
+	}
+
+	return &protocol.RSS{
+		Version: "2.0",
+		Channel: protocol.RSSChannel{
+			Title:         h.listingTitle(query),
+			Link:          h.listingURL(query),
+			Description:   "Posts of " + h.listingTitle(query) + " ranked by score",
+			LastBuildDate: h.now().UTC().Format(time.RFC1123Z),
+			Items:         items,
+		},
+	}
+}
+
+func (h *handler) atom(r *http.Request, query *protocol.FeedQuery, posts []protocol.Post) *protocol.AtomFeed {
+	entries := make([]protocol.AtomEntry, 0, len(posts))
+	// A feed has been updated when its latest entry has, an empty one is as new as the response.
+	var updated int64
+	for i := range posts {
+		post := &posts[i]
+		postUpdated := post.Created
+		if post.Edited > postUpdated {
+			postUpdated = post.Edited
+		}
+		if postUpdated > updated {
+			updated = postUpdated
+		}
+
+		entry := protocol.AtomEntry{
+			ID:        h.permalink(post),
+			Title:     post.Title,
+			Updated:   time.Unix(postUpdated, 0).UTC().Format(time.RFC3339),
+			Published: time.Unix(post.Created, 0).UTC().Format(time.RFC3339),
+			Author:    protocol.AtomPerson{Name: post.Author},
+			Links:     []protocol.AtomLink{{Href: h.permalink(post), Rel: "alternate"}},
+			Category:  &protocol.AtomCategory{Term: post.Subreddit},
+		}
+		if post.Link != "" {
+			entry.Links = append(entry.Links, protocol.AtomLink{Href: post.Link, Rel: "related"})
+		}
+		if post.Content != "" {
+			entry.Content = &protocol.AtomContent{Type: "text", Value: post.Content}
+		}
+		entries = append(entries, entry)
+	}
+	if updated == 0 {
+		updated = h.now().Unix()
+	}
+
+	return &protocol.AtomFeed{
+		ID:      h.listingURL(query),
+		Title:   h.listingTitle(query),
+		Updated: time.Unix(updated, 0).UTC().Format(time.RFC3339),
+		Links: []protocol.AtomLink{
+			{Href: h.cfg.PublicURL + r.URL.RequestURI(), Rel: "self", Type: protocol.ContentTypeAtom},
+			{Href: h.listingURL(query), Rel: "alternate"},
+		},
+		Entries: entries,
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/middleware"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func withURLFormat(r *http.Request, format string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), chimiddleware.URLFormatCtxKey, format))
+}
+
+func TestNegotiate(t *testing.T) {
+	Convey("Test negotiating a format of a listing", t, func() {
+		for accept, format := range map[string]string{
+			"":                                    formatJSON,
+			"*/*":                                 formatJSON,
+			"text/html,application/xml;q=0.9":     formatJSON,
+			"application/rss+xml":                 formatRSS,
+			"Application/Atom+XML; charset=utf-8": formatAtom,
+			"application/rss+xml;q=0.5, application/atom+xml":   formatAtom,
+			"application/json;q=0.1, application/rss+xml;q=0.2": formatRSS,
+			"application/rss+xml;q=0, application/json":         formatJSON,
+		} {
+			So(negotiate(accept), ShouldEqual, format)
+		}
+	})
+}
+
+func TestFeedFormats(t *testing.T) {
+	Convey("Test feeds in RSS and Atom", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		posts := []protocol.Post{
+			{ID: "1a", Title: "Go & <generics>", Author: "t2_abcdefg2", Subreddit: "golang", Content: "<script>alert(1)</script>", Created: 1612000000, Score: 5},
+			{ID: "1b", Title: "Go 1.16", Author: "t2_abcdefg3", Link: "https://golang.org/doc/go1.16?a=1&b=2", Subreddit: "golang", Created: 1611000000, Edited: 1612001000, Score: 3},
+		}
+
+		Convey("It fails if a format is unknown", func() {
+			req := withURLFormat(httptest.NewRequest(http.MethodGet, "/feed.xml", nil), "xml")
+
+			handler.Feed(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("RSS is chosen by a suffix", func() {
+			req := withURLFormat(httptest.NewRequest(http.MethodGet, "/feed.rss", nil), "rss")
+
+			m.
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Viewer: "ip:192.0.2.1", NoAds: true}).Return(posts, false, nil)
+
+			handler.Feed(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/rss+xml; charset=utf-8")
+			So(w.Body.String(), ShouldEqual, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+				`<rss version="2.0"><channel><title>nanoreddit</title><link>https://nanoreddit.example/feed</link>`+
+				`<description>Posts of nanoreddit ranked by score</description><lastBuildDate>Sat, 30 Jan 2021 12:00:00 +0000</lastBuildDate>`+
+				`<item><title>Go &amp; &lt;generics&gt;</title><link>https://nanoreddit.example/posts/1a</link>`+
+				`<guid isPermaLink="false">1a</guid><pubDate>Sat, 30 Jan 2021 09:46:40 +0000</pubDate>`+
+				`<description>&lt;script&gt;alert(1)&lt;/script&gt;</description><category>golang</category>`+
+				`<comments>https://nanoreddit.example/posts/1a</comments></item>`+
+				`<item><title>Go 1.16</title><link>https://golang.org/doc/go1.16?a=1&amp;b=2</link>`+
+				`<guid isPermaLink="false">1b</guid><pubDate>Mon, 18 Jan 2021 20:00:00 +0000</pubDate>`+
+				`<category>golang</category><comments>https://nanoreddit.example/posts/1b</comments></item>`+
+				`</channel></rss>`)
+		})
+
+		Convey("Atom is chosen by the Accept header", func() {
+			req := withURLParams(httptest.NewRequest(http.MethodGet, "/r/golang?page=1", nil), map[string]string{"subreddit": "golang"})
+			req.Header.Set("Accept", "application/atom+xml, application/rss+xml;q=0.9")
+
+			m.
+				On("GetSubreddit", mock.Anything, "golang").Return(&protocol.Subreddit{Name: "golang"}, nil).
+				On("GetFeed", mock.Anything, &protocol.FeedQuery{Subreddit: "golang", Page: 1, Viewer: "ip:192.0.2.1", NoAds: true}).Return(posts, false, nil)
+
+			handler.SubredditFeed(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/atom+xml; charset=utf-8")
+			So(w.Header().Get("Vary"), ShouldEqual, "Accept")
+			So(w.Body.String(), ShouldEqual, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+				`<feed xmlns="http://www.w3.org/2005/Atom"><id>https://nanoreddit.example/r/golang</id><title>r/golang</title>`+
+				`<updated>2021-01-30T10:03:20Z</updated>`+
+				`<link href="https://nanoreddit.example/r/golang?page=1" rel="self" type="application/atom+xml"></link>`+
+				`<link href="https://nanoreddit.example/r/golang" rel="alternate"></link>`+
+				`<entry><id>https://nanoreddit.example/posts/1a</id><title>Go &amp; &lt;generics&gt;</title>`+
+				`<updated>2021-01-30T09:46:40Z</updated><published>2021-01-30T09:46:40Z</published>`+
+				`<author><name>t2_abcdefg2</name></author><link href="https://nanoreddit.example/posts/1a" rel="alternate"></link>`+
+				`<category term="golang"></category><content type="text">&lt;script&gt;alert(1)&lt;/script&gt;</content></entry>`+
+				`<entry><id>https://nanoreddit.example/posts/1b</id><title>Go 1.16</title>`+
+				`<updated>2021-01-30T10:03:20Z</updated><published>2021-01-18T20:00:00Z</published>`+
+				`<author><name>t2_abcdefg3</name></author><link href="https://nanoreddit.example/posts/1b" rel="alternate"></link>`+
+				`<link href="https://golang.org/doc/go1.16?a=1&amp;b=2" rel="related"></link><category term="golang"></category></entry>`+
+				`</feed>`)
+		})
+	})
+}
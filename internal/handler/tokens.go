@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+// IssueToken issues an access token for a third-party client on behalf of the user.
+// Only sessions issue tokens, so a token can't issue another one which would survive its revocation.
+func (h *handler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.TokenRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+	if !middleware.Session(ctx) {
+		h.render.Forbidden(w, r, errors.New("only session tokens can issue access tokens"))
+		return
+	}
+
+	scopes, err := protocol.ParseScopes(request.Scope)
+	if err != nil {
+		h.render.InvalidRequest(w, r, err)
+		return
+	}
+	granted := middleware.Scopes(ctx)
+	for _, scope := range scopes {
+		if !protocol.HasScope(granted, scope) {
+			h.render.InvalidRequest(w, r, fmt.Errorf("the %s scope exceeds the credential", scope))
+			return
+		}
+	}
+
+	ttl := h.cfg.TokenTTL
+	if request.ExpiresIn != 0 {
+		ttl = time.Duration(request.ExpiresIn) * time.Second
+		if ttl > h.cfg.TokenMaxTTL {
+			h.render.InvalidRequest(w, r, fmt.Errorf("a token cannot live longer than %s", h.cfg.TokenMaxTTL))
+			return
+		}
+	}
+
+	grant := protocol.AccessToken{
+		User:    user,
+		Scopes:  scopes,
+		Expires: h.now().Add(ttl).Unix(),
+	}
+	token, err := h.storage.AddToken(ctx, &grant)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't issue an access token")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.TokenResponse{
+		AccessToken: token,
+		TokenType:   "bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+// RevokeToken revokes an access token of the user. Like RFC 7009 says, an unknown token isn't an error.
+func (h *handler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.RevokeRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+	user := h.author(w, r, "")
+	if user == "" {
+		return
+	}
+
+	if err := h.storage.RevokeToken(ctx, user, request.Token); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't revoke an access token")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.GeneralResponse{})
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+func TestIssueToken(t *testing.T) {
+	Convey("Test IssueToken", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/oauth/token", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return withUser(req, "t2_abcdefg2")
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a scope is unknown", func() {
+			handler.IssueToken(w, newRequest(`{"scope":"read write"}`))
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"errors":[{"code":400,"description":"unknown scope: \"write\""}]}`)
+		})
+
+		Convey("It fails if a scope exceeds the credential", func() {
+			req := newRequest(`{"scope":"read vote"}`)
+			req = req.WithContext(middleware.WithCredential(req.Context(), &middleware.Credential{User: "t2_abcdefg2", Scopes: []string{protocol.ScopeRead}, Session: true}))
+
+			handler.IssueToken(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a credential isn't a session", func() {
+			req := newRequest(`{"scope":"read"}`)
+			req = req.WithContext(middleware.WithCredential(req.Context(), &middleware.Credential{User: "t2_abcdefg2", Scopes: protocol.Scopes, FirstParty: true}))
+
+			handler.IssueToken(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"errors":[{"code":403,"description":"only session tokens can issue access tokens"}]}`)
+		})
+
+		Convey("It fails if a lifetime is too long", func() {
+			handler.IssueToken(w, newRequest(`{"scope":"read","expires_in":86401}`))
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("AddToken", mock.Anything, mock.Anything).Return("", errors.New("storage error"))
+
+			handler.IssueToken(w, newRequest(`{"scope":"read"}`))
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story (a lifetime defaults to the configured one)", func() {
+			m.
+				On("AddToken", mock.Anything, &protocol.AccessToken{
+					User:    "t2_abcdefg2",
+					Scopes:  []string{protocol.ScopeRead, protocol.ScopeVote},
+					Expires: mockNow.Unix() + 3600,
+				}).Return("secret", nil)
+
+			handler.IssueToken(w, newRequest(`{"scope":"read  vote"}`))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"access_token":"secret","token_type":"bearer","expires_in":3600,"scope":"read vote"}`)
+		})
+
+		Convey("Successful story (a lifetime is requested)", func() {
+			m.
+				On("AddToken", mock.Anything, &protocol.AccessToken{
+					User:    "t2_abcdefg2",
+					Scopes:  []string{protocol.ScopeSubmit},
+					Expires: mockNow.Unix() + 60,
+				}).Return("secret", nil)
+
+			handler.IssueToken(w, newRequest(`{"scope":"submit","expires_in":60}`))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(w.Body.String(), assertions.ShouldEqualJSON, `{"access_token":"secret","token_type":"bearer","expires_in":60,"scope":"submit"}`)
+		})
+	})
+}
+
+func TestRevokeToken(t *testing.T) {
+	Convey("Test RevokeToken", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/oauth/revoke", bytes.NewBufferString(`{"token":"secret"}`))
+		req.Header.Add("Content-Type", "application/json")
+		req = withUser(req, "t2_abcdefg2")
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("RevokeToken", mock.Anything, "t2_abcdefg2", "secret").Return(errors.New("storage error"))
+
+			handler.RevokeToken(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("RevokeToken", mock.Anything, "t2_abcdefg2", "secret").Return(nil)
+
+			handler.RevokeToken(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
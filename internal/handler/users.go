@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+var errUserNotFound = errors.New("the user is not found")
+
+// user fetches a user referred by the URL. It renders a response and returns nil if there is no such user.
+func (h *handler) user(w http.ResponseWriter, r *http.Request) *protocol.User {
+	ctx := r.Context()
+
+	user, err := h.storage.GetUser(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch a user")
+		h.render.InternalServerError(w, r, err)
+		return nil
+	}
+	if user == nil {
+		h.render.NotFound(w, r, errUserNotFound)
+		return nil
+	}
+	return user
+}
+
+func (h *handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request protocol.UserRequest
+	if err := h.binder.Bind(w, r, &request); err != nil {
+		return
+	}
+
+	user := protocol.User{
+		Name:    request.Name,
+		Created: h.now().Unix(),
+	}
+	ok, err := h.storage.AddUser(ctx, &user)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't save a user")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if !ok {
+		h.render.Conflict(w, r, errors.New("the name is already taken"))
+		return
+	}
+	token, err := h.storage.AddToken(ctx, &protocol.AccessToken{User: user.ID, Scopes: protocol.Scopes, FirstParty: true})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't issue an API token")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+
+	render.Respond(w, r, &protocol.UserResponse{Data: user, Token: token})
+}
+
+func (h *handler) User(w http.ResponseWriter, r *http.Request) {
+	user := h.user(w, r)
+	if user == nil {
+		return
+	}
+
+	render.Respond(w, r, &protocol.UserResponse{Data: *user})
+}
+
+func (h *handler) Submitted(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format, ok := h.format(w, r, false)
+	if !ok {
+		return
+	}
+	page, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+
+	user := h.user(w, r)
+	if user == nil {
+		return
+	}
+
+	// Posts of a shadowbanned author are listed to the author only.
+	own := user.ID == middleware.User(ctx)
+	posts, more, err := h.storage.GetSubmitted(ctx, user.ID, own, page)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch submitted posts")
+		h.render.InternalServerError(w, r, err)
+		return
+	}
+	if posts == nil {
+		posts = []protocol.Post{}
+	}
+
+	h.respondPosts(w, r, format, page, posts, more)
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
+)
+
+func TestCreateUser(t *testing.T) {
+	Convey("Test CreateUser", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(body string) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(body))
+			req.Header.Add("Content-Type", "application/json")
+			return req
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a name is invalid", func() {
+			for _, name := range []string{"", "ab", "spaces are bad", "a_very_long_user_name_indeed"} {
+				w := httptest.NewRecorder()
+				handler.CreateUser(w, newRequest(`{"name":"`+name+`"}`))
+
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			}
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a name is taken", func() {
+			m.
+				On("AddUser", mock.Anything, mock.Anything).Return(false, nil)
+
+			handler.CreateUser(w, newRequest(`{"name":"gopher"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusConflict)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"errors":[{"code":409,"description":"the name is already taken"}]}`)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("AddUser", mock.Anything, mock.Anything).Return(false, errors.New("storage error"))
+
+			handler.CreateUser(w, newRequest(`{"name":"gopher"}`))
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("AddUser", mock.Anything, &protocol.User{Name: "gopher", Created: mockNow.Unix()}).
+				Run(func(args mock.Arguments) { args.Get(1).(*protocol.User).ID = "t2_abcdefg2" }).
+				Return(true, nil).
+				On("AddToken", mock.Anything, &protocol.AccessToken{User: "t2_abcdefg2", Scopes: protocol.Scopes, FirstParty: true}).Return("secret", nil)
+
+			handler.CreateUser(w, newRequest(`{"name":"gopher"}`))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"t2_abcdefg2","name":"gopher","link_karma":0,"comment_karma":0,"created":1612008000},"token":"secret"}`)
+		})
+	})
+}
+
+func TestUser(t *testing.T) {
+	Convey("Test User", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		req := withURLParams(httptest.NewRequest(http.MethodGet, "/user/t2_abcdefg2", nil), map[string]string{"id": "t2_abcdefg2"})
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a user doesn't exist", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return((*protocol.User)(nil), nil)
+
+			handler.User(w, req)
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").
+				Return(&protocol.User{ID: "t2_abcdefg2", Name: "gopher", LinkKarma: 10, CommentKarma: -2}, nil)
+
+			handler.User(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `{"data":{"id":"t2_abcdefg2","name":"gopher","link_karma":10,"comment_karma":-2}}`)
+		})
+	})
+}
+
+func TestSubmitted(t *testing.T) {
+	Convey("Test Submitted", t, func() {
+		m := &mock.Mock{}
+
+		w := httptest.NewRecorder()
+		w.Body = bytes.NewBuffer(nil)
+
+		newRequest := func(target string) *http.Request {
+			return withURLParams(httptest.NewRequest(http.MethodGet, target, nil), map[string]string{"id": "t2_abcdefg2"})
+		}
+
+		handler, err := mockHandler(m)
+		So(err, ShouldBeNil)
+
+		Convey("It fails if a page is invalid", func() {
+			handler.Submitted(w, newRequest("/user/t2_abcdefg2/submitted?page=one"))
+
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a user doesn't exist", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return((*protocol.User)(nil), nil)
+
+			handler.Submitted(w, newRequest("/user/t2_abcdefg2/submitted"))
+
+			So(w.Code, ShouldEqual, http.StatusNotFound)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an storage has been failed", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{ID: "t2_abcdefg2"}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", false, 0).Return(([]protocol.Post)(nil), false, errors.New("storage error"))
+
+			handler.Submitted(w, newRequest("/user/t2_abcdefg2/submitted"))
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("Successful story", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{ID: "t2_abcdefg2"}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", false, 2).Return([]protocol.Post{{ID: "1a", Title: "title", Author: "t2_abcdefg2"}}, false, nil)
+
+			handler.Submitted(w, newRequest("/user/t2_abcdefg2/submitted?page=2"))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			resBbody, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(resBbody), assertions.ShouldEqualJSON, `[{"id":"1a","title":"title","author":"t2_abcdefg2","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":0}]`)
+		})
+
+		Convey("Posts of shadowbanned authors are listed for their authors only", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{ID: "t2_abcdefg2"}, nil)
+
+			Convey("Others don't see them", func() {
+				m.
+					On("GetSubmitted", mock.Anything, "t2_abcdefg2", false, 0).Return([]protocol.Post{}, false, nil)
+
+				req := newRequest("/user/t2_abcdefg2/submitted")
+				handler.Submitted(w, req.WithContext(middleware.WithUser(req.Context(), "t2_abcdefg1")))
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("The author sees them", func() {
+				m.
+					On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post{{ID: "1a", Title: "title", Author: "t2_abcdefg2", Shadowbanned: true}}, false, nil)
+
+				req := newRequest("/user/t2_abcdefg2/submitted")
+				handler.Submitted(w, req.WithContext(middleware.WithUser(req.Context(), "t2_abcdefg2")))
+
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				So(w.Body.String(), assertions.ShouldEqualJSON, `[{"id":"1a","title":"title","author":"t2_abcdefg2","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":0}]`)
+			})
+		})
+
+		Convey("There are more pages as long as a storage has more posts", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{ID: "t2_abcdefg2"}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", false, 0).Return([]protocol.Post{{ID: "1a", Title: "title", Author: "t2_abcdefg2"}}, true, nil).
+				On("GetUsernames", mock.Anything, []string{"t2_abcdefg2"}).Return(map[string]string{"t2_abcdefg2": "gopher"}, nil)
+
+			handler.Submitted(w, withURLFormat(newRequest("/user/t2_abcdefg2/submitted.json"), "json"))
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			var listing protocol.RedditListing
+			So(json.Unmarshal(w.Body.Bytes(), &listing), ShouldBeNil)
+			So(*listing.Data.After, ShouldEqual, "1")
+		})
+	})
+}
@@ -0,0 +1,7 @@
+package live
+
+type Config struct {
+	Channel string `env:"ES_UPDATES,default=feed_updates"`
+	// Buffer is how many updates a connection may lag behind before it's dropped.
+	Buffer int `env:"LIVE_BUFFER,default=64"`
+}
@@ -0,0 +1,129 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// service relays updates of the feeds which the materializer publishes to live connections of this replica.
+// Connections mustn't hold up each other, so one which doesn't keep up with updates is dropped rather than waited
+// for, and its client is expected to reconnect and catch up with the feed.
+type service struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *Config
+	client interface {
+		Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+	}
+
+	mu          sync.Mutex
+	subscribers map[chan protocol.FeedUpdate]struct{}
+	stopped     bool
+}
+
+func (s *service) Execute() error {
+	ctx := s.ctx
+
+	pubsub := s.client.Subscribe(ctx, s.cfg.Channel)
+	defer func() {
+		if err := pubsub.Close(); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't close a subscription")
+		}
+	}()
+	s.relay(ctx, pubsub.Channel())
+	return nil
+}
+
+// relay broadcasts messages until the context is done or the messages run out, then it closes all subscriptions.
+func (s *service) relay(ctx context.Context, messages <-chan *redis.Message) {
+	defer s.stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			var update protocol.FeedUpdate
+			if err := json.Unmarshal([]byte(message.Payload), &update); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Str("payload", message.Payload).Msg("Couldn't unmarshal a feed update")
+				continue
+			}
+			s.broadcast(ctx, update)
+		}
+	}
+}
+
+func (s *service) broadcast(ctx context.Context, update protocol.FeedUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for updates := range s.subscribers {
+		select {
+		case updates <- update:
+		default:
+			delete(s.subscribers, updates)
+			close(updates)
+			zerolog.Ctx(ctx).Warn().Msg("A live connection has fallen behind and has been dropped")
+		}
+	}
+}
+
+func (s *service) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	for updates := range s.subscribers {
+		delete(s.subscribers, updates)
+		close(updates)
+	}
+}
+
+// Subscribe returns a channel of updates and a function which cancels the subscription. The channel is closed
+// when the subscription is cancelled, the subscriber has fallen behind, or the service stops.
+func (s *service) Subscribe() (<-chan protocol.FeedUpdate, func()) {
+	updates := make(chan protocol.FeedUpdate, s.cfg.Buffer)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		close(updates)
+		return updates, func() {}
+	}
+	s.subscribers[updates] = struct{}{}
+	return updates, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := s.subscribers[updates]; ok {
+			delete(s.subscribers, updates)
+			close(updates)
+		}
+	}
+}
+
+func (s *service) Interrupt(err error) {
+	s.cancel()
+}
+
+func NewService(ctx context.Context, cancel context.CancelFunc, client *redis.Client, cfg *Config) *service {
+	l := zerolog.Ctx(ctx).With().Str("service", "live").Logger()
+	ctx = l.WithContext(ctx)
+
+	return &service{
+		ctx:         ctx,
+		cancel:      cancel,
+		client:      client,
+		cfg:         cfg,
+		subscribers: map[chan protocol.FeedUpdate]struct{}{},
+	}
+}
@@ -0,0 +1,65 @@
+package live
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestRelay(t *testing.T) {
+	Convey("Test relaying feed updates", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		srv := NewService(ctx, cancel, nil, &Config{Channel: "feed_updates", Buffer: 1})
+		messages := make(chan *redis.Message, 3)
+		done := make(chan struct{})
+
+		fast, unsubscribe := srv.Subscribe()
+		defer unsubscribe()
+		slow, _ := srv.Subscribe()
+
+		go func() {
+			srv.relay(srv.ctx, messages)
+			close(done)
+		}()
+
+		Convey("Updates are delivered and a connection which falls behind is dropped", func() {
+			messages <- &redis.Message{Channel: "feed_updates", Payload: `{"type":"new","id":"1","subreddit":"golang"}`}
+			So(<-fast, ShouldResemble, protocol.FeedUpdate{Type: "new", ID: "1", Subreddit: "golang"})
+
+			// The slow subscriber hasn't read its first update, so there is no room for the second one.
+			messages <- &redis.Message{Channel: "feed_updates", Payload: "{"}
+			messages <- &redis.Message{Channel: "feed_updates", Payload: `{"type":"removal","id":"2","subreddit":"golang"}`}
+			So(<-fast, ShouldResemble, protocol.FeedUpdate{Type: "removal", ID: "2", Subreddit: "golang"})
+
+			So(<-slow, ShouldResemble, protocol.FeedUpdate{Type: "new", ID: "1", Subreddit: "golang"})
+			_, ok := <-slow
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Subscriptions are closed when the service stops", func() {
+			srv.Interrupt(nil)
+			<-done
+
+			_, ok := <-fast
+			So(ok, ShouldBeFalse)
+			_, ok = <-slow
+			So(ok, ShouldBeFalse)
+
+			late, _ := srv.Subscribe()
+			_, ok = <-late
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("A cancelled subscription is closed", func() {
+			unsubscribe()
+
+			_, ok := <-fast
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package materializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
+)
+
+// hour truncates a Unix time to the start of its hour.
+func hour(t int64) int64 {
+	return t - t%3600
+}
+
+// countAd increments a counter of a campaign overall and within the hour of an event.
+func (s *service) countAd(ctx context.Context, campaign, counter string, created int64) error {
+	for _, h := range []int64{0, hour(created)} {
+		if err := s.client.HIncrBy(ctx, storage.AdStatsKey(s.cfg.AdStats, campaign, h), counter, 1).Err(); err != nil {
+			return fmt.Errorf("couldn't count an ad event: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *service) adImpressed(ctx context.Context, blob string) error {
+	var impression protocol.AdImpressed
+	if err := json.Unmarshal([]byte(blob), &impression); err != nil {
+		return fmt.Errorf("couldn't unmarshal an impression: %w", err)
+	}
+
+	if err := s.countAd(ctx, impression.Campaign, storage.AdStatsImpressions, impression.Created); err != nil {
+		return err
+	}
+	if impression.Viewer == "" {
+		return nil
+	}
+	for _, h := range []int64{0, hour(impression.Created)} {
+		if err := s.client.PFAdd(ctx, storage.AdStatsKey(s.cfg.AdViewers, impression.Campaign, h), impression.Viewer).Err(); err != nil {
+			return fmt.Errorf("couldn't count a viewer: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *service) adClicked(ctx context.Context, blob string) error {
+	var click protocol.AdClicked
+	if err := json.Unmarshal([]byte(blob), &click); err != nil {
+		return fmt.Errorf("couldn't unmarshal a click: %w", err)
+	}
+
+	return s.countAd(ctx, click.Campaign, storage.AdStatsClicks, click.Created)
+}
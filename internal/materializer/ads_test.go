@@ -0,0 +1,64 @@
+package materializer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/storage"
+)
+
+func TestAdEvents(t *testing.T) {
+	Convey("Test materializing ad events", t, func() {
+		m := &mock.Mock{}
+		srv := mockService(m, &Config{AdStats: "ad_stats", AdViewers: "ad_viewers"})
+
+		Convey("It fails if an impression cannot be counted", func() {
+			mockEvent(m, storage.EventAdImpressed, `{"campaign":"1a","post_id":"1b","viewer":"t2_abcdefg3","page":0,"slot":1,"created":1612008123}`)
+			m.
+				On("HIncrBy", mock.Anything, "ad_stats:1a", storage.AdStatsImpressions, int64(1)).
+				Return(redis.NewIntResult(0, errors.New("error")))
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't count an ad event: error`)
+		})
+
+		Convey("An impression is counted overall and within its hour along with its viewer", func() {
+			mockEvent(m, storage.EventAdImpressed, `{"campaign":"1a","post_id":"1b","viewer":"t2_abcdefg3","page":0,"slot":1,"created":1612008123}`)
+			m.
+				On("HIncrBy", mock.Anything, "ad_stats:1a", storage.AdStatsImpressions, int64(1)).
+				Return(redis.NewIntResult(1, nil)).
+				On("HIncrBy", mock.Anything, "ad_stats:1a:1612008000", storage.AdStatsImpressions, int64(1)).
+				Return(redis.NewIntResult(1, nil)).
+				On("PFAdd", mock.Anything, "ad_viewers:1a", []interface{}{"t2_abcdefg3"}).
+				Return(redis.NewIntResult(1, nil)).
+				On("PFAdd", mock.Anything, "ad_viewers:1a:1612008000", []interface{}{"t2_abcdefg3"}).
+				Return(redis.NewIntResult(1, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A click is counted", func() {
+			mockEvent(m, storage.EventAdClicked, `{"campaign":"1a","post_id":"1b","created":1612008123}`)
+			m.
+				On("HIncrBy", mock.Anything, "ad_stats:1a", storage.AdStatsClicks, int64(1)).
+				Return(redis.NewIntResult(1, nil)).
+				On("HIncrBy", mock.Anything, "ad_stats:1a:1612008000", storage.AdStatsClicks, int64(1)).
+				Return(redis.NewIntResult(1, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
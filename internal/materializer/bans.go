@@ -0,0 +1,40 @@
+package materializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
+)
+
+// banned returns a ban of the author of a post site-wide or from the subreddit of the post, or nil if there is none.
+// Bans are checked once again here, since a post may have been in flight when its author was banned, and posts of
+// shadowbanned authors are accepted anyway. A ban which isn't a shadowban takes precedence.
+func (s *service) banned(ctx context.Context, post *protocol.Post) (*protocol.Ban, error) {
+	var shadowban *protocol.Ban
+	for _, subreddit := range []string{"", post.Subreddit} {
+		blob, err := s.client.HGet(ctx, storage.BansKey(s.cfg.Bans, subreddit), post.Author).Result()
+		if err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return nil, fmt.Errorf("couldn't load a ban: %w", err)
+		}
+		var ban protocol.Ban
+		if err := json.Unmarshal([]byte(blob), &ban); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal a ban: %w", err)
+		}
+		if !ban.Active(s.now().Unix()) {
+			continue
+		}
+		if !ban.Shadow {
+			return &ban, nil
+		}
+		shadowban = &ban
+	}
+	return shadowban, nil
+}
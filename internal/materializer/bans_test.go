@@ -0,0 +1,94 @@
+package materializer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/storage"
+)
+
+func TestBans(t *testing.T) {
+	Convey("Test materializing posts of banned authors", t, func() {
+		m := &mock.Mock{}
+		srv := mockService(m, &Config{Feed: "feed", Posts: "post_by_id", Submitted: "submitted", Shadowbanned: "shadowbanned", Bans: "bans"})
+		srv.now = func() time.Time { return time.Unix(1000, 0) }
+		mockEvent(m, storage.EventPostSubmitted, `{"id":"1a","author":"t2_abcdefg2","subreddit":"GoLang","score":1,"created":900}`)
+
+		Convey("It fails if a ban cannot be loaded", func() {
+			m.
+				On("HGet", mock.Anything, "bans", "t2_abcdefg2").
+				Return(redis.NewStringResult("", errors.New("error")))
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't load a ban: error`)
+		})
+
+		Convey("A post of a site-wide shadowbanned author is kept out of listings", func() {
+			m.
+				On("HGet", mock.Anything, "bans", "t2_abcdefg2").
+				Return(redis.NewStringResult(`{"user":"t2_abcdefg2","shadow":true,"by":"t2_abcdefg1","created":500}`, nil)).
+				On("HGet", mock.Anything, "bans:golang", "t2_abcdefg2").
+				Return(redis.NewStringResult("", redis.Nil)).
+				On("HSet", mock.Anything, "post_by_id", mock.Anything).
+				Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "shadowbanned:t2_abcdefg2", []*redis.Z{{Score: 900, Member: "1a"}}).
+				Return(redis.NewIntResult(1, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			for _, call := range m.Calls {
+				if call.Method == "HSet" {
+					values := call.Arguments.Get(2).([]interface{})
+					So(values[0], ShouldEqual, "1a")
+					So(values[1], assertions.ShouldEqualJSON, `{"id":"1a","title":"","author":"t2_abcdefg2","subreddit":"GoLang","score":1,"promoted":false,"nsfw":false,"num_comments":0,"created":900,"shadowbanned":true}`)
+				}
+			}
+		})
+
+		Convey("A post of an author banned from the subreddit is skipped", func() {
+			m.
+				On("HGet", mock.Anything, "bans", "t2_abcdefg2").
+				Return(redis.NewStringResult("", redis.Nil)).
+				On("HGet", mock.Anything, "bans:golang", "t2_abcdefg2").
+				Return(redis.NewStringResult(`{"user":"t2_abcdefg2","subreddit":"GoLang","by":"t2_abcdefg1","created":500,"expires":2000}`, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An expired ban doesn't matter", func() {
+			m.
+				On("HGet", mock.Anything, "bans", "t2_abcdefg2").
+				Return(redis.NewStringResult(`{"user":"t2_abcdefg2","by":"t2_abcdefg1","created":500,"expires":1000}`, nil)).
+				On("HGet", mock.Anything, "bans:golang", "t2_abcdefg2").
+				Return(redis.NewStringResult("", redis.Nil)).
+				On("HSet", mock.Anything, "post_by_id", mock.Anything).
+				Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "submitted:t2_abcdefg2", mock.Anything).
+				Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "feed", []*redis.Z{{Score: 1, Member: "1a"}}).
+				Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "feed:golang", []*redis.Z{{Score: 1, Member: "1a"}}).
+				Return(redis.NewIntResult(1, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package materializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
+)
+
+// confidence is the lower bound of Wilson score confidence interval for a Bernoulli parameter.
+// That is what Reddit calls the "best" order: http://www.evanmiller.org/how-not-to-sort-by-average-rating.html
+func confidence(ups, downs int) float64 {
+	n := float64(ups + downs)
+	if n == 0 {
+		return 0
+	}
+	const z = 1.281551565545 // 80% confidence
+	p := float64(ups) / n
+	left := p + z*z/(2*n)
+	right := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	under := 1 + z*z/n
+	return (left - right) / under
+}
+
+func (s *service) loadComment(ctx context.Context, id string) (*protocol.Comment, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Comments, id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("couldn't load a comment: %w", err)
+	}
+	var comment protocol.Comment
+	if err := json.Unmarshal([]byte(blob), &comment); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal a saved comment: %w", err)
+	}
+	return &comment, nil
+}
+
+func (s *service) saveComment(ctx context.Context, comment *protocol.Comment) error {
+	blob, err := json.Marshal(comment)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal a comment: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.cfg.Comments, comment.ID, blob).Err(); err != nil {
+		return fmt.Errorf("couldn't save a comment: %w", err)
+	}
+	return nil
+}
+
+// indexComment puts a comment into every order of replies to its parent.
+func (s *service) indexComment(ctx context.Context, comment *protocol.Comment) error {
+	parentID := comment.ParentID
+	if parentID == "" {
+		parentID = comment.PostID
+	}
+	scores := map[string]float64{
+		protocol.CommentSortBest: confidence(comment.Ups, comment.Downs),
+		protocol.CommentSortTop:  float64(comment.Score),
+		protocol.CommentSortNew:  float64(comment.Created),
+	}
+	for _, sort := range protocol.CommentSorts {
+		if err := s.client.ZAdd(ctx, storage.CommentIndexKey(s.cfg.CommentIndex, sort, parentID), &redis.Z{
+			Score:  scores[sort],
+			Member: comment.ID,
+		}).Err(); err != nil {
+			return fmt.Errorf("couldn't index a comment: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *service) commentSubmitted(ctx context.Context, blob string) error {
+	var comment protocol.Comment
+	if err := json.Unmarshal([]byte(blob), &comment); err != nil {
+		return fmt.Errorf("couldn't unmarshal a comment: %w", err)
+	}
+
+	if err := s.client.HSet(ctx, s.cfg.Comments, comment.ID, blob).Err(); err != nil {
+		return fmt.Errorf("couldn't save a comment: %w", err)
+	}
+	if err := s.indexComment(ctx, &comment); err != nil {
+		return err
+	}
+
+	// Counters are denormalized so that a thread can be rendered without counting replies.
+	if comment.ParentID != "" {
+		parent, err := s.loadComment(ctx, comment.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent != nil {
+			parent.NumReplies++
+			if err := s.saveComment(ctx, parent); err != nil {
+				return err
+			}
+		}
+	}
+	post, err := s.loadPost(ctx, comment.PostID)
+	if err != nil {
+		return err
+	}
+	if post == nil {
+		zerolog.Ctx(ctx).Warn().Str("id", comment.PostID).Msg("A comment refers to a missing post")
+		return nil
+	}
+	post.NumComments++
+	return s.savePost(ctx, post)
+}
+
+func (s *service) commentVoted(ctx context.Context, blob string) error {
+	var vote protocol.CommentVoted
+	if err := json.Unmarshal([]byte(blob), &vote); err != nil {
+		return fmt.Errorf("couldn't unmarshal a vote: %w", err)
+	}
+
+	comment, err := s.loadComment(ctx, vote.CommentID)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		zerolog.Ctx(ctx).Warn().Str("id", vote.CommentID).Msg("Skipping a vote for a missing comment")
+		return nil
+	}
+
+	previous, changed, err := s.vote(ctx, s.cfg.CommentVotes+":"+comment.ID, vote.Voter, vote.Direction)
+	if err != nil || !changed {
+		return err
+	}
+
+	count := func(dir, delta int) {
+		switch dir {
+		case 1:
+			comment.Ups += delta
+		case -1:
+			comment.Downs += delta
+		}
+	}
+	count(previous, -1)
+	count(vote.Direction, 1)
+	comment.Score = comment.Ups - comment.Downs
+
+	if err := s.saveComment(ctx, comment); err != nil {
+		return err
+	}
+	if err := s.indexComment(ctx, comment); err != nil {
+		return err
+	}
+	return s.addKarma(ctx, comment.Author, storage.KarmaComment, vote.Direction-previous)
+}
@@ -0,0 +1,146 @@
+package materializer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/storage"
+)
+
+func TestConfidence(t *testing.T) {
+	Convey("Test confidence", t, func() {
+		So(confidence(0, 0), ShouldEqual, 0)
+		So(confidence(10, 0), ShouldBeGreaterThan, confidence(1, 0))
+		So(confidence(10, 10), ShouldBeLessThan, confidence(10, 1))
+		So(confidence(100, 0), ShouldBeLessThan, 1)
+	})
+}
+
+func TestComments(t *testing.T) {
+	Convey("Test materializing comments", t, func() {
+		m := &mock.Mock{}
+		srv := mockService(m, &Config{Posts: "post_by_id", Comments: "comment_by_id", CommentIndex: "comments", CommentVotes: "comment_votes", Karma: "karma"})
+
+		Convey("A submitted comment", func() {
+			const blob = `{"id":"3c","post_id":"1a","parent_id":"2b","author":"t2_abcdefg2","body":"hello","created":100}`
+
+			Convey("It fails if an event payload is undecryptable", func() {
+				mockEvent(m, storage.EventCommentSubmitted, "")
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't unmarshal a comment: unexpected end of JSON input`)
+			})
+
+			Convey("It fails if a comment cannot be indexed", func() {
+				mockEvent(m, storage.EventCommentSubmitted, blob)
+				m.
+					On("HSet", mock.Anything, "comment_by_id", []interface{}{"3c", blob}).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "comments:best:2b", mock.Anything).
+					Return(redis.NewIntResult(0, errors.New("error")))
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't index a comment: error`)
+			})
+
+			Convey("Successful story", func() {
+				mockEvent(m, storage.EventCommentSubmitted, blob)
+				m.
+					On("HSet", mock.Anything, "comment_by_id", []interface{}{"3c", blob}).
+					Return(redis.NewIntResult(1, nil)).Once().
+					On("ZAdd", mock.Anything, "comments:best:2b", []*redis.Z{{Score: 0, Member: "3c"}}).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "comments:top:2b", []*redis.Z{{Score: 0, Member: "3c"}}).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "comments:new:2b", []*redis.Z{{Score: 100, Member: "3c"}}).
+					Return(redis.NewIntResult(1, nil)).
+					On("HGet", mock.Anything, "comment_by_id", "2b").
+					Return(redis.NewStringResult(`{"id":"2b","post_id":"1a","num_replies":1}`, nil)).
+					On("HSet", mock.Anything, "comment_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).Once().
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","num_comments":5}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).Once()
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				parent := m.Calls[6].Arguments.Get(2).([]interface{})
+				So(string(parent[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"2b","post_id":"1a","author":"","body":"","score":0,"ups":0,"downs":0,"num_replies":2}`)
+				post := m.Calls[8].Arguments.Get(2).([]interface{})
+				So(string(post[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"","author":"","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":6}`)
+			})
+		})
+
+		Convey("A vote", func() {
+			const blob = `{"comment_id":"3c","voter":"t2_abcdefg3","dir":-1}`
+
+			Convey("A vote for a missing comment is skipped", func() {
+				mockEvent(m, storage.EventCommentVoted, blob)
+				m.
+					On("HGet", mock.Anything, "comment_by_id", "3c").
+					Return(redis.NewStringResult("", redis.Nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("A repeated vote changes nothing", func() {
+				mockEvent(m, storage.EventCommentVoted, blob)
+				m.
+					On("HGet", mock.Anything, "comment_by_id", "3c").
+					Return(redis.NewStringResult(`{"id":"3c","post_id":"1a","downs":1,"score":-1}`, nil)).
+					On("HGet", mock.Anything, "comment_votes:3c", "t2_abcdefg3").
+					Return(redis.NewStringResult("-1", nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("Successful story (a vote is changed)", func() {
+				mockEvent(m, storage.EventCommentVoted, blob)
+				m.
+					On("HGet", mock.Anything, "comment_by_id", "3c").
+					Return(redis.NewStringResult(`{"id":"3c","post_id":"1a","author":"t2_abcdefg2","ups":3,"score":3,"created":100}`, nil)).
+					On("HGet", mock.Anything, "comment_votes:3c", "t2_abcdefg3").
+					Return(redis.NewStringResult("1", nil)).
+					On("HSet", mock.Anything, "comment_votes:3c", []interface{}{"t2_abcdefg3", -1}).
+					Return(redis.NewIntResult(0, nil)).
+					On("HSet", mock.Anything, "comment_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZAdd", mock.Anything, "comments:best:1a", []*redis.Z{{Score: confidence(2, 1), Member: "3c"}}).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZAdd", mock.Anything, "comments:top:1a", []*redis.Z{{Score: 1, Member: "3c"}}).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZAdd", mock.Anything, "comments:new:1a", []*redis.Z{{Score: 100, Member: "3c"}}).
+					Return(redis.NewIntResult(0, nil)).
+					On("HIncrBy", mock.Anything, "karma:t2_abcdefg2", storage.KarmaComment, int64(-2)).
+					Return(redis.NewIntResult(1, nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				comment := m.Calls[4].Arguments.Get(2).([]interface{})
+				So(string(comment[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"3c","post_id":"1a","author":"t2_abcdefg2","body":"","score":1,"ups":2,"downs":1,"num_replies":0,"created":100}`)
+			})
+		})
+	})
+}
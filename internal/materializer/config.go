@@ -1,9 +1,30 @@
 package materializer
 
 type Config struct {
-	Group     string `env:"ES_GROUP,default=materializer"`
-	Consumer  string `env:"ES_CONSUMER,default=nanoreddit"`
-	Stream    string `env:"ES_STREAM,default=posts"`
-	Feed      string `env:"ES_FEED,default=feed"`
-	Promotion string `env:"ES_PROMOTION,default=promotion"`
+	Group        string `env:"ES_GROUP,default=materializer"`
+	Consumer     string `env:"ES_CONSUMER,default=nanoreddit"`
+	Stream       string `env:"ES_STREAM,default=posts"`
+	Feed         string `env:"ES_FEED,default=feed"`
+	Promotion    string `env:"ES_PROMOTION_WEIGHTS,default=promotion_weights"`
+	HouseAds     string `env:"ES_HOUSE_ADS,default=house_ads"`
+	Posts        string `env:"ES_POSTS,default=post_by_id"`
+	Comments     string `env:"ES_COMMENTS,default=comment_by_id"`
+	CommentIndex string `env:"ES_COMMENT_INDEX,default=comments"`
+	CommentVotes string `env:"ES_COMMENT_VOTES,default=comment_votes"`
+	PostVotes    string `env:"ES_POST_VOTES,default=post_votes"`
+	Karma        string `env:"ES_KARMA,default=karma"`
+	Submitted    string `env:"ES_SUBMITTED,default=submitted"`
+	Shadowbanned string `env:"ES_SHADOWBANNED,default=shadowbanned"`
+	Links        string `env:"ES_LINKS,default=links"`
+	Reputation   string `env:"ES_REPUTATION,default=reputation"`
+	ModQueue     string `env:"ES_MODQUEUE,default=modqueue"`
+	Reports      string `env:"ES_REPORTS,default=reports"`
+	Bans         string `env:"ES_BANS,default=bans"`
+	AdStats      string `env:"ES_AD_STATS,default=ad_stats"`
+	AdViewers    string `env:"ES_AD_VIEWERS,default=ad_viewers"`
+	Updates      string `env:"ES_UPDATES,default=feed_updates"`
+	// PromotionWeight is a weight of a house ad among the others.
+	PromotionWeight int `env:"PROMOTION_WEIGHT,default=100"`
+	// LegacyPromotion is a list which used to rotate promoted posts, the migration drops it.
+	LegacyPromotion string `env:"ES_PROMOTION,default=promotion"`
 }
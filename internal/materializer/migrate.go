@@ -0,0 +1,128 @@
+package materializer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/storage"
+)
+
+// migrationBatch is a number of events or entries read at once while migrating.
+const migrationBatch = 1000
+
+// Migrate brings data written by earlier versions of the service up to date. It's safe to run it again, and it has
+// to be run before the materializer starts.
+func (s *service) Migrate() error {
+	ctx := s.ctx
+
+	if err := s.migrateFeed(ctx); err != nil {
+		return fmt.Errorf("couldn't migrate the feed: %w", err)
+	}
+	if err := s.migratePromotion(ctx); err != nil {
+		return fmt.Errorf("couldn't migrate the rotation: %w", err)
+	}
+	return nil
+}
+
+// migratePromotion drops the list which used to rotate promoted posts. Rotations are weighted now, and the list
+// cannot be turned into one, since posts are promoted by campaigns. Posts of the list stay available by their
+// identifiers.
+func (s *service) migratePromotion(ctx context.Context) error {
+	kind, err := s.client.Type(ctx, s.cfg.LegacyPromotion).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't check the legacy rotation: %w", err)
+	}
+	// The key may be taken by something else if it's configured so.
+	if kind != "list" {
+		return nil
+	}
+	entries, err := s.client.LLen(ctx, s.cfg.LegacyPromotion).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't read the legacy rotation: %w", err)
+	}
+	if err := s.client.Del(ctx, s.cfg.LegacyPromotion).Err(); err != nil {
+		return fmt.Errorf("couldn't drop the legacy rotation: %w", err)
+	}
+	zerolog.Ctx(ctx).Warn().Int64("entries", entries).Msg("Dropped the legacy rotation of promoted posts")
+	return nil
+}
+
+// migrateFeed replaces posts which the feed used to keep as JSON by identifiers. Such posts have no identifiers, so
+// they're materialized again from their events like legacy events are while replaying the stream.
+func (s *service) migrateFeed(ctx context.Context) error {
+	legacy, err := s.legacyFeed(ctx)
+	if err != nil {
+		return err
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+	zerolog.Ctx(ctx).Info().Int("entries", len(legacy)).Msg("Migrating legacy entries of the feed")
+
+	start := "-"
+	for len(legacy) != 0 {
+		messages, err := s.client.XRangeN(ctx, s.cfg.Stream, start, "+", migrationBatch).Result()
+		if err != nil {
+			return fmt.Errorf("couldn't read the stream: %w", err)
+		}
+		for _, message := range messages {
+			// The range includes the message it starts from, which has been handled already.
+			if message.ID == start {
+				continue
+			}
+			blob, _ := message.Values[storage.StreamValueField].(string)
+			if _, ok := legacy[blob]; !ok {
+				continue
+			}
+			if err := s.postSubmitted(ctx, message.ID, blob); err != nil {
+				return err
+			}
+			if err := s.client.ZRem(ctx, s.cfg.Feed, blob).Err(); err != nil {
+				return fmt.Errorf("couldn't remove a legacy entry: %w", err)
+			}
+			delete(legacy, blob)
+		}
+		if len(messages) < migrationBatch {
+			break
+		}
+		start = messages[len(messages)-1].ID
+	}
+
+	// Nothing refers to entries without events, so they cannot be served anyway.
+	if len(legacy) != 0 {
+		members := make([]interface{}, 0, len(legacy))
+		for blob := range legacy {
+			members = append(members, blob)
+		}
+		if err := s.client.ZRem(ctx, s.cfg.Feed, members...).Err(); err != nil {
+			return fmt.Errorf("couldn't remove legacy entries: %w", err)
+		}
+		zerolog.Ctx(ctx).Warn().Int("entries", len(legacy)).Msg("Dropped legacy entries of the feed without events")
+	}
+	return nil
+}
+
+// legacyFeed returns posts which the feed keeps as JSON.
+func (s *service) legacyFeed(ctx context.Context) (map[string]struct{}, error) {
+	legacy := map[string]struct{}{}
+	var cursor uint64
+	for {
+		// A reply alternates members and their scores.
+		pairs, next, err := s.client.ZScan(ctx, s.cfg.Feed, cursor, "{*", migrationBatch).Result()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't scan the feed: %w", err)
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			if strings.HasPrefix(pairs[i], "{") {
+				legacy[pairs[i]] = struct{}{}
+			}
+		}
+		if next == 0 {
+			return legacy, nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,97 @@
+package materializer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/storage"
+)
+
+func TestMigrate(t *testing.T) {
+	Convey("Test migrating legacy data", t, func() {
+		m := &mock.Mock{}
+		srv := mockService(m, &Config{
+			Stream: "posts", Feed: "feed", Posts: "post_by_id", Submitted: "submitted", Bans: "bans", LegacyPromotion: "promotion",
+		})
+		m.
+			On("HGet", mock.Anything, mock.Anything, "t2_abcdefg2").Return(redis.NewStringResult("", redis.Nil)).Maybe()
+
+		legacy := `{"title":"Old","author":"t2_abcdefg2","subreddit":"golang","score":5,"promoted":false,"nsfw":false}`
+
+		Convey("Nothing happens if the feed is up to date", func() {
+			m.
+				On("ZScan", mock.Anything, "feed", uint64(0), "{*", int64(1000)).Return(redis.NewScanCmdResult(nil, 0, nil)).
+				On("Type", mock.Anything, "promotion").Return(redis.NewStatusResult("none", nil))
+
+			So(srv.Migrate(), ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("The legacy rotation is dropped", func() {
+			m.
+				On("ZScan", mock.Anything, "feed", uint64(0), "{*", int64(1000)).Return(redis.NewScanCmdResult(nil, 0, nil)).
+				On("Type", mock.Anything, "promotion").Return(redis.NewStatusResult("list", nil)).
+				On("LLen", mock.Anything, "promotion").Return(redis.NewIntResult(3, nil)).
+				On("Del", mock.Anything, []string{"promotion"}).Return(redis.NewIntResult(1, nil))
+
+			So(srv.Migrate(), ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A key of another type is left alone", func() {
+			m.
+				On("ZScan", mock.Anything, "feed", uint64(0), "{*", int64(1000)).Return(redis.NewScanCmdResult(nil, 0, nil)).
+				On("Type", mock.Anything, "promotion").Return(redis.NewStatusResult("hash", nil))
+
+			So(srv.Migrate(), ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if the stream cannot be read", func() {
+			m.
+				On("ZScan", mock.Anything, "feed", uint64(0), "{*", int64(1000)).Return(redis.NewScanCmdResult([]string{legacy, "5"}, 0, nil)).
+				On("XRangeN", mock.Anything, "posts", "-", "+", int64(1000)).Return(redis.NewXMessageSliceCmdResult(nil, errors.New("error")))
+
+			err := srv.Migrate()
+
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldEqual, "couldn't migrate the feed: couldn't read the stream: error")
+		})
+
+		Convey("Successful story", func() {
+			orphan := `{"title":"Lost","score":1}`
+			m.
+				On("ZScan", mock.Anything, "feed", uint64(0), "{*", int64(1000)).Return(redis.NewScanCmdResult([]string{legacy, "5"}, 7, nil)).
+				On("ZScan", mock.Anything, "feed", uint64(7), "{*", int64(1000)).Return(redis.NewScanCmdResult([]string{orphan, "1"}, 0, nil)).
+				On("XRangeN", mock.Anything, "posts", "-", "+", int64(1000)).Return(redis.NewXMessageSliceCmdResult([]redis.XMessage{
+				{ID: "1611000000000-0", Values: map[string]interface{}{storage.StreamValueField: `{"title":"Other","score":1}`}},
+				{ID: "1612000000500-1", Values: map[string]interface{}{storage.StreamValueField: legacy}},
+			}, nil)).
+				On("HSet", mock.Anything, "post_by_id", mock.Anything).Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "submitted:t2_abcdefg2", []*redis.Z{{Score: 1612000000, Member: "1612000000500-1"}}).Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "feed", []*redis.Z{{Score: 5, Member: "1612000000500-1"}}).Return(redis.NewIntResult(1, nil)).
+				On("ZAdd", mock.Anything, "feed:golang", []*redis.Z{{Score: 5, Member: "1612000000500-1"}}).Return(redis.NewIntResult(1, nil)).
+				On("ZRem", mock.Anything, "feed", []interface{}{legacy}).Return(redis.NewIntResult(1, nil)).
+				On("ZRem", mock.Anything, "feed", []interface{}{orphan}).Return(redis.NewIntResult(1, nil)).
+				On("Type", mock.Anything, "promotion").Return(redis.NewStatusResult("none", nil))
+
+			So(srv.Migrate(), ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			// The legacy post takes the identifier and the time of its event.
+			var values []interface{}
+			for _, call := range m.Calls {
+				if call.Method == "HSet" {
+					values = call.Arguments.Get(2).([]interface{})
+				}
+			}
+			So(values[0], ShouldEqual, "1612000000500-1")
+			So(values[1], assertions.ShouldEqualJSON, `{"id":"1612000000500-1","title":"Old","author":"t2_abcdefg2","subreddit":"golang",
+				"score":5,"promoted":false,"nsfw":false,"num_comments":0,"created":1612000000}`)
+		})
+	})
+}
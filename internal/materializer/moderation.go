@@ -0,0 +1,142 @@
+package materializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
+)
+
+// listed tells whether a post belongs to the feeds. Promoted posts go to the rotation instead, and removed posts,
+// shadowbanned posts and posts waiting for moderators go nowhere.
+func listed(post *protocol.Post) bool {
+	queued := post.Spam != nil && post.Spam.Outcome == protocol.SpamQueue && !post.Approved
+	return !post.Promoted && !post.Removed && !post.Shadowbanned && !queued
+}
+
+// unlist removes a post from the feeds, the rotation of promoted posts and house ads, and it tells live feeds.
+func (s *service) unlist(ctx context.Context, post *protocol.Post) error {
+	for _, key := range []string{s.cfg.Feed, storage.SubredditFeedKey(s.cfg.Feed, post.Subreddit)} {
+		if err := s.client.ZRem(ctx, key, post.ID).Err(); err != nil {
+			return fmt.Errorf("couldn't remove a post from the feed: %w", err)
+		}
+	}
+	for _, key := range []string{s.cfg.Promotion, s.cfg.HouseAds} {
+		if err := s.client.HDel(ctx, key, post.ID).Err(); err != nil {
+			return fmt.Errorf("couldn't remove a post from the rotation: %w", err)
+		}
+	}
+	if !post.Promoted {
+		s.notify(ctx, protocol.FeedUpdateRemoval, post)
+	}
+	return nil
+}
+
+// dequeue removes a post from the moderation queue along with its reports.
+func (s *service) dequeue(ctx context.Context, post *protocol.Post) error {
+	if err := s.client.ZRem(ctx, storage.ModQueueKey(s.cfg.ModQueue, post.Subreddit), post.ID).Err(); err != nil {
+		return fmt.Errorf("couldn't remove a post from the moderation queue: %w", err)
+	}
+	if err := s.client.Del(ctx, storage.ReportsKey(s.cfg.Reports, post.ID)).Err(); err != nil {
+		return fmt.Errorf("couldn't remove reports: %w", err)
+	}
+	return nil
+}
+
+func (s *service) postReported(ctx context.Context, blob string) error {
+	var report protocol.PostReported
+	if err := json.Unmarshal([]byte(blob), &report); err != nil {
+		return fmt.Errorf("couldn't unmarshal a report: %w", err)
+	}
+
+	post, err := s.loadPost(ctx, report.ID)
+	if err != nil {
+		return err
+	}
+	if post == nil || post.Deleted || post.Removed {
+		zerolog.Ctx(ctx).Warn().Str("id", report.ID).Msg("Skipping a report of a missing post")
+		return nil
+	}
+
+	// Every user reports a post once, so a single user cannot flood moderators.
+	added, err := s.client.HSetNX(ctx, storage.ReportsKey(s.cfg.Reports, post.ID), report.Reporter, report.Reason).Result()
+	if err != nil {
+		return fmt.Errorf("couldn't save a report: %w", err)
+	}
+	if !added {
+		return nil
+	}
+	// A post keeps its place in the queue when it's reported again.
+	if err := s.client.ZAddNX(ctx, storage.ModQueueKey(s.cfg.ModQueue, post.Subreddit), &redis.Z{
+		Score:  float64(report.Reported),
+		Member: post.ID,
+	}).Err(); err != nil {
+		return fmt.Errorf("couldn't put a post into the moderation queue: %w", err)
+	}
+	return nil
+}
+
+func (s *service) postModerated(ctx context.Context, blob string) error {
+	var action protocol.ModAction
+	if err := json.Unmarshal([]byte(blob), &action); err != nil {
+		return fmt.Errorf("couldn't unmarshal a moderation action: %w", err)
+	}
+
+	post, err := s.loadPost(ctx, action.Target)
+	if err != nil {
+		return err
+	}
+	if post == nil || post.Deleted {
+		zerolog.Ctx(ctx).Warn().Str("id", action.Target).Msg("Skipping a moderation of a missing post")
+		return nil
+	}
+
+	wasListed := listed(post)
+	switch action.Action {
+	case protocol.ModApprove:
+		post.Removed = false
+		post.Approved = true
+	case protocol.ModRemove, protocol.ModSpam:
+		post.Removed = true
+		post.Approved = false
+	default:
+		zerolog.Ctx(ctx).Warn().Str("action", action.Action).Str("id", post.ID).Msg("Skipping an unknown moderation action")
+		return nil
+	}
+	if err := s.savePost(ctx, post); err != nil {
+		return err
+	}
+	if err := s.dequeue(ctx, post); err != nil {
+		return err
+	}
+
+	switch {
+	case post.Removed:
+		if err := s.unlist(ctx, post); err != nil {
+			return err
+		}
+		if action.Action == protocol.ModSpam {
+			return s.addReputation(ctx, post.Domain, storage.ReputationSpam)
+		}
+		return nil
+	case post.HouseAd:
+		return s.promote(ctx, post)
+	case !listed(post):
+		// Posts of campaigns are left for the scheduler, and posts of shadowbanned authors are never listed.
+		return nil
+	default:
+		if err := s.rank(ctx, post); err != nil {
+			return err
+		}
+		// An approved post may have been listed already, like a reported one.
+		if !wasListed {
+			s.notify(ctx, protocol.FeedUpdateNew, post)
+		}
+		return nil
+	}
+}
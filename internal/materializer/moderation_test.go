@@ -0,0 +1,217 @@
+package materializer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/storage"
+)
+
+func TestModeration(t *testing.T) {
+	Convey("Test materializing moderation", t, func() {
+		m := &mock.Mock{}
+		srv := mockService(m, &Config{
+			Feed: "feed", Promotion: "promotion_weights", HouseAds: "house_ads", Posts: "post_by_id", Reputation: "reputation",
+			ModQueue: "modqueue", Reports: "reports",
+		})
+
+		Convey("A report", func() {
+			const blob = `{"id":"1a","reporter":"t2_abcdefg3","reason":"spam","reported":100}`
+
+			Convey("It fails if an event payload is undecryptable", func() {
+				mockEvent(m, storage.EventPostReported, "")
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't unmarshal a report: unexpected end of JSON input`)
+			})
+
+			Convey("A report of a removed post is skipped", func() {
+				mockEvent(m, storage.EventPostReported, blob)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang","removed":true}`, nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("A repeated report changes nothing", func() {
+				mockEvent(m, storage.EventPostReported, blob)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang"}`, nil)).
+					On("HSetNX", mock.Anything, "reports:1a", "t2_abcdefg3", "spam").
+					Return(redis.NewBoolResult(false, nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("Successful story", func() {
+				mockEvent(m, storage.EventPostReported, blob)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang"}`, nil)).
+					On("HSetNX", mock.Anything, "reports:1a", "t2_abcdefg3", "spam").
+					Return(redis.NewBoolResult(true, nil)).
+					On("ZAddNX", mock.Anything, "modqueue:golang", []*redis.Z{{Score: 100, Member: "1a"}}).
+					Return(redis.NewIntResult(1, nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+		})
+
+		Convey("A moderation action", func() {
+			dequeue := func() {
+				m.
+					On("ZRem", mock.Anything, "modqueue:golang", []interface{}{"1a"}).
+					Return(redis.NewIntResult(1, nil)).
+					On("Del", mock.Anything, []string{"reports:1a"}).
+					Return(redis.NewIntResult(1, nil))
+			}
+
+			Convey("It fails if an event payload is undecryptable", func() {
+				mockEvent(m, storage.EventPostModerated, "")
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't unmarshal a moderation action: unexpected end of JSON input`)
+			})
+
+			Convey("It fails if a post cannot be removed from the queue", func() {
+				mockEvent(m, storage.EventPostModerated, `{"subreddit":"GoLang","moderator":"t2_abcdefg2","action":"remove","target":"1a","created":100}`)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang"}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZRem", mock.Anything, "modqueue:golang", []interface{}{"1a"}).
+					Return(redis.NewIntResult(0, errors.New("error")))
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't remove a post from the moderation queue: error`)
+			})
+
+			Convey("A post removed as spam leaves the listings and spoils the reputation of its domain", func() {
+				mockEvent(m, storage.EventPostModerated, `{"subreddit":"GoLang","moderator":"t2_abcdefg2","action":"spam","target":"1a","created":100}`)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","title":"title","subreddit":"GoLang","domain":"spam.com","created":50}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZRem", mock.Anything, "feed", []interface{}{"1a"}).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZRem", mock.Anything, "feed:golang", []interface{}{"1a"}).
+					Return(redis.NewIntResult(1, nil)).
+					On("HDel", mock.Anything, "promotion_weights", []string{"1a"}).
+					Return(redis.NewIntResult(0, nil)).
+					On("HDel", mock.Anything, "house_ads", []string{"1a"}).
+					Return(redis.NewIntResult(0, nil)).
+					On("HIncrBy", mock.Anything, "reputation:spam.com", storage.ReputationSpam, int64(1)).
+					Return(redis.NewIntResult(1, nil))
+				dequeue()
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				values := m.Calls[2].Arguments.Get(2).([]interface{})
+				So(string(values[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"title","author":"","subreddit":"GoLang","domain":"spam.com","score":0,"promoted":false,"nsfw":false,"num_comments":0,"created":50,"removed":true}`)
+			})
+
+			Convey("An approved post goes to the feed", func() {
+				mockEvent(m, storage.EventPostModerated, `{"subreddit":"GoLang","moderator":"t2_abcdefg2","action":"approve","target":"1a","created":100}`)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang","score":5,"spam":{"outcome":"queue","score":1}}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZAdd", mock.Anything, "feed", []*redis.Z{{Score: 5, Member: "1a"}}).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "feed:golang", []*redis.Z{{Score: 5, Member: "1a"}}).
+					Return(redis.NewIntResult(1, nil))
+				dequeue()
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("An approved house ad returns to the rotation", func() {
+				mockEvent(m, storage.EventPostModerated, `{"subreddit":"","moderator":"t2_abcdefg2","action":"approve","target":"1a","created":100}`)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","promoted":true,"house_ad":true,"removed":true}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil)).
+					On("ZRem", mock.Anything, "modqueue:", []interface{}{"1a"}).
+					Return(redis.NewIntResult(1, nil)).
+					On("Del", mock.Anything, []string{"reports:1a"}).
+					Return(redis.NewIntResult(1, nil)).
+					On("HSet", mock.Anything, "house_ads", []interface{}{"1a", 0}).
+					Return(redis.NewIntResult(1, nil))
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("An approved post of a shadowbanned author stays out of the feeds", func() {
+				mockEvent(m, storage.EventPostModerated, `{"subreddit":"GoLang","moderator":"t2_abcdefg2","action":"approve","target":"1a","created":100}`)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang","shadowbanned":true,"spam":{"score":5,"outcome":"queue"}}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil))
+				dequeue()
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				m.AssertNotCalled(t, "ZAdd", mock.Anything, mock.Anything, mock.Anything)
+				m.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			Convey("A post its author has promoted isn't a house ad", func() {
+				mockEvent(m, storage.EventPostModerated, `{"subreddit":"GoLang","moderator":"t2_abcdefg2","action":"approve","target":"1a","created":100}`)
+				m.
+					On("HGet", mock.Anything, "post_by_id", "1a").
+					Return(redis.NewStringResult(`{"id":"1a","subreddit":"GoLang","promoted":true,"removed":true}`, nil)).
+					On("HSet", mock.Anything, "post_by_id", mock.Anything).
+					Return(redis.NewIntResult(0, nil))
+				dequeue()
+				mockStop(m)
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+				m.AssertNotCalled(t, "HSet", mock.Anything, "house_ads", mock.Anything)
+			})
+		})
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
@@ -18,6 +21,7 @@ type service struct {
 	cfg    *Config
 	client redis.Cmdable
 	decode func(data []byte, v interface{}) error
+	now    func() time.Time
 }
 
 func (s *service) Execute() error {
@@ -39,36 +43,343 @@ func (s *service) Execute() error {
 			return fmt.Errorf("unexpected number of streams: %d", len(streams))
 		}
 
-		// We've got a bunch of messages, so the next step is extracting original posts.
+		// We've got a bunch of messages, so the next step is extracting original events.
 		for _, message := range streams[0].Messages {
 			blob, ok := message.Values[storage.StreamValueField].(string)
 			if !ok {
 				//TODO What will we do with the other messages? I believe it's a place for variate decisions.
 				return fmt.Errorf("couldn't find an event in a message: %v", message)
 			}
-			var post protocol.Post
-			if err := json.Unmarshal([]byte(blob), &post); err != nil {
-				return fmt.Errorf("couldn't unmarshal a saved post: %w", err)
-			}
+			eventType, _ := message.Values[storage.StreamTypeField].(string)
 
-			if post.Promoted {
-				// Promoted posts go to a circular list.
-				if err := s.client.LPush(ctx, s.cfg.Promotion, blob).Err(); err != nil {
-					return fmt.Errorf("couldn't put a promoted post into a ring: %w", err)
-				}
-				continue
+			var err error
+			switch eventType {
+			case "", storage.EventPostSubmitted:
+				err = s.postSubmitted(ctx, message.ID, blob)
+			case storage.EventPostEdited:
+				err = s.postEdited(ctx, blob)
+			case storage.EventPostDeleted:
+				err = s.postDeleted(ctx, blob)
+			case storage.EventPostVoted:
+				err = s.postVoted(ctx, blob)
+			case storage.EventPostReported:
+				err = s.postReported(ctx, blob)
+			case storage.EventPostModerated:
+				err = s.postModerated(ctx, blob)
+			case storage.EventCommentSubmitted:
+				err = s.commentSubmitted(ctx, blob)
+			case storage.EventCommentVoted:
+				err = s.commentVoted(ctx, blob)
+			case storage.EventAdImpressed:
+				err = s.adImpressed(ctx, blob)
+			case storage.EventAdClicked:
+				err = s.adClicked(ctx, blob)
+			default:
+				zerolog.Ctx(ctx).Warn().Str("type", eventType).Str("id", message.ID).Msg("Skipping an event of unknown type")
 			}
-			// Ordinary posts should be kept in a sorted set.
-			if err := s.client.ZAdd(ctx, s.cfg.Feed, &redis.Z{
-				Score:  float64(post.Score),
-				Member: blob,
-			}).Err(); err != nil {
-				return fmt.Errorf("couldn't put a post into the feed: %w", err)
+			if err != nil {
+				return err
 			}
 		}
 	}
 }
 
+// postSubmitted materializes a post of the event with the given identifier.
+func (s *service) postSubmitted(ctx context.Context, eventID, blob string) error {
+	var post protocol.Post
+	if err := json.Unmarshal([]byte(blob), &post); err != nil {
+		return fmt.Errorf("couldn't unmarshal a saved post: %w", err)
+	}
+	if adoptLegacy(eventID, &post) {
+		b, err := json.Marshal(&post)
+		if err != nil {
+			return fmt.Errorf("couldn't marshal a legacy post: %w", err)
+		}
+		blob = string(b)
+	}
+	outcome := protocol.SpamAccept
+	if post.Spam != nil {
+		outcome = post.Spam.Outcome
+	}
+	if outcome == protocol.SpamReject {
+		// A rejected post stays in the stream only, but it spoils the reputation of its domain.
+		return s.addReputation(ctx, post.Domain, storage.ReputationSpam)
+	}
+	ban, err := s.banned(ctx, &post)
+	if err != nil {
+		return err
+	}
+	if ban != nil && !ban.Shadow {
+		zerolog.Ctx(ctx).Info().Str("id", post.ID).Str("author", post.Author).Msg("Skipping a post of a banned author")
+		return nil
+	}
+	demoted := post.Promoted && post.Campaign == "" && !post.HouseAd
+	if demoted {
+		// Only campaigns and administrators promote posts, earlier versions let authors promote theirs. Such a
+		// post is an ordinary one.
+		post.Promoted = false
+	}
+	if ban != nil {
+		// A post of a shadowbanned author is kept for the author only, it never reaches listings.
+		post.Shadowbanned = true
+	}
+	if demoted || ban != nil {
+		b, err := json.Marshal(&post)
+		if err != nil {
+			return fmt.Errorf("couldn't marshal a post: %w", err)
+		}
+		blob = string(b)
+	}
+
+	// Every post is kept by its identifier, indexes refer to it.
+	if err := s.client.HSet(ctx, s.cfg.Posts, post.ID, blob).Err(); err != nil {
+		return fmt.Errorf("couldn't save a post: %w", err)
+	}
+	index := storage.SubmittedKey(s.cfg.Submitted, post.Author)
+	if post.Shadowbanned {
+		// Posts of shadowbanned authors are indexed apart, so listings of others never have to skip them.
+		index = storage.ShadowbannedKey(s.cfg.Shadowbanned, post.Author)
+	}
+	if err := s.client.ZAdd(ctx, index, &redis.Z{
+		Score:  float64(post.Created),
+		Member: post.ID,
+	}).Err(); err != nil {
+		return fmt.Errorf("couldn't put a post into the author's index: %w", err)
+	}
+	if post.Shadowbanned {
+		return nil
+	}
+	if post.Link != "" {
+		if err := s.client.ZAdd(ctx, storage.LinkKey(s.cfg.Links, post.Link), &redis.Z{
+			Score:  float64(post.Created),
+			Member: post.ID,
+		}).Err(); err != nil {
+			return fmt.Errorf("couldn't put a post into the link's index: %w", err)
+		}
+	}
+	if err := s.addReputation(ctx, post.Domain, storage.ReputationPosts); err != nil {
+		return err
+	}
+
+	if outcome == protocol.SpamQueue {
+		// A suspicious post waits for moderators instead of listings.
+		if err := s.client.ZAdd(ctx, storage.ModQueueKey(s.cfg.ModQueue, post.Subreddit), &redis.Z{
+			Score:  float64(post.Created),
+			Member: post.ID,
+		}).Err(); err != nil {
+			return fmt.Errorf("couldn't put a post into the moderation queue: %w", err)
+		}
+		return nil
+	}
+	if post.Promoted {
+		// Posts of campaigns are put into the rotation by the ads scheduler once their campaigns are live.
+		if post.HouseAd {
+			return s.promote(ctx, &post)
+		}
+		return nil
+	}
+	// Ordinary posts should be kept in sorted sets.
+	if err := s.rank(ctx, &post); err != nil {
+		return err
+	}
+	s.notify(ctx, protocol.FeedUpdateNew, &post)
+	return nil
+}
+
+// adoptLegacy gives a post submitted before the service issued identifiers the identifier of its event, and the
+// time of the event unless the post has one. The stream keeps events, so replaying it gives the same identifiers.
+// It returns false if a post isn't a legacy one.
+func adoptLegacy(eventID string, post *protocol.Post) bool {
+	if post.ID != "" || eventID == "" {
+		return false
+	}
+	post.ID = eventID
+	if post.Created == 0 {
+		// Identifiers of events start with milliseconds of the time they've been added at.
+		if ms, err := strconv.ParseInt(strings.SplitN(eventID, "-", 2)[0], 10, 64); err == nil {
+			post.Created = ms / 1000
+		}
+	}
+	return true
+}
+
+// promote puts a house ad into the rotation of house ads. House ads fill slots which no campaign may take.
+func (s *service) promote(ctx context.Context, post *protocol.Post) error {
+	if err := s.client.HSet(ctx, s.cfg.HouseAds, post.ID, s.cfg.PromotionWeight).Err(); err != nil {
+		return fmt.Errorf("couldn't put a house ad into the rotation: %w", err)
+	}
+	return nil
+}
+
+// addReputation counts a post of a domain. Self posts have no domain.
+func (s *service) addReputation(ctx context.Context, domain, counter string) error {
+	if domain == "" {
+		return nil
+	}
+	if err := s.client.HIncrBy(ctx, storage.ReputationKey(s.cfg.Reputation, domain), counter, 1).Err(); err != nil {
+		return fmt.Errorf("couldn't update a reputation: %w", err)
+	}
+	return nil
+}
+
+// rank puts a post into the global feed and the feed of its subreddit according to the score.
+func (s *service) rank(ctx context.Context, post *protocol.Post) error {
+	for _, key := range []string{s.cfg.Feed, storage.SubredditFeedKey(s.cfg.Feed, post.Subreddit)} {
+		if err := s.client.ZAdd(ctx, key, &redis.Z{
+			Score:  float64(post.Score),
+			Member: post.ID,
+		}).Err(); err != nil {
+			return fmt.Errorf("couldn't put a post into the feed: %w", err)
+		}
+	}
+	return nil
+}
+
+// notify publishes an update of the feeds for live feeds. Live feeds are best-effort, so a failure doesn't stop
+// the materializer.
+func (s *service) notify(ctx context.Context, kind string, post *protocol.Post) {
+	update := protocol.FeedUpdate{Type: kind, ID: post.ID, Subreddit: post.Subreddit}
+	if kind != protocol.FeedUpdateRemoval {
+		public := post.Public()
+		update.Post = &public
+	}
+	blob, err := json.Marshal(&update)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't marshal a feed update")
+		return
+	}
+	if err := s.client.Publish(ctx, s.cfg.Updates, blob).Err(); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("id", post.ID).Msg("Couldn't publish a feed update")
+	}
+}
+
+// loadPost fetches a materialized post. It returns nil if there is no such post.
+func (s *service) loadPost(ctx context.Context, id string) (*protocol.Post, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Posts, id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("couldn't load a post: %w", err)
+	}
+	var post protocol.Post
+	if err := json.Unmarshal([]byte(blob), &post); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal a saved post: %w", err)
+	}
+	return &post, nil
+}
+
+func (s *service) savePost(ctx context.Context, post *protocol.Post) error {
+	blob, err := json.Marshal(post)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal a post: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.cfg.Posts, post.ID, blob).Err(); err != nil {
+		return fmt.Errorf("couldn't save a post: %w", err)
+	}
+	return nil
+}
+
+func (s *service) postEdited(ctx context.Context, blob string) error {
+	var edit protocol.PostEdited
+	if err := json.Unmarshal([]byte(blob), &edit); err != nil {
+		return fmt.Errorf("couldn't unmarshal an edit: %w", err)
+	}
+
+	post, err := s.loadPost(ctx, edit.ID)
+	if err != nil {
+		return err
+	}
+	// Handlers check that a post exists, but it may be deleted while an edit is in flight.
+	if post == nil || post.Deleted {
+		zerolog.Ctx(ctx).Warn().Str("id", edit.ID).Msg("Skipping an edit of a missing post")
+		return nil
+	}
+
+	if edit.Spam != nil && edit.Spam.Outcome == protocol.SpamReject {
+		// A rejected edit stays in the stream only, and the post is kept as it was.
+		zerolog.Ctx(ctx).Info().Str("id", edit.ID).Msg("Skipping a rejected edit")
+		return nil
+	}
+
+	wasListed := listed(post)
+	if edit.Title != nil {
+		post.Title = *edit.Title
+	}
+	if edit.Content != nil {
+		post.Content = *edit.Content
+	}
+	post.Edited = edit.Edited
+	if edit.NSFWOverride != nil && !post.NSFW {
+		post.NSFW = true
+		post.NSFWOverride = edit.NSFWOverride
+	}
+	if wasListed && edit.Spam != nil && edit.Spam.Outcome == protocol.SpamQueue {
+		// A suspicious edit takes a post back to moderators, even an approved one.
+		post.Spam = edit.Spam
+		post.Approved = false
+	}
+	if err := s.savePost(ctx, post); err != nil {
+		return err
+	}
+	if !wasListed || listed(post) {
+		return nil
+	}
+	if err := s.unlist(ctx, post); err != nil {
+		return err
+	}
+	// A post keeps its place in the queue if it's been reported already.
+	if err := s.client.ZAddNX(ctx, storage.ModQueueKey(s.cfg.ModQueue, post.Subreddit), &redis.Z{
+		Score:  float64(post.Edited),
+		Member: post.ID,
+	}).Err(); err != nil {
+		return fmt.Errorf("couldn't put a post into the moderation queue: %w", err)
+	}
+	return nil
+}
+
+func (s *service) postDeleted(ctx context.Context, blob string) error {
+	var deletion protocol.PostDeleted
+	if err := json.Unmarshal([]byte(blob), &deletion); err != nil {
+		return fmt.Errorf("couldn't unmarshal a deletion: %w", err)
+	}
+
+	post, err := s.loadPost(ctx, deletion.ID)
+	if err != nil {
+		return err
+	}
+	if post == nil || post.Deleted {
+		zerolog.Ctx(ctx).Warn().Str("id", deletion.ID).Msg("Skipping a deletion of a missing post")
+		return nil
+	}
+
+	// A deleted post disappears from listings but it's still resolvable by its identifier.
+	if err := s.unlist(ctx, post); err != nil {
+		return err
+	}
+	index := storage.SubmittedKey(s.cfg.Submitted, post.Author)
+	if post.Shadowbanned {
+		index = storage.ShadowbannedKey(s.cfg.Shadowbanned, post.Author)
+	}
+	if err := s.client.ZRem(ctx, index, post.ID).Err(); err != nil {
+		return fmt.Errorf("couldn't remove a post from the author's index: %w", err)
+	}
+	if post.Link != "" {
+		if err := s.client.ZRem(ctx, storage.LinkKey(s.cfg.Links, post.Link), post.ID).Err(); err != nil {
+			return fmt.Errorf("couldn't remove a post from the link's index: %w", err)
+		}
+	}
+
+	post.Title = protocol.DeletedMarker
+	post.Author = protocol.DeletedMarker
+	post.Content = protocol.DeletedMarker
+	post.Link = ""
+	post.Flair = ""
+	post.Deleted = true
+	post.Edited = deletion.Deleted
+	return s.savePost(ctx, post)
+}
+
 func (s *service) Interrupt(err error) {
 	s.cancel()
 }
@@ -83,5 +394,6 @@ func NewService(ctx context.Context, cancel context.CancelFunc, client redis.Cmd
 		client: client,
 		cfg:    cfg,
 		decode: json.Unmarshal,
+		now:    time.Now,
 	}
 }
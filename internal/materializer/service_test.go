@@ -6,10 +6,12 @@ import (
 	"testing"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/smartystreets/assertions"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/mock"
 
 	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
 )
 
 type mockRedis struct {
@@ -23,24 +25,119 @@ func (m *mockRedis) XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *re
 	return args.Get(0).(*redis.XStreamSliceCmd)
 }
 
-func (m *mockRedis) LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+func (m *mockRedis) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
 	args := m.m.Called(ctx, key, values)
 	return args.Get(0).(*redis.IntCmd)
 }
 
+func (m *mockRedis) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	args := m.m.Called(ctx, key, field)
+	return args.Get(0).(*redis.StringCmd)
+}
+
+func (m *mockRedis) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	args := m.m.Called(ctx, key, members)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	args := m.m.Called(ctx, key, fields)
+	return args.Get(0).(*redis.IntCmd)
+}
+
 func (m *mockRedis) ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
 	args := m.m.Called(ctx, key, members)
 	return args.Get(0).(*redis.IntCmd)
 }
 
+func (m *mockRedis) HIncrBy(ctx context.Context, key, field string, incr int64) *redis.IntCmd {
+	args := m.m.Called(ctx, key, field, incr)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) HSetNX(ctx context.Context, key, field string, value interface{}) *redis.BoolCmd {
+	args := m.m.Called(ctx, key, field, value)
+	return args.Get(0).(*redis.BoolCmd)
+}
+
+func (m *mockRedis) ZAddNX(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	args := m.m.Called(ctx, key, members)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) PFAdd(ctx context.Context, key string, els ...interface{}) *redis.IntCmd {
+	args := m.m.Called(ctx, key, els)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) Type(ctx context.Context, key string) *redis.StatusCmd {
+	args := m.m.Called(ctx, key)
+	return args.Get(0).(*redis.StatusCmd)
+}
+
+func (m *mockRedis) LLen(ctx context.Context, key string) *redis.IntCmd {
+	args := m.m.Called(ctx, key)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := m.m.Called(ctx, keys)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+// mockService makes a materializer over a mocked Redis. Live feeds are best-effort, so any update is accepted, and
+// tests check only updates they care about.
+func mockService(m *mock.Mock, cfg *Config) *service {
+	cfg.Updates = "feed_updates"
+	m.On("Publish", mock.Anything, "feed_updates", mock.Anything).Return(redis.NewIntResult(0, nil)).Maybe()
+	return &service{ctx: context.Background(), cfg: cfg, client: &mockRedis{m: m}}
+}
+
+// mockEvent makes the materializer read an event of the type from the stream.
+func mockEvent(m *mock.Mock, eventType, blob string) {
+	m.
+		On("XReadGroup", mock.Anything, mock.Anything).
+		Return(redis.NewXStreamSliceCmdResult(
+			[]redis.XStream{
+				{Messages: []redis.XMessage{
+					{Values: map[string]interface{}{storage.StreamTypeField: eventType, storage.StreamValueField: blob}},
+				},
+				},
+			}, nil)).Once()
+}
+
+// mockStop makes the materializer stop once it has handled the events.
+func mockStop(m *mock.Mock) {
+	m.
+		On("XReadGroup", mock.Anything, mock.Anything).
+		Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+}
+
 func TestService(t *testing.T) {
 	Convey("Test materializer", t, func() {
 		m := &mock.Mock{}
-		srv := service{
-			cfg:    &Config{},
-			client: &mockRedis{m: m},
+		srv := mockService(m, &Config{
+			Submitted: "submitted", Links: "links", Reputation: "reputation", ModQueue: "modqueue", Bans: "bans",
+			Promotion: "promotion_weights", HouseAds: "house_ads", PromotionWeight: 100,
+		})
+		// Nobody is banned unless a test says otherwise.
+		for _, key := range []string{"bans", "bans:golang"} {
+			m.
+				On("HGet", mock.Anything, key, mock.Anything).
+				Return(redis.NewStringResult("", redis.Nil)).Maybe()
 		}
 
+		Convey("Live updates never have spam decisions", func() {
+			srv.notify(srv.ctx, protocol.FeedUpdateNew, &protocol.Post{
+				ID:        "1a",
+				Subreddit: "golang",
+				Spam:      &protocol.SpamDecision{Outcome: protocol.SpamAccept},
+			})
+
+			So(m.Calls, ShouldHaveLength, 1)
+			So(string(m.Calls[0].Arguments.Get(2).([]byte)), assertions.ShouldEqualJSON, `{"type":"new","id":"1a","subreddit":"golang","post":{"id":"1a","title":"","author":"","subreddit":"golang","score":0,"promoted":false,"nsfw":false,"num_comments":0}}`)
+		})
+
 		Convey("Execute", func() {
 			Convey("Suppress safe errors", func() {
 				m.
@@ -126,16 +223,20 @@ func TestService(t *testing.T) {
 						Return(redis.NewXStreamSliceCmdResult(
 							[]redis.XStream{
 								{Messages: []redis.XMessage{
-									{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true}`}},
+									{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true, "house_ad": true}`}},
 								},
 								},
 							}, nil)).Once().
-						On("LPush", mock.Anything, mock.Anything, mock.Anything).
-						Return(redis.NewIntResult(123, errors.New("error")))
+						On("HSet", mock.Anything, "house_ads", mock.Anything).
+						Return(redis.NewIntResult(0, errors.New("error"))).
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+						Return(redis.NewIntResult(1, nil))
 
 					err := srv.Execute()
 
-					So(err.Error(), ShouldEqual, `couldn't put a promoted post into a ring: error`)
+					So(err.Error(), ShouldEqual, `couldn't put a house ad into the rotation: error`)
 				})
 
 				Convey("Successful story", func() {
@@ -144,18 +245,79 @@ func TestService(t *testing.T) {
 						Return(redis.NewXStreamSliceCmdResult(
 							[]redis.XStream{
 								{Messages: []redis.XMessage{
-									{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true}`}},
+									{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true, "house_ad": true}`}},
+								},
+								},
+							}, nil)).Once().
+						On("HSet", mock.Anything, "house_ads", []interface{}{"", 100}).
+						Return(redis.NewIntResult(1, nil)).Once().
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				})
+
+				Convey("A post its author has promoted is an ordinary one", func() {
+					m.
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult(
+							[]redis.XStream{
+								{Messages: []redis.XMessage{
+									{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1c", "subreddit": "golang", "promoted": true}`}},
 								},
 								},
 							}, nil)).Once().
-						On("LPush", mock.Anything, mock.Anything, mock.Anything).
-						Return(redis.NewIntResult(123, nil)).Once().
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).Twice().
 						On("XReadGroup", mock.Anything, mock.Anything).
 						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
 
 					err := srv.Execute()
 
 					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+					m.AssertNotCalled(t, "HSet", mock.Anything, "house_ads", mock.Anything)
+					for _, call := range m.Calls {
+						if call.Method == "HSet" {
+							values := call.Arguments.Get(2).([]interface{})
+							So(values[1], assertions.ShouldEqualJSON, `{"id":"1c","title":"","author":"","subreddit":"golang","score":0,"promoted":false,"nsfw":false,"num_comments":0}`)
+						}
+					}
+				})
+
+				Convey("A post of a campaign waits for the ads scheduler", func() {
+					m.
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult(
+							[]redis.XStream{
+								{Messages: []redis.XMessage{
+									{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1b", "promoted": true, "campaign": "1a"}`}},
+								},
+								},
+							}, nil)).Once().
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+					m.AssertNotCalled(t, "HSet", mock.Anything, "promotion_weights", mock.Anything)
+					m.AssertNotCalled(t, "HSet", mock.Anything, "house_ads", mock.Anything)
 				})
 			})
 
@@ -170,6 +332,10 @@ func TestService(t *testing.T) {
 								},
 								},
 							}, nil)).Once().
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
 						On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
 						Return(redis.NewIntResult(123, errors.New("error")))
 
@@ -188,14 +354,397 @@ func TestService(t *testing.T) {
 								},
 								},
 							}, nil)).Once().
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
+						On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+						Return(redis.NewIntResult(1, nil)).
 						On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
-						Return(redis.NewIntResult(123, nil)).Once().
+						Return(redis.NewIntResult(123, nil)).Twice().
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				})
+			})
+
+			Convey("An ordinary post goes to the feed of its subreddit as well", func() {
+				srv.cfg.Feed = "feed"
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1a", "subreddit": "GoLang", "score": 5}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HSet", mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "feed", []*redis.Z{{Score: 5, Member: "1a"}}).
+					Return(redis.NewIntResult(1, nil)).Once().
+					On("ZAdd", mock.Anything, "feed:golang", []*redis.Z{{Score: 5, Member: "1a"}}).
+					Return(redis.NewIntResult(1, nil)).Once().
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("A link post goes to the index of its link", func() {
+				link := storage.LinkKey("links", "https://reddit.com/")
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1a", "link": "https://reddit.com/", "created": 50}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HSet", mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+					Return(redis.NewIntResult(1, nil))
+
+				Convey("It fails if the index cannot be updated", func() {
+					m.
+						On("ZAdd", mock.Anything, link, []*redis.Z{{Score: 50, Member: "1a"}}).
+						Return(redis.NewIntResult(0, errors.New("error")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't put a post into the link's index: error`)
+				})
+
+				Convey("Successful story", func() {
+					m.
+						On("ZAdd", mock.Anything, link, []*redis.Z{{Score: 50, Member: "1a"}}).
+						Return(redis.NewIntResult(1, nil)).Once().
+						On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(1, nil)).Twice().
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+				})
+			})
+
+			Convey("A rejected post only spoils the reputation of its domain", func() {
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1a", "link": "https://casino.com/", "domain": "casino.com", "spam": {"outcome": "reject", "score": 2}}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HIncrBy", mock.Anything, "reputation:casino.com", storage.ReputationSpam, int64(1)).
+					Return(redis.NewIntResult(1, nil)).Once().
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("A queued post goes to the moderation queue instead of the feed", func() {
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1a", "subreddit": "GoLang", "content": "content", "created": 50, "spam": {"outcome": "queue", "score": 1}}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HSet", mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+					Return(redis.NewIntResult(1, nil))
+
+				Convey("It fails if the queue cannot be updated", func() {
+					m.
+						On("ZAdd", mock.Anything, "modqueue:golang", []*redis.Z{{Score: 50, Member: "1a"}}).
+						Return(redis.NewIntResult(0, errors.New("error")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't put a post into the moderation queue: error`)
+				})
+
+				Convey("Successful story", func() {
+					m.
+						On("ZAdd", mock.Anything, "modqueue:golang", []*redis.Z{{Score: 50, Member: "1a"}}).
+						Return(redis.NewIntResult(1, nil)).Once().
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+				})
+			})
+
+			Convey("A link post counts for the reputation of its domain", func() {
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1a", "link": "https://reddit.com/", "domain": "reddit.com", "spam": {"outcome": "accept", "score": 0}}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HSet", mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewIntResult(1, nil))
+
+				Convey("It fails if the reputation cannot be updated", func() {
+					m.
+						On("HIncrBy", mock.Anything, "reputation:reddit.com", storage.ReputationPosts, int64(1)).
+						Return(redis.NewIntResult(0, errors.New("error")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't update a reputation: error`)
+				})
+
+				Convey("Successful story", func() {
+					m.
+						On("HIncrBy", mock.Anything, "reputation:reddit.com", storage.ReputationPosts, int64(1)).
+						Return(redis.NewIntResult(1, nil)).Once().
 						On("XReadGroup", mock.Anything, mock.Anything).
 						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
 
 					err := srv.Execute()
 
 					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+				})
+			})
+
+			Convey("It fails if a post cannot be saved", func() {
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamValueField: `{"id": "1a"}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HSet", mock.Anything, mock.Anything, []interface{}{"1a", `{"id": "1a"}`}).
+					Return(redis.NewIntResult(0, errors.New("error")))
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't save a post: error`)
+			})
+
+			Convey("An unknown event is skipped", func() {
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamTypeField: "unknown", storage.StreamValueField: `{}`}},
+							},
+							},
+						}, nil)).Once().
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+				err := srv.Execute()
+
+				So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+				So(m.AssertExpectations(t), ShouldBeTrue)
+			})
+
+			Convey("An edited post", func() {
+				edited := func(blob string) { mockEvent(m, storage.EventPostEdited, blob) }
+
+				Convey("It fails if an event payload is undecryptable", func() {
+					edited("")
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't unmarshal an edit: unexpected end of JSON input`)
+				})
+
+				Convey("It fails if a post cannot be loaded", func() {
+					edited(`{"id": "1a", "title": "new title", "edited": 100}`)
+					m.
+						On("HGet", mock.Anything, mock.Anything, "1a").
+						Return(redis.NewStringResult("", errors.New("error")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't load a post: error`)
+				})
+
+				Convey("An edit of a missing post is skipped", func() {
+					edited(`{"id": "1a", "title": "new title", "edited": 100}`)
+					m.
+						On("HGet", mock.Anything, mock.Anything, "1a").
+						Return(redis.NewStringResult("", redis.Nil)).
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+				})
+
+				Convey("Successful story", func() {
+					edited(`{"id": "1a", "title": "new title", "edited": 100}`)
+					m.
+						On("HGet", mock.Anything, mock.Anything, "1a").
+						Return(redis.NewStringResult(`{"id":"1a","title":"title","author":"t2_abcdefg2","content":"content","created":50}`, nil)).
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(0, nil)).Once().
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+					values := m.Calls[2].Arguments.Get(2).([]interface{})
+					So(values[0], ShouldEqual, "1a")
+					So(string(values[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"new title","author":"t2_abcdefg2","content":"content","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":0,"created":50,"edited":100}`)
+				})
+
+				Convey("An edit can make a post NSFW", func() {
+					edited(`{"id": "1a", "title": "new nsfw title", "edited": 100, "nsfw_override": {"rule": "keyword", "match": "nsfw"}}`)
+					m.
+						On("HGet", mock.Anything, mock.Anything, "1a").
+						Return(redis.NewStringResult(`{"id":"1a","title":"title","author":"t2_abcdefg2","content":"content","created":50}`, nil)).
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(0, nil)).Once().
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+					values := m.Calls[2].Arguments.Get(2).([]interface{})
+					So(string(values[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"new nsfw title","author":"t2_abcdefg2","content":"content","subreddit":"","score":0,"promoted":false,"nsfw":true,"num_comments":0,"created":50,"edited":100,"nsfw_override":{"rule":"keyword","match":"nsfw"}}`)
+				})
+
+				Convey("A rejected edit is skipped", func() {
+					edited(`{"id": "1a", "title": "casino", "edited": 100, "spam": {"outcome": "reject", "score": 3}}`)
+					m.
+						On("HGet", mock.Anything, mock.Anything, "1a").
+						Return(redis.NewStringResult(`{"id":"1a","title":"title","author":"t2_abcdefg2","created":50}`, nil)).
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+				})
+
+				Convey("A suspicious edit takes an approved post back to moderators", func() {
+					edited(`{"id": "1a", "title": "cheap watches", "edited": 100, "spam": {"outcome": "queue", "score": 1}}`)
+					m.
+						On("HGet", mock.Anything, mock.Anything, "1a").
+						Return(redis.NewStringResult(`{"id":"1a","title":"title","author":"t2_abcdefg2","subreddit":"golang","created":50,"approved":true}`, nil)).
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(0, nil)).Once().
+						On("ZRem", mock.Anything, mock.Anything, []interface{}{"1a"}).
+						Return(redis.NewIntResult(1, nil)).
+						On("HDel", mock.Anything, mock.Anything, []string{"1a"}).
+						Return(redis.NewIntResult(0, nil)).
+						On("ZAddNX", mock.Anything, "modqueue:golang", []*redis.Z{{Score: 100, Member: "1a"}}).
+						Return(redis.NewIntResult(1, nil)).
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+					values := m.Calls[2].Arguments.Get(2).([]interface{})
+					So(string(values[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"cheap watches","author":"t2_abcdefg2","subreddit":"golang","score":0,"promoted":false,"nsfw":false,"num_comments":0,"created":50,"edited":100,"spam":{"outcome":"queue","score":1}}`)
+				})
+			})
+
+			Convey("A deleted post", func() {
+				m.
+					On("XReadGroup", mock.Anything, mock.Anything).
+					Return(redis.NewXStreamSliceCmdResult(
+						[]redis.XStream{
+							{Messages: []redis.XMessage{
+								{Values: map[string]interface{}{storage.StreamTypeField: storage.EventPostDeleted, storage.StreamValueField: `{"id": "1a", "deleted": 100}`}},
+							},
+							},
+						}, nil)).Once().
+					On("HGet", mock.Anything, mock.Anything, "1a").
+					Return(redis.NewStringResult(`{"id":"1a","title":"title","author":"t2_abcdefg2","link":"https://reddit.com","created":50}`, nil))
+
+				Convey("It fails if a post cannot be removed from the feed", func() {
+					m.
+						On("ZRem", mock.Anything, mock.Anything, []interface{}{"1a"}).
+						Return(redis.NewIntResult(0, errors.New("error")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't remove a post from the feed: error`)
+				})
+
+				Convey("It fails if a post cannot be removed from the rotation", func() {
+					m.
+						On("ZRem", mock.Anything, mock.Anything, []interface{}{"1a"}).
+						Return(redis.NewIntResult(1, nil)).
+						On("HDel", mock.Anything, "promotion_weights", []string{"1a"}).
+						Return(redis.NewIntResult(0, errors.New("error")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't remove a post from the rotation: error`)
+				})
+
+				Convey("Successful story", func() {
+					m.
+						On("ZRem", mock.Anything, mock.Anything, []interface{}{"1a"}).
+						Return(redis.NewIntResult(1, nil)).
+						On("HDel", mock.Anything, "promotion_weights", []string{"1a"}).
+						Return(redis.NewIntResult(0, nil)).
+						On("HDel", mock.Anything, "house_ads", []string{"1a"}).
+						Return(redis.NewIntResult(0, nil)).
+						On("HSet", mock.Anything, mock.Anything, mock.Anything).
+						Return(redis.NewIntResult(0, nil)).Once().
+						On("XReadGroup", mock.Anything, mock.Anything).
+						Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
+
+					err := srv.Execute()
+
+					So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+					So(m.AssertExpectations(t), ShouldBeTrue)
+					So(m.Calls[6].Arguments.Get(1), ShouldEqual, "feed_updates")
+					So(string(m.Calls[6].Arguments.Get(2).([]byte)), assertions.ShouldEqualJSON, `{"type":"removal","id":"1a","subreddit":""}`)
+					So(m.Calls[8].Arguments.Get(1), ShouldEqual, storage.LinkKey("links", "https://reddit.com"))
+					values := m.Calls[9].Arguments.Get(2).([]interface{})
+					So(values[0], ShouldEqual, "1a")
+					So(string(values[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"[deleted]","author":"[deleted]","content":"[deleted]","subreddit":"","score":0,"promoted":false,"nsfw":false,"num_comments":0,"created":50,"edited":100,"deleted":true}`)
 				})
 			})
 
@@ -206,20 +755,20 @@ func TestService(t *testing.T) {
 						[]redis.XStream{
 							{Messages: []redis.XMessage{
 								{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": false}`}},
-								{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true}`}},
+								{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true, "house_ad": true}`}},
 								{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": false}`}},
-								{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true}`}},
+								{Values: map[string]interface{}{storage.StreamValueField: `{"promoted": true, "house_ad": true}`}},
 							},
 							},
 						}, nil)).Once().
+					On("HSet", mock.Anything, "house_ads", mock.Anything).
+					Return(redis.NewIntResult(1, nil)).Twice().
+					On("HSet", mock.Anything, mock.Anything, mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
+					On("ZAdd", mock.Anything, "submitted:", mock.Anything).
+					Return(redis.NewIntResult(1, nil)).
 					On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
-					Return(redis.NewIntResult(123, nil)).Once().
-					On("LPush", mock.Anything, mock.Anything, mock.Anything).
-					Return(redis.NewIntResult(123, nil)).Once().
-					On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
-					Return(redis.NewIntResult(123, nil)).Once().
-					On("LPush", mock.Anything, mock.Anything, mock.Anything).
-					Return(redis.NewIntResult(123, nil)).Once().
+					Return(redis.NewIntResult(123, nil)).Times(4).
 					On("XReadGroup", mock.Anything, mock.Anything).
 					Return(redis.NewXStreamSliceCmdResult([]redis.XStream{{}}, errors.New("stop")))
 
@@ -230,3 +779,18 @@ func TestService(t *testing.T) {
 		})
 	})
 }
+
+func (m *mockRedis) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	args := m.m.Called(ctx, channel, message)
+	return args.Get(0).(*redis.IntCmd)
+}
+
+func (m *mockRedis) ZScan(ctx context.Context, key string, cursor uint64, match string, count int64) *redis.ScanCmd {
+	args := m.m.Called(ctx, key, cursor, match, count)
+	return args.Get(0).(*redis.ScanCmd)
+}
+
+func (m *mockRedis) XRangeN(ctx context.Context, stream, start, stop string, count int64) *redis.XMessageSliceCmd {
+	args := m.m.Called(ctx, stream, start, stop, count)
+	return args.Get(0).(*redis.XMessageSliceCmd)
+}
@@ -0,0 +1,75 @@
+package materializer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+
+	"nanoreddit/internal/storage"
+	"nanoreddit/pkg/protocol"
+)
+
+// vote remembers the last vote of the voter. Users can change their minds, so we keep the last vote
+// of everyone to apply only the difference. It returns the previous direction and whether it's changed.
+func (s *service) vote(ctx context.Context, votesKey, voter string, direction int) (int, bool, error) {
+	previous, err := s.client.HGet(ctx, votesKey, voter).Int()
+	if err != nil && err != redis.Nil {
+		return 0, false, fmt.Errorf("couldn't load a previous vote: %w", err)
+	}
+	if previous == direction {
+		return previous, false, nil
+	}
+	if err := s.client.HSet(ctx, votesKey, voter, direction).Err(); err != nil {
+		return 0, false, fmt.Errorf("couldn't save a vote: %w", err)
+	}
+	return previous, true, nil
+}
+
+// addKarma credits an author for votes their posts and comments have received.
+func (s *service) addKarma(ctx context.Context, author, kind string, delta int) error {
+	if delta == 0 || author == protocol.DeletedMarker {
+		return nil
+	}
+	if err := s.client.HIncrBy(ctx, storage.KarmaKey(s.cfg.Karma, author), kind, int64(delta)).Err(); err != nil {
+		return fmt.Errorf("couldn't update karma: %w", err)
+	}
+	return nil
+}
+
+func (s *service) postVoted(ctx context.Context, blob string) error {
+	var vote protocol.PostVoted
+	if err := json.Unmarshal([]byte(blob), &vote); err != nil {
+		return fmt.Errorf("couldn't unmarshal a vote: %w", err)
+	}
+
+	post, err := s.loadPost(ctx, vote.PostID)
+	if err != nil {
+		return err
+	}
+	if post == nil || post.Deleted {
+		zerolog.Ctx(ctx).Warn().Str("id", vote.PostID).Msg("Skipping a vote for a missing post")
+		return nil
+	}
+
+	previous, changed, err := s.vote(ctx, s.cfg.PostVotes+":"+post.ID, vote.Voter, vote.Direction)
+	if err != nil || !changed {
+		return err
+	}
+	delta := vote.Direction - previous
+	post.Score += delta
+
+	if err := s.savePost(ctx, post); err != nil {
+		return err
+	}
+	// A new score changes the rank, but only of posts in the feeds.
+	if listed(post) {
+		if err := s.rank(ctx, post); err != nil {
+			return err
+		}
+		s.notify(ctx, protocol.FeedUpdateScore, post)
+	}
+	return s.addKarma(ctx, post.Author, storage.KarmaLink, delta)
+}
@@ -0,0 +1,105 @@
+package materializer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/smartystreets/assertions"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/internal/storage"
+)
+
+func TestPostVotes(t *testing.T) {
+	Convey("Test materializing votes for posts", t, func() {
+		m := &mock.Mock{}
+		srv := mockService(m, &Config{Feed: "feed", Posts: "post_by_id", PostVotes: "post_votes", Karma: "karma"})
+		event := func(blob string) { mockEvent(m, storage.EventPostVoted, blob) }
+
+		const blob = `{"post_id":"1a","voter":"t2_abcdefg3","dir":1}`
+
+		Convey("It fails if an event payload is undecryptable", func() {
+			event("")
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't unmarshal a vote: unexpected end of JSON input`)
+		})
+
+		Convey("A vote for a deleted post is skipped", func() {
+			event(blob)
+			m.
+				On("HGet", mock.Anything, "post_by_id", "1a").
+				Return(redis.NewStringResult(`{"id":"1a","deleted":true}`, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A repeated vote changes nothing", func() {
+			event(blob)
+			m.
+				On("HGet", mock.Anything, "post_by_id", "1a").
+				Return(redis.NewStringResult(`{"id":"1a","author":"t2_abcdefg2","score":1}`, nil)).
+				On("HGet", mock.Anything, "post_votes:1a", "t2_abcdefg3").
+				Return(redis.NewStringResult("1", nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if karma cannot be updated", func() {
+			event(blob)
+			m.
+				On("HGet", mock.Anything, "post_by_id", "1a").
+				Return(redis.NewStringResult(`{"id":"1a","author":"t2_abcdefg2","subreddit":"golang","score":1}`, nil)).
+				On("HGet", mock.Anything, "post_votes:1a", "t2_abcdefg3").
+				Return(redis.NewStringResult("", redis.Nil)).
+				On("HSet", mock.Anything, mock.Anything, mock.Anything).
+				Return(redis.NewIntResult(0, nil)).
+				On("ZAdd", mock.Anything, mock.Anything, mock.Anything).
+				Return(redis.NewIntResult(0, nil)).
+				On("HIncrBy", mock.Anything, "karma:t2_abcdefg2", storage.KarmaLink, int64(1)).
+				Return(redis.NewIntResult(0, errors.New("error")))
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't update karma: error`)
+		})
+
+		Convey("Successful story (a vote is changed)", func() {
+			event(`{"post_id":"1a","voter":"t2_abcdefg3","dir":-1}`)
+			m.
+				On("HGet", mock.Anything, "post_by_id", "1a").
+				Return(redis.NewStringResult(`{"id":"1a","author":"t2_abcdefg2","subreddit":"golang","score":3,"created":50}`, nil)).
+				On("HGet", mock.Anything, "post_votes:1a", "t2_abcdefg3").
+				Return(redis.NewStringResult("1", nil)).
+				On("HSet", mock.Anything, "post_votes:1a", []interface{}{"t2_abcdefg3", -1}).
+				Return(redis.NewIntResult(0, nil)).
+				On("HSet", mock.Anything, "post_by_id", mock.Anything).
+				Return(redis.NewIntResult(0, nil)).
+				On("ZAdd", mock.Anything, "feed", []*redis.Z{{Score: 1, Member: "1a"}}).
+				Return(redis.NewIntResult(0, nil)).
+				On("ZAdd", mock.Anything, "feed:golang", []*redis.Z{{Score: 1, Member: "1a"}}).
+				Return(redis.NewIntResult(0, nil)).
+				On("HIncrBy", mock.Anything, "karma:t2_abcdefg2", storage.KarmaLink, int64(-2)).
+				Return(redis.NewIntResult(-1, nil))
+			mockStop(m)
+
+			err := srv.Execute()
+
+			So(err.Error(), ShouldEqual, `couldn't read a group: stop`)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			post := m.Calls[4].Arguments.Get(2).([]interface{})
+			So(string(post[1].([]byte)), assertions.ShouldEqualJSON, `{"id":"1a","title":"","author":"t2_abcdefg2","subreddit":"golang","score":1,"promoted":false,"nsfw":false,"num_comments":0,"created":50}`)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"nanoreddit/pkg/protocol"
+)
+
+type credentialCtxKey struct{}
+
+// Credential is what a request is authenticated with.
+type Credential struct {
+	User   string
+	Scopes []string
+	// FirstParty credentials are sessions and tokens issued along with registrations, others are issued to clients.
+	FirstParty bool
+	Session    bool
+}
+
+// WithCredential attaches a credential to a context.
+func WithCredential(ctx context.Context, credential *Credential) context.Context {
+	return context.WithValue(ctx, credentialCtxKey{}, credential)
+}
+
+// WithUser attaches a session of a user to a context.
+func WithUser(ctx context.Context, user string) context.Context {
+	return WithCredential(ctx, &Credential{User: user, Scopes: protocol.Scopes, FirstParty: true, Session: true})
+}
+
+// User returns a user authenticated by a request or an empty string for an anonymous one.
+func User(ctx context.Context) string {
+	if credential, ok := ctx.Value(credentialCtxKey{}).(*Credential); ok {
+		return credential.User
+	}
+	return ""
+}
+
+// Viewer identifies a client of a request: the user, or the address of an anonymous client.
+func Viewer(r *http.Request) string {
+	if user := User(r.Context()); user != "" {
+		return user
+	}
+	return "ip:" + remoteHost(r)
+}
+
+// Scopes returns scopes granted to a request. An anonymous request has none.
+func Scopes(ctx context.Context) []string {
+	if credential, ok := ctx.Value(credentialCtxKey{}).(*Credential); ok {
+		return credential.Scopes
+	}
+	return nil
+}
+
+// FirstParty tells whether a request is authenticated with a first-party credential.
+func FirstParty(ctx context.Context) bool {
+	credential, ok := ctx.Value(credentialCtxKey{}).(*Credential)
+	return ok && credential.FirstParty
+}
+
+// Session tells whether a request is authenticated with a session token.
+func Session(ctx context.Context) bool {
+	credential, ok := ctx.Value(credentialCtxKey{}).(*Credential)
+	return ok && credential.Session
+}
+
+type tokenStorage interface {
+	// GetToken returns nil if a token is unknown.
+	GetToken(ctx context.Context, token string) (*protocol.AccessToken, error)
+}
+
+// Authenticate resolves a user by a credential of a request. Both API tokens and session tokens are accepted
+// as bearer tokens, session tokens are told apart by a signature separated by a dot.
+// A request without a credential passes through anonymously, while an invalid credential is rejected.
+func Authenticate(sessions *Sessions, tokens tokenStorage,
+	unauthorized, internalServerError func(w http.ResponseWriter, r *http.Request, err error),
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			const prefix = "Bearer "
+			if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+				unauthorized(w, r, errors.New("only bearer tokens are supported"))
+				return
+			}
+			token := header[len(prefix):]
+
+			var credential *Credential
+			if strings.Contains(token, ".") {
+				// Sessions are first-party, so they are granted everything.
+				user, err := sessions.Verify(token)
+				if err != nil {
+					unauthorized(w, r, err)
+					return
+				}
+				credential = &Credential{User: user, Scopes: protocol.Scopes, FirstParty: true, Session: true}
+			} else {
+				grant, err := tokens.GetToken(ctx, token)
+				if err != nil {
+					zerolog.Ctx(ctx).Error().Err(err).Msg("Couldn't fetch an API token")
+					internalServerError(w, r, err)
+					return
+				}
+				if grant == nil {
+					unauthorized(w, r, errors.New("the API token is invalid"))
+					return
+				}
+				credential = &Credential{User: grant.User, Scopes: grant.Scopes, FirstParty: grant.FirstParty}
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithCredential(ctx, credential)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Authenticated rejects anonymous requests. It relies on Authenticate put earlier in a chain.
+func Authenticated(unauthorized func(w http.ResponseWriter, r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if User(r.Context()) == "" {
+				unauthorized(w, r, errors.New("authentication is required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireScopes rejects requests with credentials lacking any of the scopes. Anonymous requests pass through,
+// so routes which require a credential should be guarded by Authenticated as well.
+func RequireScopes(insufficientScope func(w http.ResponseWriter, r *http.Request, err error), scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if User(ctx) != "" {
+				granted := Scopes(ctx)
+				for _, scope := range scopes {
+					if !protocol.HasScope(granted, scope) {
+						insufficientScope(w, r, fmt.Errorf("the %s scope is required", scope))
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireFirstParty rejects requests with credentials issued to clients, even if they're granted all scopes.
+// Anonymous requests pass through like they do with RequireScopes.
+func RequireFirstParty(forbidden func(w http.ResponseWriter, r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if User(ctx) != "" && !FirstParty(ctx) {
+				forbidden(w, r, errors.New("a first-party credential is required"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+type mockTokens struct {
+	m *mock.Mock
+}
+
+func (m *mockTokens) GetToken(ctx context.Context, token string) (*protocol.AccessToken, error) {
+	args := m.m.Called(ctx, token)
+	return args.Get(0).(*protocol.AccessToken), args.Error(1)
+}
+
+func mockSessions(now time.Time) *Sessions {
+	sessions := NewSessions(&Config{SessionSecret: "secret", SessionTTL: time.Hour})
+	sessions.now = func() time.Time { return now }
+	return sessions
+}
+
+func TestSessions(t *testing.T) {
+	Convey("Test sessions", t, func() {
+		now := time.Date(2021, time.January, 30, 12, 0, 0, 0, time.UTC)
+		sessions := mockSessions(now)
+
+		token, expires := sessions.Issue("t2_abcdefg2")
+		So(expires, ShouldEqual, now.Add(time.Hour).Unix())
+
+		Convey("A token is verified", func() {
+			user, err := sessions.Verify(token)
+
+			So(err, ShouldBeNil)
+			So(user, ShouldEqual, "t2_abcdefg2")
+		})
+
+		Convey("It fails if a token is signed with another secret", func() {
+			other := NewSessions(&Config{SessionSecret: "other", SessionTTL: time.Hour})
+			other.now = sessions.now
+
+			_, err := other.Verify(token)
+
+			So(err, ShouldEqual, errInvalidSession)
+		})
+
+		Convey("It fails if a token is tampered", func() {
+			forged, _ := mockSessions(now).Issue("t2_abcdefg3")
+
+			_, err := sessions.Verify(forged[:len(forged)-1] + "A")
+
+			So(err, ShouldEqual, errInvalidSession)
+		})
+
+		Convey("It fails if a token is malformed", func() {
+			for _, token := range []string{"", "nodot", "!!!.sig", "dGVzdA.sig"} {
+				_, err := sessions.Verify(token)
+
+				So(err, ShouldEqual, errInvalidSession)
+			}
+		})
+
+		Convey("It fails if a token has expired", func() {
+			sessions.now = func() time.Time { return now.Add(time.Hour) }
+
+			_, err := sessions.Verify(token)
+
+			So(err.Error(), ShouldEqual, "the session token has expired")
+		})
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	Convey("Test Authenticate", t, func() {
+		m := &mock.Mock{}
+		sessions := mockSessions(time.Now())
+
+		var user string
+		var scopes []string
+		var firstParty, session bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user = User(r.Context())
+			scopes = Scopes(r.Context())
+			firstParty = FirstParty(r.Context())
+			session = Session(r.Context())
+		})
+		fail := func(status int) func(w http.ResponseWriter, r *http.Request, err error) {
+			return func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(status)
+			}
+		}
+		h := Authenticate(sessions, &mockTokens{m: m}, fail(http.StatusUnauthorized), fail(http.StatusInternalServerError))(next)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		Convey("An anonymous request passes through", func() {
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(user, ShouldBeEmpty)
+		})
+
+		Convey("It fails if a scheme isn't bearer", func() {
+			r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("A session token is accepted", func() {
+			token, _ := sessions.Issue("t2_abcdefg2")
+			r.Header.Set("Authorization", "Bearer "+token)
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(user, ShouldEqual, "t2_abcdefg2")
+			So(scopes, ShouldResemble, protocol.Scopes)
+			So(firstParty, ShouldBeTrue)
+			So(session, ShouldBeTrue)
+		})
+
+		Convey("It fails if a session token is invalid", func() {
+			r.Header.Set("Authorization", "Bearer payload.signature")
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("An API token is accepted", func() {
+			m.On("GetToken", mock.Anything, "apitoken").Return(&protocol.AccessToken{User: "t2_abcdefg3", Scopes: []string{protocol.ScopeRead}}, nil)
+			r.Header.Set("Authorization", "bearer apitoken")
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(user, ShouldEqual, "t2_abcdefg3")
+			So(scopes, ShouldResemble, []string{protocol.ScopeRead})
+			So(firstParty, ShouldBeFalse)
+			So(session, ShouldBeFalse)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("An API token issued along with a registration is first-party", func() {
+			m.On("GetToken", mock.Anything, "apitoken").Return(&protocol.AccessToken{User: "t2_abcdefg3", Scopes: protocol.Scopes, FirstParty: true}, nil)
+			r.Header.Set("Authorization", "Bearer apitoken")
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(firstParty, ShouldBeTrue)
+			So(session, ShouldBeFalse)
+		})
+
+		Convey("It fails if an API token is unknown", func() {
+			m.On("GetToken", mock.Anything, "apitoken").Return((*protocol.AccessToken)(nil), nil)
+			r.Header.Set("Authorization", "Bearer apitoken")
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("It fails if a storage has been failed", func() {
+			m.On("GetToken", mock.Anything, "apitoken").Return((*protocol.AccessToken)(nil), errors.New("storage error"))
+			r.Header.Set("Authorization", "Bearer apitoken")
+
+			h.ServeHTTP(w, r)
+
+			So(w.Code, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("Authenticated rejects anonymous requests", func() {
+			h := Authenticated(fail(http.StatusUnauthorized))(next)
+
+			h.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusUnauthorized)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r.WithContext(WithUser(r.Context(), "t2_abcdefg2")))
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(user, ShouldEqual, "t2_abcdefg2")
+		})
+
+		Convey("RequireScopes rejects credentials lacking a scope", func() {
+			h := RequireScopes(fail(http.StatusForbidden), protocol.ScopeRead, protocol.ScopeVote)(next)
+
+			h.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r.WithContext(WithCredential(r.Context(), &Credential{User: "t2_abcdefg2", Scopes: []string{protocol.ScopeVote}})))
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, r.WithContext(WithCredential(r.Context(), &Credential{User: "t2_abcdefg2", Scopes: []string{protocol.ScopeVote, protocol.ScopeRead}})))
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("RequireFirstParty rejects credentials issued to clients", func() {
+			h := RequireFirstParty(fail(http.StatusForbidden))(next)
+
+			h.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r.WithContext(WithCredential(r.Context(), &Credential{User: "t2_abcdefg2", Scopes: protocol.Scopes})))
+			So(w.Code, ShouldEqual, http.StatusForbidden)
+
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, r.WithContext(WithCredential(r.Context(), &Credential{User: "t2_abcdefg2", Scopes: protocol.Scopes, FirstParty: true})))
+			So(w.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
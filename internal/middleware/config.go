@@ -0,0 +1,8 @@
+package middleware
+
+import "time"
+
+type Config struct {
+	SessionSecret string        `env:"AUTH_SESSION_SECRET,required" json:"-"`
+	SessionTTL    time.Duration `env:"AUTH_SESSION_TTL,default=24h"`
+}
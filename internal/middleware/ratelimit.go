@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+)
+
+type RateLimitConfig struct {
+	Prefix string `env:"ES_RATE_LIMIT,default=ratelimit"`
+	// Users are limited by their identifiers, anonymous clients are limited by their addresses.
+	Submit    Limit `env:"RATE_LIMIT_SUBMIT,default=10/1m"`
+	Write     Limit `env:"RATE_LIMIT_WRITE,default=60/1m"`
+	Read      Limit `env:"RATE_LIMIT_READ,default=600/1m"`
+	Anonymous Limit `env:"RATE_LIMIT_ANONYMOUS,default=120/1m"`
+	Register  Limit `env:"RATE_LIMIT_REGISTER,default=5/1h"`
+}
+
+// Limit allows Rate requests per Period. The whole rate can be spent at once, then requests are spread evenly.
+// A zero limit means there is no limit.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+}
+
+// Decode parses a limit like 10/1m.
+func (l *Limit) Decode(s string) error {
+	if s == "0" {
+		*l = Limit{}
+		return nil
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return fmt.Errorf("a limit should look like 10/1m: %q", s)
+	}
+	rate, err := strconv.Atoi(s[:i])
+	if err != nil || rate < 0 {
+		return fmt.Errorf("couldn't recognize a rate of a limit: %q", s)
+	}
+	period, err := time.ParseDuration(s[i+1:])
+	if err != nil || period <= 0 {
+		return fmt.Errorf("couldn't recognize a period of a limit: %q", s)
+	}
+	*l = Limit{Rate: rate, Period: period}
+	return nil
+}
+
+func (l Limit) String() string {
+	if l.Rate == 0 {
+		return "0"
+	}
+	return strconv.Itoa(l.Rate) + "/" + l.Period.String()
+}
+
+// gcra implements the generic cell rate algorithm. A key keeps a theoretical arrival time of the next request.
+// The clock of Redis is used, so that all replicas of the service agree on time.
+// It returns whether a request is allowed, how many requests remain, in how many milliseconds a request may be retried
+// and in how many milliseconds the limit is restored completely.
+var gcra = redis.NewScript(`
+redis.replicate_commands()
+local interval = tonumber(ARGV[1])
+local tolerance = interval * tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next_tat = tat + interval
+local diff = now - (next_tat - tolerance)
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+redis.call("SET", KEYS[1], next_tat, "PX", next_tat - now)
+return {1, math.floor(diff / interval), 0, next_tat - now}
+`)
+
+type RateLimiter struct {
+	client redis.Cmdable
+	prefix string
+}
+
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int64
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+func (rl *RateLimiter) take(ctx context.Context, key string, limit Limit) (*rateLimitResult, error) {
+	interval := limit.Period.Milliseconds() / int64(limit.Rate)
+	if interval < 1 {
+		interval = 1
+	}
+	values, err := gcra.Run(ctx, rl.client, []string{rl.prefix + ":" + key}, interval, limit.Rate).Result()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := values.([]interface{})
+	if !ok || len(v) != 4 {
+		return nil, fmt.Errorf("unexpected result of the rate limiter: %v", values)
+	}
+	ints := make([]int64, len(v))
+	for i := range v {
+		if ints[i], ok = v[i].(int64); !ok {
+			return nil, fmt.Errorf("unexpected result of the rate limiter: %v", values)
+		}
+	}
+	return &rateLimitResult{
+		allowed:    ints[0] == 1,
+		remaining:  ints[1],
+		retryAfter: time.Duration(ints[2]) * time.Millisecond,
+		reset:      time.Duration(ints[3]) * time.Millisecond,
+	}, nil
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// Limit limits requests to a route. Authenticated requests are limited per user, anonymous ones per address.
+// If Redis fails, requests aren't limited, since it's better than rejecting all of them.
+func (rl *RateLimiter) Limit(route string, user, anonymous Limit,
+	tooManyRequests func(w http.ResponseWriter, r *http.Request, err error),
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			limit, identity := user, Viewer(r)
+			if User(ctx) == "" {
+				limit = anonymous
+			}
+			if limit.Rate == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := rl.take(ctx, route+":"+identity, limit)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Str("route", route).Msg("Couldn't apply a rate limit")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(limit.Rate))
+			h.Set("X-RateLimit-Remaining", strconv.FormatInt(result.remaining, 10))
+			h.Set("X-RateLimit-Reset", seconds(result.reset))
+			if !result.allowed {
+				h.Set("Retry-After", seconds(result.retryAfter))
+				tooManyRequests(w, r, fmt.Errorf("the rate limit of %s is exceeded", limit))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func NewRateLimiter(client redis.Cmdable, cfg *RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		client: client,
+		prefix: cfg.Prefix,
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRedis struct {
+	redis.Cmdable
+
+	m *mock.Mock
+}
+
+func (m *mockRedis) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	called := m.m.Called(ctx, keys, args)
+	return called.Get(0).(*redis.Cmd)
+}
+
+func TestLimit(t *testing.T) {
+	Convey("Test Limit", t, func() {
+		var l Limit
+
+		So(l.Decode("10/1m"), ShouldBeNil)
+		So(l, ShouldResemble, Limit{Rate: 10, Period: time.Minute})
+		So(l.String(), ShouldEqual, "10/1m0s")
+
+		So(l.Decode("0"), ShouldBeNil)
+		So(l, ShouldResemble, Limit{})
+
+		for _, s := range []string{"10", "ten/1m", "-1/1m", "10/minute", "10/0s"} {
+			So(l.Decode(s), ShouldNotBeNil)
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	Convey("Test RateLimiter", t, func() {
+		m := &mock.Mock{}
+		rl := NewRateLimiter(&mockRedis{m: m}, &RateLimitConfig{Prefix: "ratelimit"})
+
+		called := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})
+		tooManyRequests := func(w http.ResponseWriter, r *http.Request, err error) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		h := rl.Limit("submit", Limit{Rate: 10, Period: time.Minute}, Limit{Rate: 2, Period: time.Second}, tooManyRequests)(next)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/submit", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+
+		Convey("A user is limited by the identifier", func() {
+			m.
+				On("EvalSha", mock.Anything, []string{"ratelimit:submit:t2_abcdefg2"}, []interface{}{int64(6000), 10}).
+				Return(redis.NewCmdResult([]interface{}{int64(1), int64(9), int64(0), int64(6000)}, nil))
+
+			h.ServeHTTP(w, r.WithContext(WithUser(r.Context(), "t2_abcdefg2")))
+
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(called, ShouldBeTrue)
+			So(w.Header().Get("X-RateLimit-Limit"), ShouldEqual, "10")
+			So(w.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "9")
+			So(w.Header().Get("X-RateLimit-Reset"), ShouldEqual, "6")
+			So(w.Header().Get("Retry-After"), ShouldBeEmpty)
+		})
+
+		Convey("An anonymous client is limited by the address", func() {
+			m.
+				On("EvalSha", mock.Anything, []string{"ratelimit:submit:ip:192.0.2.1"}, []interface{}{int64(500), 2}).
+				Return(redis.NewCmdResult([]interface{}{int64(0), int64(0), int64(250), int64(1250)}, nil))
+
+			h.ServeHTTP(w, r)
+
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(called, ShouldBeFalse)
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("X-RateLimit-Limit"), ShouldEqual, "2")
+			So(w.Header().Get("X-RateLimit-Remaining"), ShouldEqual, "0")
+			So(w.Header().Get("X-RateLimit-Reset"), ShouldEqual, "2")
+			So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+		})
+
+		Convey("A zero limit means no limit", func() {
+			h := rl.Limit("submit", Limit{}, Limit{}, tooManyRequests)(next)
+
+			h.ServeHTTP(w, r)
+
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(called, ShouldBeTrue)
+		})
+
+		Convey("Requests aren't limited if Redis fails", func() {
+			m.
+				On("EvalSha", mock.Anything, mock.Anything, mock.Anything).
+				Return(redis.NewCmdResult(nil, errors.New("redis error")))
+
+			h.ServeHTTP(w, r)
+
+			So(called, ShouldBeTrue)
+			So(w.Header().Get("X-RateLimit-Limit"), ShouldBeEmpty)
+		})
+	})
+}
@@ -5,11 +5,13 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 
 	"nanoreddit/internal/chi_utils"
 	"nanoreddit/internal/middleware"
+	"nanoreddit/pkg/protocol"
 )
 
 type service struct {
@@ -36,24 +38,196 @@ func (s *service) Interrupt(err error) {
 }
 
 func NewService(ctx context.Context, cfg *Config,
+	sessions *middleware.Sessions,
+	limiter *middleware.RateLimiter,
+	limits *middleware.RateLimitConfig,
+	tokens interface {
+		GetToken(ctx context.Context, token string) (*protocol.AccessToken, error)
+	},
 	handler interface {
 		Submit(w http.ResponseWriter, r *http.Request)
 		Feed(w http.ResponseWriter, r *http.Request)
+		FeedStream(w http.ResponseWriter, r *http.Request)
+		FeedSocket(w http.ResponseWriter, r *http.Request)
+		Post(w http.ResponseWriter, r *http.Request)
+		Duplicates(w http.ResponseWriter, r *http.Request)
+		EditPost(w http.ResponseWriter, r *http.Request)
+		DeletePost(w http.ResponseWriter, r *http.Request)
+		VotePost(w http.ResponseWriter, r *http.Request)
+		ReportPost(w http.ResponseWriter, r *http.Request)
+		ApprovePost(w http.ResponseWriter, r *http.Request)
+		RemovePost(w http.ResponseWriter, r *http.Request)
+		SpamPost(w http.ResponseWriter, r *http.Request)
+		ModQueue(w http.ResponseWriter, r *http.Request)
+		ModLog(w http.ResponseWriter, r *http.Request)
+		Moderators(w http.ResponseWriter, r *http.Request)
+		AddModerator(w http.ResponseWriter, r *http.Request)
+		RemoveModerator(w http.ResponseWriter, r *http.Request)
+		SubredditBans(w http.ResponseWriter, r *http.Request)
+		BanFromSubreddit(w http.ResponseWriter, r *http.Request)
+		UnbanFromSubreddit(w http.ResponseWriter, r *http.Request)
+		Bans(w http.ResponseWriter, r *http.Request)
+		BanUser(w http.ResponseWriter, r *http.Request)
+		UnbanUser(w http.ResponseWriter, r *http.Request)
+		CreateCampaign(w http.ResponseWriter, r *http.Request)
+		Campaigns(w http.ResponseWriter, r *http.Request)
+		Campaign(w http.ResponseWriter, r *http.Request)
+		EditCampaign(w http.ResponseWriter, r *http.Request)
+		PauseCampaign(w http.ResponseWriter, r *http.Request)
+		ResumeCampaign(w http.ResponseWriter, r *http.Request)
+		CampaignStats(w http.ResponseWriter, r *http.Request)
+		Advertisers(w http.ResponseWriter, r *http.Request)
+		AddAdvertiser(w http.ResponseWriter, r *http.Request)
+		RemoveAdvertiser(w http.ResponseWriter, r *http.Request)
+		CreateHouseAd(w http.ResponseWriter, r *http.Request)
+		ClickPost(w http.ResponseWriter, r *http.Request)
+		Comments(w http.ResponseWriter, r *http.Request)
+		AddComment(w http.ResponseWriter, r *http.Request)
+		VoteComment(w http.ResponseWriter, r *http.Request)
+		CreateSubreddit(w http.ResponseWriter, r *http.Request)
+		Subreddit(w http.ResponseWriter, r *http.Request)
+		SubredditFeed(w http.ResponseWriter, r *http.Request)
+		Subscriptions(w http.ResponseWriter, r *http.Request)
+		Subscribe(w http.ResponseWriter, r *http.Request)
+		Unsubscribe(w http.ResponseWriter, r *http.Request)
+		CreateUser(w http.ResponseWriter, r *http.Request)
+		User(w http.ResponseWriter, r *http.Request)
+		Submitted(w http.ResponseWriter, r *http.Request)
+		Preferences(w http.ResponseWriter, r *http.Request)
+		EditPreferences(w http.ResponseWriter, r *http.Request)
+		SavePost(w http.ResponseWriter, r *http.Request)
+		UnsavePost(w http.ResponseWriter, r *http.Request)
+		HidePost(w http.ResponseWriter, r *http.Request)
+		UnhidePost(w http.ResponseWriter, r *http.Request)
+		Saved(w http.ResponseWriter, r *http.Request)
+		CreateSession(w http.ResponseWriter, r *http.Request)
+		IssueToken(w http.ResponseWriter, r *http.Request)
+		RevokeToken(w http.ResponseWriter, r *http.Request)
 	},
 ) *service {
 	l := zerolog.Ctx(ctx).With().Str("service", "server").Logger()
 
+	render := chi_utils.NewRender()
+
 	r := chi.NewRouter()
 	r.Use(hlog.NewHandler(l))
 	//TODO put a recoverer here
 	r.Use(hlog.RequestIDHandler("id_request", "X-Request-ID"))
 	r.Use(hlog.RequestHandler("request"))
 	if cfg.LogRequests {
-		render := chi_utils.NewRender()
 		r.Use(middleware.RequestBody(render.InvalidRequest))
 	}
-	r.Post("/submit", handler.Submit)
-	r.Get("/feed", handler.Feed)
+	// Listings can be requested in other formats by a suffix, like /feed.rss, so it's cut off before routing.
+	r.Use(chimiddleware.URLFormat)
+	// A credential is optional for public routes, but if it's present, it has to be valid.
+	r.Use(middleware.Authenticate(sessions, tokens, render.Unauthorized, render.InternalServerError))
+
+	scopes := func(scopes ...string) func(http.Handler) http.Handler {
+		return middleware.RequireScopes(render.InsufficientScope, scopes...)
+	}
+
+	// Limits are applied after authentication, since users are limited by their identifiers and anonymous clients by
+	// their addresses.
+	limit := func(route string, user, anonymous middleware.Limit) func(http.Handler) http.Handler {
+		return limiter.Limit(route, user, anonymous, render.TooManyRequests)
+	}
+
+	r.With(limit("register", middleware.Limit{}, limits.Register)).Post("/users", handler.CreateUser)
+
+	// Public routes
+	r.Group(func(r chi.Router) {
+		r.Use(scopes(protocol.ScopeRead))
+		r.Use(limit("read", limits.Read, limits.Anonymous))
+
+		r.Get("/feed", handler.Feed)
+		r.Get("/feed/stream", handler.FeedStream)
+		r.Get("/feed/ws", handler.FeedSocket)
+		r.Get("/posts/{id}", handler.Post)
+		r.Get("/posts/{id}/comments", handler.Comments)
+		r.Get("/posts/{id}/click", handler.ClickPost)
+		r.Get("/duplicates/{id}", handler.Duplicates)
+		r.Get("/r/{subreddit}", handler.SubredditFeed)
+		r.Get("/r/{subreddit}/feed", handler.SubredditFeed)
+		r.Get("/r/{subreddit}/about", handler.Subreddit)
+		r.Get("/r/{subreddit}/about/moderators", handler.Moderators)
+		r.Get("/user/{id}", handler.User)
+		r.Get("/user/{id}/submitted", handler.Submitted)
+	})
+
+	// Authenticated routes
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Authenticated(render.Unauthorized))
+		r.Use(limit("write", limits.Write, middleware.Limit{}))
+
+		// Tokens are issued by sessions only, which the handler checks, and any credential may revoke a token.
+		r.Post("/oauth/token", handler.IssueToken)
+		r.Post("/oauth/revoke", handler.RevokeToken)
+
+		// Sessions, subscriptions, preferences, saved and hidden posts and ad campaigns are managed by first-party
+		// clients only.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireFirstParty(render.InsufficientScope))
+
+			r.Post("/sessions", handler.CreateSession)
+			r.Get("/users/{id}/subscriptions", handler.Subscriptions)
+			r.Post("/users/{id}/subscriptions", handler.Subscribe)
+			r.Delete("/users/{id}/subscriptions/{subreddit}", handler.Unsubscribe)
+			r.Get("/prefs", handler.Preferences)
+			r.Patch("/prefs", handler.EditPreferences)
+			r.Post("/posts/{id}/save", handler.SavePost)
+			r.Post("/posts/{id}/unsave", handler.UnsavePost)
+			r.Post("/posts/{id}/hide", handler.HidePost)
+			r.Post("/posts/{id}/unhide", handler.UnhidePost)
+			r.Get("/user/{id}/saved", handler.Saved)
+			r.Get("/ads/campaigns", handler.Campaigns)
+			r.Post("/ads/campaigns", handler.CreateCampaign)
+			r.Get("/ads/campaigns/{id}", handler.Campaign)
+			r.Patch("/ads/campaigns/{id}", handler.EditCampaign)
+			r.Post("/ads/campaigns/{id}/pause", handler.PauseCampaign)
+			r.Post("/ads/campaigns/{id}/resume", handler.ResumeCampaign)
+			r.Get("/ads/campaigns/{id}/stats", handler.CampaignStats)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(scopes(protocol.ScopeSubmit))
+
+			r.With(limit("submit", limits.Submit, middleware.Limit{})).Post("/submit", handler.Submit)
+			r.Patch("/posts/{id}", handler.EditPost)
+			r.Delete("/posts/{id}", handler.DeletePost)
+			r.Post("/posts/{id}/comments", handler.AddComment)
+			r.Post("/posts/{id}/report", handler.ReportPost)
+			r.Post("/subreddits", handler.CreateSubreddit)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(scopes(protocol.ScopeVote))
+
+			r.Post("/posts/{id}/vote", handler.VotePost)
+			r.Post("/comments/{id}/vote", handler.VoteComment)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(scopes(protocol.ScopeModPosts))
+
+			r.Post("/posts/{id}/approve", handler.ApprovePost)
+			r.Post("/posts/{id}/remove", handler.RemovePost)
+			r.Post("/posts/{id}/spam", handler.SpamPost)
+			r.Get("/r/{subreddit}/about/modqueue", handler.ModQueue)
+			r.Get("/r/{subreddit}/about/log", handler.ModLog)
+			r.Post("/r/{subreddit}/about/moderators", handler.AddModerator)
+			r.Delete("/r/{subreddit}/about/moderators/{user}", handler.RemoveModerator)
+			r.Get("/r/{subreddit}/about/banned", handler.SubredditBans)
+			r.Post("/r/{subreddit}/about/banned", handler.BanFromSubreddit)
+			r.Delete("/r/{subreddit}/about/banned/{user}", handler.UnbanFromSubreddit)
+			r.Get("/admin/bans", handler.Bans)
+			r.Post("/admin/bans", handler.BanUser)
+			r.Delete("/admin/bans/{user}", handler.UnbanUser)
+			r.Get("/admin/advertisers", handler.Advertisers)
+			r.Post("/admin/advertisers", handler.AddAdvertiser)
+			r.Delete("/admin/advertisers/{user}", handler.RemoveAdvertiser)
+			r.Post("/admin/house_ads", handler.CreateHouseAd)
+		})
+	})
 
 	return &service{
 		logger: l,
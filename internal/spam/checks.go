@@ -0,0 +1,204 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"nanoreddit/internal/validation"
+	"nanoreddit/pkg/protocol"
+)
+
+// Names of checks.
+const (
+	CheckBlocklist  = "blocklist"
+	CheckReputation = "reputation"
+	CheckVelocity   = "velocity"
+	CheckSimilarity = "similarity"
+)
+
+// Scores checks contribute. A blocklisted post is rejected at once with the default thresholds, other signals
+// have to add up.
+const (
+	blocklistScore  = 3
+	reputationScore = 2
+	suspiciousScore = 1
+	velocityScore   = 1
+	similarityScore = 1
+)
+
+// blocklist looks for forbidden keywords and patterns in titles, content and links.
+type blocklist struct {
+	keywords []string
+	patterns []*regexp.Regexp
+}
+
+func (b *blocklist) Check(ctx context.Context, post *protocol.Post) ([]protocol.SpamReason, error) {
+	text := post.Title + "\n" + post.Content + "\n" + post.Link
+	lower := strings.ToLower(text)
+	for _, keyword := range b.keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(lower, keyword) {
+			return []protocol.SpamReason{{
+				Check:       CheckBlocklist,
+				Score:       blocklistScore,
+				Description: fmt.Sprintf("the post contains the blocked keyword %q", keyword),
+			}}, nil
+		}
+	}
+	for _, pattern := range b.patterns {
+		if pattern.MatchString(text) {
+			return []protocol.SpamReason{{
+				Check:       CheckBlocklist,
+				Score:       blocklistScore,
+				Description: fmt.Sprintf("the post matches the blocked pattern %q", pattern),
+			}}, nil
+		}
+	}
+	return nil, nil
+}
+
+// reputation scores a link by the share of spam among posts of its domain.
+type reputation struct {
+	cfg     *Config
+	storage storage
+}
+
+func (r *reputation) Check(ctx context.Context, post *protocol.Post) ([]protocol.SpamReason, error) {
+	if post.Domain == "" {
+		return nil, nil
+	}
+
+	var reasons []protocol.SpamReason
+	if validation.MatchDomain(r.cfg.SuspiciousDomains, post.Domain) != "" {
+		reasons = append(reasons, protocol.SpamReason{
+			Check:       CheckReputation,
+			Score:       suspiciousScore,
+			Description: fmt.Sprintf("links to %s are suspicious", post.Domain),
+		})
+	}
+
+	posts, spam, err := r.storage.GetReputation(ctx, post.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch a reputation of a domain: %w", err)
+	}
+	if total := posts + spam; spam != 0 && total >= r.cfg.ReputationMinPosts {
+		share := float64(spam) / float64(total)
+		reasons = append(reasons, protocol.SpamReason{
+			Check:       CheckReputation,
+			Score:       reputationScore * share,
+			Description: fmt.Sprintf("%.0f%% of posts of %s are spam", share*100, post.Domain),
+		})
+	}
+	return reasons, nil
+}
+
+// velocity stops new accounts submitting many posts at once.
+type velocity struct {
+	cfg     *Config
+	storage storage
+	now     func() time.Time
+}
+
+func (v *velocity) Check(ctx context.Context, post *protocol.Post) ([]protocol.SpamReason, error) {
+	if v.cfg.VelocityLimit <= 0 {
+		return nil, nil
+	}
+	user, err := v.storage.GetUser(ctx, post.Author)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch an author: %w", err)
+	}
+	now := v.now()
+	if user == nil || now.Sub(time.Unix(user.Created, 0)) >= v.cfg.NewAccountAge {
+		return nil, nil
+	}
+
+	posts, _, err := v.storage.GetSubmitted(ctx, post.Author, true, 0)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch posts of an author: %w", err)
+	}
+	since := now.Add(-v.cfg.VelocityWindow).Unix()
+	// This post counts as well.
+	n := 1
+	for i := range posts {
+		if !same(post, &posts[i]) && posts[i].Created >= since {
+			n++
+		}
+	}
+	if n < v.cfg.VelocityLimit {
+		return nil, nil
+	}
+	return []protocol.SpamReason{{
+		Check:       CheckVelocity,
+		Score:       velocityScore,
+		Description: fmt.Sprintf("a new account has submitted %d posts within %s", n, v.cfg.VelocityWindow),
+	}}, nil
+}
+
+// similarity looks for authors repeating the same title over and over.
+type similarity struct {
+	cfg     *Config
+	storage storage
+}
+
+func (s *similarity) Check(ctx context.Context, post *protocol.Post) ([]protocol.SpamReason, error) {
+	if s.cfg.SimilarTitles <= 0 {
+		return nil, nil
+	}
+	posts, _, err := s.storage.GetSubmitted(ctx, post.Author, true, 0)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch posts of an author: %w", err)
+	}
+
+	title := trigrams(post.Title)
+	n := 0
+	for i := range posts {
+		if !same(post, &posts[i]) && !posts[i].Deleted && jaccard(title, trigrams(posts[i].Title)) >= s.cfg.TitleSimilarity {
+			n++
+		}
+	}
+	if n < s.cfg.SimilarTitles {
+		return nil, nil
+	}
+	return []protocol.SpamReason{{
+		Check:       CheckSimilarity,
+		Score:       similarityScore,
+		Description: fmt.Sprintf("the author has recently submitted %d posts with similar titles", n),
+	}}, nil
+}
+
+// same tells whether a submitted post is the one being checked. Edited posts are checked again, and they're among
+// posts of their authors already, while new posts have no identifiers yet.
+func same(post, submitted *protocol.Post) bool {
+	return post.ID != "" && post.ID == submitted.ID
+}
+
+// trigrams splits a text into overlapping triples of letters, so reordered or slightly changed words still match.
+// Case and punctuation are ignored.
+func trigrams(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	runes := []rune(" " + strings.Join(words, " ") + " ")
+	set := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = struct{}{}
+	}
+	return set
+}
+
+// jaccard is a share of common items of two sets.
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for item := range a {
+		if _, ok := b[item]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
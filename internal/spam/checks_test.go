@@ -0,0 +1,280 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+var mockNow = time.Date(2021, time.January, 30, 12, 0, 0, 0, time.UTC)
+
+func TestBlocklist(t *testing.T) {
+	Convey("Test blocklist", t, func() {
+		b := &blocklist{
+			keywords: []string{" Casino ", ""},
+			patterns: []*regexp.Regexp{regexp.MustCompile(`(?i)free\s+money`)},
+		}
+
+		Convey("A clean post passes", func() {
+			reasons, err := b.Check(context.Background(), &protocol.Post{Title: "Go 1.16 is released", Link: "https://golang.org/"})
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+		})
+
+		Convey("Keywords are looked for in titles, content and links", func() {
+			for _, post := range []protocol.Post{{Title: "Best CASINO"}, {Content: "a casino"}, {Link: "https://casino.com/"}} {
+				reasons, err := b.Check(context.Background(), &post)
+
+				So(err, ShouldBeNil)
+				So(reasons, ShouldResemble, []protocol.SpamReason{{
+					Check:       CheckBlocklist,
+					Score:       blocklistScore,
+					Description: `the post contains the blocked keyword "casino"`,
+				}})
+			}
+		})
+
+		Convey("Patterns are matched", func() {
+			reasons, err := b.Check(context.Background(), &protocol.Post{Title: "Free   Money!"})
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldResemble, []protocol.SpamReason{{
+				Check:       CheckBlocklist,
+				Score:       blocklistScore,
+				Description: `the post matches the blocked pattern "(?i)free\\s+money"`,
+			}})
+		})
+	})
+}
+
+func TestReputation(t *testing.T) {
+	Convey("Test reputation", t, func() {
+		m := &mock.Mock{}
+		r := &reputation{
+			cfg:     &Config{SuspiciousDomains: []string{"bit.ly"}, ReputationMinPosts: 10},
+			storage: &mockStorage{m: m},
+		}
+
+		Convey("Self posts have no reputation", func() {
+			reasons, err := r.Check(context.Background(), &protocol.Post{Content: "content"})
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if a reputation cannot be fetched", func() {
+			m.On("GetReputation", mock.Anything, "reddit.com").Return(0, 0, errors.New("storage error"))
+
+			_, err := r.Check(context.Background(), &protocol.Post{Domain: "reddit.com"})
+
+			So(err, ShouldBeError, "couldn't fetch a reputation of a domain: storage error")
+		})
+
+		Convey("A reputation of a domain with few posts isn't trusted", func() {
+			m.On("GetReputation", mock.Anything, "reddit.com").Return(4, 5, nil)
+
+			reasons, err := r.Check(context.Background(), &protocol.Post{Domain: "reddit.com"})
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+		})
+
+		Convey("A share of spam contributes to a score", func() {
+			m.On("GetReputation", mock.Anything, "reddit.com").Return(15, 5, nil)
+
+			reasons, err := r.Check(context.Background(), &protocol.Post{Domain: "reddit.com"})
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldResemble, []protocol.SpamReason{{
+				Check:       CheckReputation,
+				Score:       0.5,
+				Description: "25% of posts of reddit.com are spam",
+			}})
+		})
+
+		Convey("Suspicious domains and their subdomains contribute to a score", func() {
+			m.On("GetReputation", mock.Anything, "go.bit.ly").Return(0, 0, nil)
+
+			reasons, err := r.Check(context.Background(), &protocol.Post{Domain: "go.bit.ly"})
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldResemble, []protocol.SpamReason{{
+				Check:       CheckReputation,
+				Score:       suspiciousScore,
+				Description: "links to go.bit.ly are suspicious",
+			}})
+		})
+	})
+}
+
+func TestVelocity(t *testing.T) {
+	Convey("Test velocity", t, func() {
+		m := &mock.Mock{}
+		v := &velocity{
+			cfg:     &Config{NewAccountAge: 72 * time.Hour, VelocityWindow: time.Hour, VelocityLimit: 3},
+			storage: &mockStorage{m: m},
+			now:     func() time.Time { return mockNow },
+		}
+		post := &protocol.Post{Author: "t2_abcdefg2"}
+
+		Convey("Zero disables the check", func() {
+			v.cfg.VelocityLimit = 0
+
+			reasons, err := v.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if an author cannot be fetched", func() {
+			m.On("GetUser", mock.Anything, "t2_abcdefg2").Return((*protocol.User)(nil), errors.New("storage error"))
+
+			_, err := v.Check(context.Background(), post)
+
+			So(err, ShouldBeError, "couldn't fetch an author: storage error")
+		})
+
+		Convey("Old accounts aren't limited", func() {
+			m.On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{Created: mockNow.Add(-72 * time.Hour).Unix()}, nil)
+
+			reasons, err := v.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if posts cannot be fetched", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{Created: mockNow.Unix()}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post(nil), false, errors.New("storage error"))
+
+			_, err := v.Check(context.Background(), post)
+
+			So(err, ShouldBeError, "couldn't fetch posts of an author: storage error")
+		})
+
+		Convey("A new account can submit a few posts", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{Created: mockNow.Add(-2 * time.Hour).Unix()}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post{
+				{Created: mockNow.Add(-time.Minute).Unix()},
+				{Created: mockNow.Add(-90 * time.Minute).Unix()},
+			}, false, nil)
+
+			reasons, err := v.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+		})
+
+		Convey("A new account submitting too fast is suspicious", func() {
+			m.
+				On("GetUser", mock.Anything, "t2_abcdefg2").Return(&protocol.User{Created: mockNow.Add(-2 * time.Hour).Unix()}, nil).
+				On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post{
+				{Created: mockNow.Add(-time.Minute).Unix()},
+				{Created: mockNow.Add(-time.Hour).Unix()},
+			}, false, nil)
+
+			reasons, err := v.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldResemble, []protocol.SpamReason{{
+				Check:       CheckVelocity,
+				Score:       velocityScore,
+				Description: "a new account has submitted 3 posts within 1h0m0s",
+			}})
+		})
+	})
+}
+
+func TestSimilarity(t *testing.T) {
+	Convey("Test similarity", t, func() {
+		m := &mock.Mock{}
+		s := &similarity{
+			cfg:     &Config{SimilarTitles: 2, TitleSimilarity: 0.8},
+			storage: &mockStorage{m: m},
+		}
+		post := &protocol.Post{Author: "t2_abcdefg2", Title: "Buy cheap watches here"}
+
+		Convey("Zero disables the check", func() {
+			s.cfg.SimilarTitles = 0
+
+			reasons, err := s.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("It fails if posts cannot be fetched", func() {
+			m.On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post(nil), false, errors.New("storage error"))
+
+			_, err := s.Check(context.Background(), post)
+
+			So(err, ShouldBeError, "couldn't fetch posts of an author: storage error")
+		})
+
+		Convey("Different titles pass", func() {
+			m.On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post{
+				{Title: "buy cheap watches HERE"},
+				{Title: "Go 1.16 is released"},
+				{Title: protocol.DeletedMarker, Deleted: true},
+			}, false, nil)
+
+			reasons, err := s.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+		})
+
+		Convey("Repeated titles are suspicious", func() {
+			m.On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post{
+				{Title: "buy cheap watches HERE"},
+				{Title: "Buy  cheap watches here!"},
+			}, false, nil)
+
+			reasons, err := s.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldResemble, []protocol.SpamReason{{
+				Check:       CheckSimilarity,
+				Score:       similarityScore,
+				Description: "the author has recently submitted 2 posts with similar titles",
+			}})
+		})
+
+		Convey("An edited post isn't similar to itself", func() {
+			post.ID = "1a"
+			m.On("GetSubmitted", mock.Anything, "t2_abcdefg2", true, 0).Return([]protocol.Post{
+				{ID: "1a", Title: "Buy cheap watches here"},
+				{ID: "1b", Title: "Buy  cheap watches here!"},
+			}, false, nil)
+
+			reasons, err := s.Check(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(reasons, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestJaccard(t *testing.T) {
+	Convey("Test jaccard", t, func() {
+		So(jaccard(trigrams("hello world"), trigrams("Hello   World")), ShouldEqual, 1)
+		So(jaccard(trigrams("hello world"), trigrams("goodbye")), ShouldEqual, 0)
+		So(jaccard(trigrams(""), trigrams("")), ShouldEqual, 0)
+		So(jaccard(trigrams("hello world"), trigrams("Hello, world!")), ShouldEqual, 1)
+		So(jaccard(trigrams("buy cheap watches"), trigrams("buy cheap watch")), ShouldBeBetween, 0.5, 1)
+	})
+}
@@ -0,0 +1,25 @@
+package spam
+
+import "time"
+
+type Config struct {
+	// A post is queued for moderation or rejected once its score reaches a threshold. A single weak signal like
+	// velocity doesn't reach either, so ordinary new users aren't queued.
+	QueueScore  float64 `env:"SPAM_QUEUE_SCORE,default=2"`
+	RejectScore float64 `env:"SPAM_REJECT_SCORE,default=3"`
+	// Keywords are matched case-insensitively against titles, content and links, patterns are regular expressions.
+	Keywords []string `env:"SPAM_KEYWORDS"`
+	Patterns []string `env:"SPAM_PATTERNS"`
+	// Domains like URL shorteners hide the actual destination, so their links are suspicious.
+	SuspiciousDomains []string `env:"SPAM_SUSPICIOUS_DOMAINS"`
+	// A reputation of a domain is trusted once it has enough posts.
+	ReputationMinPosts int `env:"SPAM_REPUTATION_MIN_POSTS,default=10"`
+	// Accounts younger than NewAccountAge cannot submit VelocityLimit posts within VelocityWindow. Zero disables it.
+	NewAccountAge  time.Duration `env:"SPAM_NEW_ACCOUNT_AGE,default=72h"`
+	VelocityWindow time.Duration `env:"SPAM_VELOCITY_WINDOW,default=1h"`
+	VelocityLimit  int           `env:"SPAM_VELOCITY_LIMIT,default=10"`
+	// A post is suspicious if SimilarTitles recent posts of the author have titles as similar as TitleSimilarity.
+	// Zero disables it.
+	SimilarTitles   int     `env:"SPAM_SIMILAR_TITLES,default=2"`
+	TitleSimilarity float64 `env:"SPAM_TITLE_SIMILARITY,default=0.8"`
+}
@@ -0,0 +1,74 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// Check is a single stage of spam scoring.
+type Check interface {
+	// Check returns reasons to suspect a post. A clean post has none.
+	Check(ctx context.Context, post *protocol.Post) ([]protocol.SpamReason, error)
+}
+
+type storage interface {
+	// GetUser returns nil if a user doesn't exist.
+	GetUser(ctx context.Context, id string) (*protocol.User, error)
+	GetSubmitted(ctx context.Context, author string, own bool, page int) ([]protocol.Post, bool, error)
+	GetReputation(ctx context.Context, domain string) (int, int, error)
+}
+
+type scorer struct {
+	cfg    *Config
+	checks []Check
+}
+
+// Score runs every check and sums up their scores.
+func (s *scorer) Score(ctx context.Context, post *protocol.Post) (*protocol.SpamDecision, error) {
+	decision := protocol.SpamDecision{Outcome: protocol.SpamAccept}
+	for _, check := range s.checks {
+		reasons, err := check.Check(ctx, post)
+		if err != nil {
+			return nil, err
+		}
+		for _, reason := range reasons {
+			decision.Score += reason.Score
+		}
+		decision.Reasons = append(decision.Reasons, reasons...)
+	}
+
+	switch {
+	case decision.Score >= s.cfg.RejectScore:
+		decision.Outcome = protocol.SpamReject
+	case decision.Score >= s.cfg.QueueScore:
+		decision.Outcome = protocol.SpamQueue
+	}
+	return &decision, nil
+}
+
+// NewScorer makes a scorer with the standard chain of checks followed by the extra ones.
+func NewScorer(cfg *Config, storage storage, extra ...Check) (*scorer, error) {
+	patterns := make([]*regexp.Regexp, 0, len(cfg.Patterns))
+	for _, expr := range cfg.Patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't compile a spam pattern: %w", err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	checks := []Check{
+		&blocklist{keywords: cfg.Keywords, patterns: patterns},
+		&reputation{cfg: cfg, storage: storage},
+		&velocity{cfg: cfg, storage: storage, now: time.Now},
+		&similarity{cfg: cfg, storage: storage},
+	}
+	return &scorer{
+		cfg:    cfg,
+		checks: append(checks, extra...),
+	}, nil
+}
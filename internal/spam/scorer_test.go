@@ -0,0 +1,121 @@
+package spam
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/stretchr/testify/mock"
+
+	"nanoreddit/pkg/protocol"
+)
+
+type mockStorage struct {
+	m *mock.Mock
+}
+
+func (m *mockStorage) GetUser(ctx context.Context, id string) (*protocol.User, error) {
+	args := m.m.Called(ctx, id)
+	return args.Get(0).(*protocol.User), args.Error(1)
+}
+
+func (m *mockStorage) GetSubmitted(ctx context.Context, author string, own bool, page int) ([]protocol.Post, bool, error) {
+	args := m.m.Called(ctx, author, own, page)
+	return args.Get(0).([]protocol.Post), args.Bool(1), args.Error(2)
+}
+
+func (m *mockStorage) GetReputation(ctx context.Context, domain string) (int, int, error) {
+	args := m.m.Called(ctx, domain)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
+type mockCheck struct {
+	m    *mock.Mock
+	name string
+}
+
+func (m *mockCheck) Check(ctx context.Context, post *protocol.Post) ([]protocol.SpamReason, error) {
+	args := m.m.MethodCalled(m.name, ctx, post)
+	return args.Get(0).([]protocol.SpamReason), args.Error(1)
+}
+
+func TestScorer(t *testing.T) {
+	Convey("Test scorer", t, func() {
+		m := &mock.Mock{}
+		s := &scorer{
+			cfg:    &Config{QueueScore: 1, RejectScore: 2},
+			checks: []Check{&mockCheck{m: m, name: "first"}, &mockCheck{m: m, name: "second"}},
+		}
+		post := &protocol.Post{Title: "title"}
+
+		Convey("It fails if a check fails", func() {
+			m.
+				On("first", mock.Anything, post).Return([]protocol.SpamReason(nil), errors.New("storage error"))
+
+			_, err := s.Score(context.Background(), post)
+
+			So(err, ShouldBeError, "storage error")
+			So(m.AssertExpectations(t), ShouldBeTrue)
+		})
+
+		Convey("A clean post is accepted", func() {
+			m.
+				On("first", mock.Anything, post).Return([]protocol.SpamReason(nil), nil).
+				On("second", mock.Anything, post).Return([]protocol.SpamReason(nil), nil)
+
+			decision, err := s.Score(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(m.AssertExpectations(t), ShouldBeTrue)
+			So(decision, ShouldResemble, &protocol.SpamDecision{Outcome: protocol.SpamAccept})
+		})
+
+		Convey("A suspicious post is queued", func() {
+			m.
+				On("first", mock.Anything, post).Return([]protocol.SpamReason{{Check: "first", Score: 0.5}}, nil).
+				On("second", mock.Anything, post).Return([]protocol.SpamReason{{Check: "second", Score: 0.5}}, nil)
+
+			decision, err := s.Score(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(decision, ShouldResemble, &protocol.SpamDecision{
+				Outcome: protocol.SpamQueue,
+				Score:   1,
+				Reasons: []protocol.SpamReason{{Check: "first", Score: 0.5}, {Check: "second", Score: 0.5}},
+			})
+		})
+
+		Convey("Spam is rejected", func() {
+			m.
+				On("first", mock.Anything, post).Return([]protocol.SpamReason{{Check: "first", Score: 2}}, nil).
+				On("second", mock.Anything, post).Return([]protocol.SpamReason(nil), nil)
+
+			decision, err := s.Score(context.Background(), post)
+
+			So(err, ShouldBeNil)
+			So(decision.Outcome, ShouldEqual, protocol.SpamReject)
+			So(decision.Score, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestNewScorer(t *testing.T) {
+	Convey("Test NewScorer", t, func() {
+		Convey("It fails if a pattern is invalid", func() {
+			_, err := NewScorer(&Config{Patterns: []string{"("}}, &mockStorage{})
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Extra checks follow the standard ones", func() {
+			extra := &mockCheck{name: "extra"}
+
+			s, err := NewScorer(&Config{Patterns: []string{"(?i)free money"}}, &mockStorage{}, extra)
+
+			So(err, ShouldBeNil)
+			So(s.checks, ShouldHaveLength, 5)
+			So(s.checks[4], ShouldEqual, extra)
+		})
+	})
+}
@@ -0,0 +1,420 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// impressionsTTL keeps daily counters of impressions a bit longer than a day, so the last day can be reported.
+const impressionsTTL = 48 * time.Hour
+
+// servedTTL is how long a click may follow an impression and still count.
+const servedTTL = 24 * time.Hour
+
+// CampaignIndexKey names a sorted set keeping campaigns of an owner ordered by creation time.
+func CampaignIndexKey(prefix, owner string) string {
+	return prefix + ":" + owner
+}
+
+// ImpressionsKey names a counter of impressions of a campaign within a day in UTC.
+func ImpressionsKey(prefix, campaign string, day time.Time) string {
+	return prefix + ":" + campaign + ":" + day.UTC().Format("2006-01-02")
+}
+
+// FrequencyKey names a hash counting impressions of campaigns seen by a viewer within an hour starting at the given
+// Unix time. It expires along with the hour.
+func FrequencyKey(prefix, viewer string, hour int64) string {
+	return prefix + ":" + viewer + ":" + strconv.FormatInt(hour, 10)
+}
+
+// AdServedKey names a hash keeping viewers of promoted posts of campaigns served by a feed response, so clicks
+// can be tied to impressions.
+func AdServedKey(prefix, requestID string) string {
+	return prefix + ":" + requestID
+}
+
+// AdClicksKey names a hash keeping campaigns a viewer has clicked within an hour starting at the given Unix time.
+// It expires along with the hour.
+func AdClicksKey(prefix, viewer string, hour int64) string {
+	return prefix + ":" + viewer + ":" + strconv.FormatInt(hour, 10)
+}
+
+// PacedBudget tells how many impressions a campaign may have had by the moment, so it spends its daily budget
+// evenly through a UTC day. A campaign may run ahead of the pace by a margin.
+func PacedBudget(daily int, now time.Time, ahead time.Duration) int64 {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	const day = int64(24 * time.Hour / time.Second)
+	elapsed := int64((now.Sub(midnight) + ahead) / time.Second)
+	if elapsed >= day {
+		return int64(daily)
+	}
+	// Rounding up lets a campaign start right after midnight.
+	return (int64(daily)*elapsed + day - 1) / day
+}
+
+// AddCampaign saves a new campaign and publishes its promoted post. Both get new identifiers, and they refer
+// to each other.
+func (s *storage) AddCampaign(ctx context.Context, campaign *protocol.Campaign, post *protocol.Post) error {
+	id, err := s.nextID(ctx)
+	if err != nil {
+		return err
+	}
+	postID, err := s.nextID(ctx)
+	if err != nil {
+		return err
+	}
+	campaign.ID = id
+	campaign.PostID = postID
+	post.ID = postID
+	post.Campaign = id
+
+	blob, err := s.encode(campaign)
+	if err != nil {
+		return err
+	}
+	event, err := s.encode(post)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.cfg.Campaigns, campaign.ID, blob)
+		pipe.ZAdd(ctx, CampaignIndexKey(s.cfg.CampaignIndex, campaign.Owner), &redis.Z{
+			Score:  float64(campaign.Created),
+			Member: campaign.ID,
+		})
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.cfg.Stream,
+			Values: map[string]interface{}{
+				StreamTypeField:  EventPostSubmitted,
+				StreamValueField: event,
+			},
+		})
+		return nil
+	})
+	return err
+}
+
+// UpdateCampaign replaces a saved campaign. The scheduler picks changes up on its next run.
+func (s *storage) UpdateCampaign(ctx context.Context, campaign *protocol.Campaign) error {
+	blob, err := s.encode(campaign)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.cfg.Campaigns, campaign.ID, blob).Err()
+}
+
+// GetCampaign returns a campaign or nil if there is no such campaign.
+func (s *storage) GetCampaign(ctx context.Context, id string) (*protocol.Campaign, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Campaigns, id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var campaign protocol.Campaign
+	if err := s.decode([]byte(blob), &campaign); err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// IsAdvertiser tells whether administrators have allowed a user to run campaigns.
+func (s *storage) IsAdvertiser(ctx context.Context, user string) (bool, error) {
+	return s.client.SIsMember(ctx, s.cfg.Advertisers, user).Result()
+}
+
+// GetAdvertisers returns users allowed to run campaigns in no particular order.
+func (s *storage) GetAdvertisers(ctx context.Context) ([]string, error) {
+	return s.client.SMembers(ctx, s.cfg.Advertisers).Result()
+}
+
+func (s *storage) AddAdvertiser(ctx context.Context, user string) error {
+	return s.client.SAdd(ctx, s.cfg.Advertisers, user).Err()
+}
+
+// RemoveAdvertiser keeps a user from starting campaigns. Campaigns the user has started keep running.
+func (s *storage) RemoveAdvertiser(ctx context.Context, user string) error {
+	return s.client.SRem(ctx, s.cfg.Advertisers, user).Err()
+}
+
+// GetCampaigns returns campaigns of an owner, the newest first.
+func (s *storage) GetCampaigns(ctx context.Context, owner string) ([]protocol.Campaign, error) {
+	ids, err := s.client.ZRevRange(ctx, CampaignIndexKey(s.cfg.CampaignIndex, owner), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	blobs, err := s.client.HMGet(ctx, s.cfg.Campaigns, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	campaigns := make([]protocol.Campaign, 0, len(blobs))
+	for _, blob := range blobs {
+		blob, ok := blob.(string)
+		if !ok {
+			continue
+		}
+		var campaign protocol.Campaign
+		if err := s.decode([]byte(blob), &campaign); err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, nil
+}
+
+// excludes tells whether a campaign excludes a subreddit.
+func excludes(campaign *protocol.Campaign, subreddit string) bool {
+	for _, name := range campaign.Targeting.ExcludedSubreddits {
+		if strings.EqualFold(name, subreddit) {
+			return true
+		}
+	}
+	return false
+}
+
+// targets tells whether a campaign may be shown in a feed. A campaign without subreddits is shown everywhere
+// but the excluded subreddits, otherwise it's shown in feeds of the subreddits only.
+func targets(campaign *protocol.Campaign, subreddit string) bool {
+	if excludes(campaign, subreddit) {
+		return false
+	}
+	if len(campaign.Targeting.Subreddits) == 0 {
+		return true
+	}
+	for _, name := range campaign.Targeting.Subreddits {
+		if strings.EqualFold(name, subreddit) {
+			return true
+		}
+	}
+	return false
+}
+
+// impress counts an impression of a campaign. It returns false if the campaign mustn't be shown: it isn't live
+// or targeted at the feed, one of the neighbors belongs to an excluded subreddit, it's ahead of the pace of its
+// daily budget, or the viewer has seen it enough within the hour. The scheduler takes campaigns which aren't live,
+// targeted or on pace out of the rotation eventually, but it runs periodically.
+func (s *storage) impress(ctx context.Context, id string, query *protocol.FeedQuery, neighbors []protocol.Post) (bool, error) {
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	now := s.now()
+	if campaign == nil || !campaign.Live(now.Unix()) || !targets(campaign, query.Subreddit) {
+		return false, nil
+	}
+	for i := range neighbors {
+		if excludes(campaign, neighbors[i].Subreddit) {
+			return false, nil
+		}
+	}
+
+	key := ImpressionsKey(s.cfg.Impressions, campaign.ID, now)
+	// Anonymous viewers are told apart by addresses, so only internal callers may lack a viewer.
+	capped := query.Viewer != "" && s.cfg.FrequencyCap > 0
+	hour := now.Unix() - now.Unix()%3600
+	frequencyKey := FrequencyKey(s.cfg.Frequency, query.Viewer, hour)
+	var incr, seen *redis.IntCmd
+	if _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, impressionsTTL)
+		if capped {
+			seen = pipe.HIncrBy(ctx, frequencyKey, campaign.ID, 1)
+			pipe.ExpireAt(ctx, frequencyKey, time.Unix(hour+3600, 0))
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	if incr.Val() > PacedBudget(campaign.DailyBudget, now, s.cfg.AdsInterval) || (capped && seen.Val() > s.cfg.FrequencyCap) {
+		// An impression which isn't shown doesn't count.
+		_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Decr(ctx, key)
+			if capped {
+				pipe.HIncrBy(ctx, frequencyKey, campaign.ID, -1)
+			}
+			return nil
+		})
+		return false, err
+	}
+	return true, nil
+}
+
+// Counters of AdStatsKey hashes.
+const (
+	AdStatsImpressions = "impressions"
+	AdStatsClicks      = "clicks"
+)
+
+// AdStatsKey names a hash counting impressions and clicks of a campaign within an hour starting at the given Unix
+// time, or overall if the hour is zero. Unique viewers are counted by HyperLogLogs named the same way.
+func AdStatsKey(prefix, campaign string, hour int64) string {
+	if hour == 0 {
+		return prefix + ":" + campaign
+	}
+	return prefix + ":" + campaign + ":" + strconv.FormatInt(hour, 10)
+}
+
+// serve records that a feed response has shown a promoted post of a campaign to a viewer.
+func (s *storage) serve(ctx context.Context, impression *protocol.AdImpressed) error {
+	key := AdServedKey(s.cfg.AdServed, impression.RequestID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, impression.PostID, impression.Viewer)
+		pipe.Expire(ctx, key, servedTTL)
+		return nil
+	})
+	return err
+}
+
+// ClickAd publishes a click on a promoted post, so the materializer counts it. It returns false if the click
+// doesn't count: the post hasn't been served to the viewer by the response the click refers to, or the viewer has
+// clicked the campaign within the hour already.
+func (s *storage) ClickAd(ctx context.Context, click *protocol.AdClicked) (bool, error) {
+	if click.RequestID == "" {
+		return false, nil
+	}
+	viewer, err := s.client.HGet(ctx, AdServedKey(s.cfg.AdServed, click.RequestID), click.PostID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+	if viewer != click.Viewer {
+		return false, nil
+	}
+
+	hour := click.Created - click.Created%3600
+	key := AdClicksKey(s.cfg.AdClicks, click.Viewer, hour)
+	var first *redis.BoolCmd
+	if _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		first = pipe.HSetNX(ctx, key, click.Campaign, click.Created)
+		pipe.ExpireAt(ctx, key, time.Unix(hour+3600, 0))
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	if !first.Val() {
+		return false, nil
+	}
+	return true, s.publish(ctx, EventAdClicked, click)
+}
+
+// GetCampaignStats returns overall stats of a campaign along with stats of the given hours.
+func (s *storage) GetCampaignStats(ctx context.Context, campaign string, hours []int64) (*protocol.CampaignStats, error) {
+	type cmds struct {
+		counters *redis.StringStringMapCmd
+		viewers  *redis.IntCmd
+	}
+	all := append([]int64{0}, hours...)
+	results := make([]cmds, len(all))
+	if _, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, hour := range all {
+			results[i].counters = pipe.HGetAll(ctx, AdStatsKey(s.cfg.AdStats, campaign, hour))
+			results[i].viewers = pipe.PFCount(ctx, AdStatsKey(s.cfg.AdViewers, campaign, hour))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	stats := make([]protocol.AdStats, len(all))
+	for i, result := range results {
+		counters := result.counters.Val()
+		stats[i] = protocol.AdStats{UniqueViewers: result.viewers.Val()}
+		// Missing counters are zeros.
+		stats[i].Impressions, _ = strconv.ParseInt(counters[AdStatsImpressions], 10, 64)
+		stats[i].Clicks, _ = strconv.ParseInt(counters[AdStatsClicks], 10, 64)
+	}
+
+	campaignStats := protocol.CampaignStats{
+		Campaign: campaign,
+		AdStats:  stats[0],
+		Hourly:   make([]protocol.HourlyAdStats, 0, len(hours)),
+	}
+	for i, hour := range hours {
+		campaignStats.Hourly = append(campaignStats.Hourly, protocol.HourlyAdStats{Hour: hour, AdStats: stats[i+1]})
+	}
+	return &campaignStats, nil
+}
+
+// brandSafe tells whether an ad may be shown next to a post.
+func (s *storage) brandSafe(post *protocol.Post) bool {
+	if post.NSFW {
+		return false
+	}
+	for _, name := range s.cfg.SensitiveSubreddits {
+		if strings.EqualFold(name, post.Subreddit) {
+			return false
+		}
+	}
+	for _, flair := range s.cfg.SensitiveFlairs {
+		if post.Flair != "" && strings.EqualFold(flair, post.Flair) {
+			return false
+		}
+	}
+	return true
+}
+
+// promoted picks a promoted post for a slot between the neighbors, or it returns nil if the slot stays empty.
+// Campaigns targeted at the feed are shown in proportion to their weights, and house ads take slots which no
+// campaign may take. Posts which have been shown in the same response already aren't picked again, and neither are
+// posts the filter of the query hides.
+func (s *storage) promoted(ctx context.Context, query *protocol.FeedQuery, neighbors []protocol.Post, shown map[string]bool) (*protocol.Post, error) {
+	for i := range neighbors {
+		if !s.brandSafe(&neighbors[i]) {
+			return nil, nil
+		}
+	}
+
+	id, err := s.nextPromoted(ctx, s.cfg.Promotion, PromotionStateKey(s.cfg.PromotionState, query.Subreddit), query.Subreddit)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" && !shown[id] {
+		post, err := s.GetPost(ctx, id)
+		if err == nil && post != nil {
+			err = s.lookUpHidden(ctx, &query.Filter, []string{id})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if post != nil && !query.Filter.Hides(post, query.Subreddit) {
+			if post.Campaign == "" {
+				return post, nil
+			}
+			ok, err := s.impress(ctx, post.Campaign, query, neighbors)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return post, nil
+			}
+		}
+	}
+
+	// House ads aren't targeted.
+	if id, err = s.nextPromoted(ctx, s.cfg.HouseAds, s.cfg.HouseAdsState, ""); err != nil || id == "" || shown[id] {
+		return nil, err
+	}
+	post, err := s.GetPost(ctx, id)
+	if err == nil && post != nil {
+		err = s.lookUpHidden(ctx, &query.Filter, []string{id})
+	}
+	if err != nil || post == nil || query.Filter.Hides(post, query.Subreddit) {
+		return nil, err
+	}
+	return post, nil
+}
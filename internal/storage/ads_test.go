@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestPacedBudget(t *testing.T) {
+	Convey("Test pacing of campaigns", t, func() {
+		day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+
+		Convey("Nothing is spent at midnight unless a campaign may run ahead", func() {
+			So(PacedBudget(1000, day, 0), ShouldEqual, 0)
+			So(PacedBudget(1000, day, time.Minute), ShouldEqual, 1)
+		})
+
+		Convey("A campaign starts right after midnight however small its budget is", func() {
+			So(PacedBudget(1, day.Add(time.Second), 0), ShouldEqual, 1)
+		})
+
+		Convey("A budget is spent evenly through the day", func() {
+			So(PacedBudget(1000, day.Add(6*time.Hour), 0), ShouldEqual, 250)
+			So(PacedBudget(1000, day.Add(12*time.Hour), 0), ShouldEqual, 500)
+			So(PacedBudget(1000, day.Add(12*time.Hour), time.Hour), ShouldEqual, 542)
+		})
+
+		Convey("The whole budget is available by the end of the day", func() {
+			So(PacedBudget(1000, day.Add(24*time.Hour-time.Second), 0), ShouldEqual, 1000)
+			So(PacedBudget(1000, day.Add(24*time.Hour-time.Minute), time.Minute), ShouldEqual, 1000)
+		})
+
+		Convey("Running ahead never exceeds the daily budget", func() {
+			So(PacedBudget(1000, day.Add(23*time.Hour), 2*time.Hour), ShouldEqual, 1000)
+		})
+
+		Convey("Days are UTC days whatever the zone of the moment is", func() {
+			zone := time.FixedZone("UTC+3", 3*60*60)
+			So(PacedBudget(1000, day.Add(12*time.Hour).In(zone), 0), ShouldEqual, 500)
+		})
+	})
+}
+
+func TestClickAd(t *testing.T) {
+	Convey("Test clicks on promoted posts", t, func() {
+		ctx := context.Background()
+		s, mr := newTestStorage(t, &Config{Stream: "posts", AdServed: "ad_served", AdClicks: "ad_clicks"})
+
+		const hour = 1612000800
+		// Counters of clicks expire along with their hours.
+		mr.SetTime(time.Unix(hour, 0))
+		So(s.serve(ctx, &protocol.AdImpressed{Campaign: "1a", PostID: "1b", Viewer: "t2_abcdefg2", RequestID: "abc"}), ShouldBeNil)
+		So(mr.TTL("ad_served:abc"), ShouldEqual, servedTTL)
+
+		click := func(viewer, requestID string, created int64) bool {
+			counted, err := s.ClickAd(ctx, &protocol.AdClicked{
+				Campaign:  "1a",
+				PostID:    "1b",
+				Viewer:    viewer,
+				RequestID: requestID,
+				Created:   created,
+			})
+			So(err, ShouldBeNil)
+			return counted
+		}
+		published := func() int {
+			if !mr.Exists("posts") {
+				return 0
+			}
+			entries, err := mr.Stream("posts")
+			So(err, ShouldBeNil)
+			return len(entries)
+		}
+
+		Convey("A click without a response isn't counted", func() {
+			So(click("t2_abcdefg2", "", hour), ShouldBeFalse)
+			So(published(), ShouldEqual, 0)
+		})
+
+		Convey("A click referring to a response which hasn't shown the post isn't counted", func() {
+			So(click("t2_abcdefg2", "def", hour), ShouldBeFalse)
+			So(published(), ShouldEqual, 0)
+		})
+
+		Convey("A click of another viewer isn't counted", func() {
+			So(click("t2_abcdefg3", "abc", hour), ShouldBeFalse)
+			So(published(), ShouldEqual, 0)
+		})
+
+		Convey("A viewer's clicks on a campaign count once an hour", func() {
+			So(click("t2_abcdefg2", "abc", hour+10), ShouldBeTrue)
+			So(click("t2_abcdefg2", "abc", hour+20), ShouldBeFalse)
+			So(published(), ShouldEqual, 1)
+
+			mr.FastForward(time.Hour)
+			So(mr.Exists("ad_clicks:t2_abcdefg2:1612000800"), ShouldBeFalse)
+
+			So(click("t2_abcdefg2", "abc", hour+3600), ShouldBeTrue)
+			So(published(), ShouldEqual, 2)
+		})
+	})
+}
+
+// newAdsStorage runs a storage with a feed of five posts of r/golang and a live campaign promoting the post 3a.
+func newAdsStorage(t *testing.T, now time.Time) (*storage, *miniredis.Miniredis) {
+	s, mr := newTestStorage(t, &Config{
+		Stream: "posts", Feed: "feed", PageSize: 25, Posts: "post_by_id",
+		Promotion: "promotion_weights", PromotionState: "promotion_state", Targets: "promotion_targets",
+		HouseAds: "house_ads", HouseAdsState: "house_ads_state", Campaigns: "campaign_by_id",
+		Impressions: "impressions", Frequency: "frequency", AdServed: "ad_served", AdClicks: "ad_clicks",
+		AdsInterval: time.Minute, FrequencyCap: 3,
+	})
+	s.now = func() time.Time { return now }
+	mr.SetTime(now)
+
+	for i, id := range []string{"2a", "2b", "2c", "2d", "2e"} {
+		mr.HSet("post_by_id", id, `{"id":"`+id+`","title":"title","subreddit":"golang"}`)
+		if _, err := mr.ZAdd("feed", float64(10-i), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mr.HSet("post_by_id", "3a", `{"id":"3a","title":"Try Go","promoted":true,"campaign":"1a"}`)
+	mr.HSet("campaign_by_id", "1a", `{"id":"1a","owner":"t2_abcdefg9","name":"launch","creative":{"title":"Try Go"},
+		"start":`+strconv.FormatInt(now.Unix()-3600, 10)+`,"end":`+strconv.FormatInt(now.Unix()+3600, 10)+`,
+		"daily_budget":100000,"post_id":"3a"}`)
+	mr.HSet("promotion_weights", "3a", "100000")
+	return s, mr
+}
+
+// feedIDs lists identifiers of posts of a feed.
+func feedIDs(feed []protocol.Post) []string {
+	result := make([]string, 0, len(feed))
+	for _, post := range feed {
+		result = append(result, post.ID)
+	}
+	return result
+}
+
+func TestFeedAds(t *testing.T) {
+	Convey("Test promoted posts of feeds", t, func() {
+		ctx := context.Background()
+		s, mr := newAdsStorage(t, time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC))
+
+		Convey("A promoted post takes the second slot, and the response which has shown it is recorded", func() {
+			feed, _, err := s.GetFeed(ctx, &protocol.FeedQuery{Viewer: "t2_abcdefg2", RequestID: "abc"})
+			So(err, ShouldBeNil)
+			So(feedIDs(feed), ShouldResemble, []string{"2a", "3a", "2b", "2c", "2d", "2e"})
+			So(mr.HGet("ad_served:abc", "3a"), ShouldEqual, "t2_abcdefg2")
+		})
+
+		Convey("Syndicated feeds have no promoted posts", func() {
+			feed, _, err := s.GetFeed(ctx, &protocol.FeedQuery{Viewer: "t2_abcdefg2", RequestID: "abc", NoAds: true})
+			So(err, ShouldBeNil)
+			So(feedIDs(feed), ShouldResemble, []string{"2a", "2b", "2c", "2d", "2e"})
+			So(mr.Exists("ad_served:abc"), ShouldBeFalse)
+			So(mr.Exists("posts"), ShouldBeFalse)
+		})
+	})
+}
+
+func TestImpress(t *testing.T) {
+	Convey("Test impressions of campaigns", t, func() {
+		ctx := context.Background()
+		now := time.Date(2021, 1, 30, 12, 30, 0, 0, time.UTC)
+		s, mr := newAdsStorage(t, now)
+
+		query := &protocol.FeedQuery{Viewer: "t2_abcdefg2"}
+		impress := func() bool {
+			ok, err := s.impress(ctx, "1a", query, nil)
+			So(err, ShouldBeNil)
+			return ok
+		}
+		hour := time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC).Unix()
+		impressions := ImpressionsKey("impressions", "1a", now)
+		frequency := FrequencyKey("frequency", "t2_abcdefg2", hour)
+
+		Convey("A viewer sees a campaign up to the cap within an hour", func() {
+			So(impress(), ShouldBeTrue)
+			So(impress(), ShouldBeTrue)
+			So(impress(), ShouldBeTrue)
+			So(impress(), ShouldBeFalse)
+			So(mr.HGet(frequency, "1a"), ShouldEqual, "3")
+
+			// Other viewers aren't affected.
+			query.Viewer = "t2_abcdefg3"
+			So(impress(), ShouldBeTrue)
+		})
+
+		Convey("Internal callers without a viewer aren't capped", func() {
+			query.Viewer = ""
+			for i := 0; i < 5; i++ {
+				So(impress(), ShouldBeTrue)
+			}
+			So(mr.Exists(FrequencyKey("frequency", "", hour)), ShouldBeFalse)
+		})
+
+		Convey("An impression which is rejected by the cap doesn't count", func() {
+			for i := 0; i < 5; i++ {
+				impress()
+			}
+			value, err := mr.Get(impressions)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "3")
+			So(mr.HGet(frequency, "1a"), ShouldEqual, "3")
+		})
+
+		Convey("An impression which is ahead of the pace counts against neither the budget nor the cap", func() {
+			paced := PacedBudget(100000, now, time.Minute)
+			So(mr.Set(impressions, strconv.FormatInt(paced, 10)), ShouldBeNil)
+
+			So(impress(), ShouldBeFalse)
+			value, err := mr.Get(impressions)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, strconv.FormatInt(paced, 10))
+			So(mr.HGet(frequency, "1a"), ShouldEqual, "0")
+		})
+
+		Convey("Counters of a viewer expire along with the hour", func() {
+			for i := 0; i < 3; i++ {
+				impress()
+			}
+			So(mr.TTL(frequency), ShouldEqual, 30*time.Minute)
+
+			mr.FastForward(30 * time.Minute)
+			So(mr.Exists(frequency), ShouldBeFalse)
+
+			s.now = func() time.Time { return now.Add(30 * time.Minute) }
+			So(impress(), ShouldBeTrue)
+			So(mr.HGet(FrequencyKey("frequency", "t2_abcdefg2", hour+3600), "1a"), ShouldEqual, "1")
+		})
+	})
+}
+
+func TestFeedAdsShown(t *testing.T) {
+	Convey("Test promoted posts shown by a response", t, func() {
+		ctx := context.Background()
+		s, mr := newAdsStorage(t, time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC))
+		// The feed is long enough for both slots.
+		for i := 0; i < 15; i++ {
+			id := "4" + string(rune('a'+i))
+			mr.HSet("post_by_id", id, `{"id":"`+id+`","title":"title","subreddit":"golang"}`)
+			if _, err := mr.ZAdd("feed", float64(5-i), id); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		Convey("A response doesn't show the only promoted post of the rotation twice", func() {
+			feed, _, err := s.GetFeed(ctx, &protocol.FeedQuery{Viewer: "t2_abcdefg2"})
+			So(err, ShouldBeNil)
+			So(feed, ShouldHaveLength, 21)
+			promoted := 0
+			for _, post := range feed {
+				if post.ID == "3a" {
+					promoted++
+				}
+			}
+			So(promoted, ShouldEqual, 1)
+			// The second slot isn't counted as an impression either.
+			value, err := mr.Get(ImpressionsKey("impressions", "1a", s.now()))
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "1")
+			So(mr.HGet(FrequencyKey("frequency", "t2_abcdefg2", s.now().Unix()-s.now().Unix()%3600), "1a"), ShouldEqual, "1")
+		})
+
+		Convey("A house ad takes the slot instead", func() {
+			mr.HSet("post_by_id", "5a", `{"id":"5a","title":"Try Go","promoted":true,"house_ad":true}`)
+			mr.HSet("house_ads", "5a", "1")
+
+			feed, _, err := s.GetFeed(ctx, &protocol.FeedQuery{Viewer: "t2_abcdefg2"})
+			So(err, ShouldBeNil)
+			So(feed, ShouldHaveLength, 22)
+			So(feed[1].ID, ShouldEqual, "3a")
+			So(feed[15].ID, ShouldEqual, "5a")
+		})
+	})
+}
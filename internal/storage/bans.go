@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// BansKey names a hash keeping bans of a subreddit by users. Site-wide bans are kept by the prefix itself.
+func BansKey(prefix, subreddit string) string {
+	if subreddit == "" {
+		return prefix
+	}
+	return prefix + ":" + strings.ToLower(subreddit)
+}
+
+// BanUser saves a ban replacing the previous one. A ban from a subreddit comes with an action of a moderator,
+// which is written to the moderation log at once.
+func (s *storage) BanUser(ctx context.Context, ban *protocol.Ban, action *protocol.ModAction) error {
+	blob, err := s.encode(ban)
+	if err != nil {
+		return err
+	}
+	var entry []byte
+	if action != nil {
+		if entry, err = s.encode(action); err != nil {
+			return err
+		}
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, BansKey(s.cfg.Bans, ban.Subreddit), ban.User, blob)
+		if action != nil {
+			pipe.XAdd(ctx, s.logEntry(action.Subreddit, entry))
+		}
+		return nil
+	})
+	return err
+}
+
+// UnbanUser lifts a ban. Like BanUser, it writes an action of a moderator to the moderation log if there is one.
+func (s *storage) UnbanUser(ctx context.Context, subreddit, user string, action *protocol.ModAction) error {
+	var entry []byte
+	if action != nil {
+		var err error
+		if entry, err = s.encode(action); err != nil {
+			return err
+		}
+	}
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, BansKey(s.cfg.Bans, subreddit), user)
+		if action != nil {
+			pipe.XAdd(ctx, s.logEntry(action.Subreddit, entry))
+		}
+		return nil
+	})
+	return err
+}
+
+// GetBan returns a ban of a user, which may have expired already, or nil if there is no such ban.
+func (s *storage) GetBan(ctx context.Context, subreddit, user string) (*protocol.Ban, error) {
+	blob, err := s.client.HGet(ctx, BansKey(s.cfg.Bans, subreddit), user).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ban protocol.Ban
+	if err := s.decode([]byte(blob), &ban); err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// GetBans returns every ban of a subreddit including expired ones, the newest first.
+func (s *storage) GetBans(ctx context.Context, subreddit string) ([]protocol.Ban, error) {
+	blobs, err := s.client.HVals(ctx, BansKey(s.cfg.Bans, subreddit)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	bans := make([]protocol.Ban, 0, len(blobs))
+	for _, blob := range blobs {
+		var ban protocol.Ban
+		if err := s.decode([]byte(blob), &ban); err != nil {
+			return nil, err
+		}
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Created > bans[j].Created
+	})
+	return bans, nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// CommentIndexKey names a sorted set keeping replies to a post or a comment ordered in a particular way.
+// Posts and comments share a sequence of identifiers, so a parent is unambiguous.
+func CommentIndexKey(prefix, sort, parentID string) string {
+	return prefix + ":" + sort + ":" + parentID
+}
+
+func (s *storage) AddComment(ctx context.Context, comment *protocol.Comment) error {
+	id, err := s.nextID(ctx)
+	if err != nil {
+		return err
+	}
+	comment.ID = id
+
+	return s.publish(ctx, EventCommentSubmitted, comment)
+}
+
+func (s *storage) VoteComment(ctx context.Context, vote *protocol.CommentVoted) error {
+	return s.publish(ctx, EventCommentVoted, vote)
+}
+
+// GetComment returns a materialized comment or nil if there is no such comment.
+func (s *storage) GetComment(ctx context.Context, id string) (*protocol.Comment, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Comments, id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var comment protocol.Comment
+	if err := s.decode([]byte(blob), &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetComments renders a part of a thread. Replies which don't fit into the limit, the depth or the budget are
+// represented as placeholders with tokens continuing the listing.
+func (s *storage) GetComments(ctx context.Context, query *protocol.CommentsQuery) ([]protocol.Comment, *protocol.MoreComments, error) {
+	parentID := query.ParentID
+	if parentID == "" {
+		parentID = query.PostID
+	}
+	budget := query.Budget
+	return s.getReplies(ctx, query, parentID, query.Offset, query.Depth, &budget)
+}
+
+// getReplies renders replies to a parent spending the budget shared by the whole response. Replies go depth-first,
+// so the budget runs out on the last branches.
+func (s *storage) getReplies(ctx context.Context, query *protocol.CommentsQuery, parentID string, offset, depth int, budget *int) ([]protocol.Comment, *protocol.MoreComments, error) {
+	limit := query.Limit
+	if limit > *budget {
+		limit = *budget
+	}
+	key := CommentIndexKey(s.cfg.CommentIndex, query.Sort, parentID)
+	// Whatever the order is, the best, the top or the newest replies have the highest scores.
+	ids, err := s.client.ZRevRange(ctx, key, int64(offset), int64(offset+limit)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	// We've asked for one extra reply just to know whether there is something beyond the limit.
+	var more *protocol.MoreComments
+	if len(ids) > limit {
+		ids = ids[:limit]
+		total, err := s.client.ZCard(ctx, key).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		more = &protocol.MoreComments{
+			Count: int(total) - offset - len(ids),
+			Token: protocol.EncodeCommentsToken(parentID, offset+len(ids)),
+		}
+	}
+	if len(ids) == 0 {
+		return nil, more, nil
+	}
+
+	blobs, err := s.client.HMGet(ctx, s.cfg.Comments, ids...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	comments := make([]protocol.Comment, 0, len(blobs))
+	for _, blob := range blobs {
+		blob, ok := blob.(string)
+		if !ok {
+			continue
+		}
+		var comment protocol.Comment
+		if err := s.decode([]byte(blob), &comment); err != nil {
+			return nil, nil, err
+		}
+		comments = append(comments, comment)
+	}
+	*budget -= len(comments)
+
+	for i := range comments {
+		comment := &comments[i]
+		if comment.NumReplies == 0 {
+			continue
+		}
+		if depth <= 1 || *budget <= 0 {
+			comment.More = &protocol.MoreComments{
+				Count: comment.NumReplies,
+				Token: protocol.EncodeCommentsToken(comment.ID, 0),
+			}
+			continue
+		}
+		comment.Replies, comment.More, err = s.getReplies(ctx, query, comment.ID, 0, depth-1, budget)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return comments, more, nil
+}
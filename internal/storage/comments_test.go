@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestGetComments(t *testing.T) {
+	Convey("Test rendering a thread", t, func() {
+		ctx := context.Background()
+		s, mr := newTestStorage(t, &Config{Comments: "comment_by_id", CommentIndex: "comments"})
+
+		// Every comment of the post has three replies, and replies have none.
+		addComment := func(id, parentID string, score float64, replies int) {
+			blob, err := json.Marshal(&protocol.Comment{ID: id, PostID: "1a", ParentID: parentID, NumReplies: replies})
+			So(err, ShouldBeNil)
+			mr.HSet("comment_by_id", id, string(blob))
+			_, err = mr.ZAdd(CommentIndexKey("comments", protocol.CommentSortBest, parentID), score, id)
+			So(err, ShouldBeNil)
+		}
+		for i, id := range []string{"2a", "2b", "2c"} {
+			addComment(id, "1a", float64(3-i), 3)
+			for j, reply := range []string{"a", "b", "c"} {
+				addComment(id+reply, id, float64(3-j), 0)
+			}
+		}
+		query := &protocol.CommentsQuery{PostID: "1a", Sort: protocol.CommentSortBest, Limit: 3, Depth: 2, Budget: 100}
+
+		Convey("Replies are limited per level and by the depth", func() {
+			query.Depth = 1
+
+			comments, more, err := s.GetComments(ctx, query)
+
+			So(err, ShouldBeNil)
+			So(more, ShouldBeNil)
+			So(comments, ShouldHaveLength, 3)
+			for _, comment := range comments {
+				So(comment.Replies, ShouldBeNil)
+				So(comment.More, ShouldResemble, &protocol.MoreComments{Count: 3, Token: protocol.EncodeCommentsToken(comment.ID, 0)})
+			}
+		})
+
+		Convey("The budget is shared by the whole thread", func() {
+			query.Budget = 5
+
+			comments, more, err := s.GetComments(ctx, query)
+
+			So(err, ShouldBeNil)
+			So(more, ShouldBeNil)
+			So(comments, ShouldHaveLength, 3)
+			// The first comment takes what is left after the first level.
+			So(comments[0].Replies, ShouldHaveLength, 2)
+			So(comments[0].Replies[0].ID, ShouldEqual, "2aa")
+			So(comments[0].More, ShouldResemble, &protocol.MoreComments{Count: 1, Token: protocol.EncodeCommentsToken("2a", 2)})
+			for _, comment := range comments[1:] {
+				So(comment.Replies, ShouldBeNil)
+				So(comment.More, ShouldResemble, &protocol.MoreComments{Count: 3, Token: protocol.EncodeCommentsToken(comment.ID, 0)})
+			}
+		})
+
+		Convey("The first level is cut by the budget too", func() {
+			query.Budget = 2
+
+			comments, more, err := s.GetComments(ctx, query)
+
+			So(err, ShouldBeNil)
+			So(comments, ShouldHaveLength, 2)
+			So(more, ShouldResemble, &protocol.MoreComments{Count: 1, Token: protocol.EncodeCommentsToken("1a", 2)})
+			So(comments[0].Replies, ShouldBeNil)
+			So(comments[0].More, ShouldNotBeNil)
+		})
+	})
+}
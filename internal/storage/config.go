@@ -1,8 +1,54 @@
 package storage
 
+import "time"
+
 type Config struct {
-	Stream    string `env:"ES_STREAM,default=posts"`
-	Feed      string `env:"ES_FEED,default=feed"`
-	PageSize  int    `env:"FEED_PAGE_SIZE,default=25"`
-	Promotion string `env:"ES_PROMOTION,default=promotion"`
+	Stream         string        `env:"ES_STREAM,default=posts"`
+	Feed           string        `env:"ES_FEED,default=feed"`
+	PageSize       int           `env:"FEED_PAGE_SIZE,default=25"`
+	Promotion      string        `env:"ES_PROMOTION_WEIGHTS,default=promotion_weights"`
+	PromotionState string        `env:"ES_PROMOTION_STATE,default=promotion_state"`
+	Targets        string        `env:"ES_PROMOTION_TARGETS,default=promotion_targets"`
+	HouseAds       string        `env:"ES_HOUSE_ADS,default=house_ads"`
+	HouseAdsState  string        `env:"ES_HOUSE_ADS_STATE,default=house_ads_state"`
+	Posts          string        `env:"ES_POSTS,default=post_by_id"`
+	Sequence       string        `env:"ES_SEQUENCE,default=sequence"`
+	Comments       string        `env:"ES_COMMENTS,default=comment_by_id"`
+	CommentIndex   string        `env:"ES_COMMENT_INDEX,default=comments"`
+	Subreddits     string        `env:"ES_SUBREDDITS,default=subreddit_by_name"`
+	Subscriptions  string        `env:"ES_SUBSCRIPTIONS,default=subscriptions"`
+	Home           string        `env:"ES_HOME,default=home"`
+	Preferences    string        `env:"ES_PREFERENCES,default=preferences"`
+	Saved          string        `env:"ES_SAVED,default=saved"`
+	Hidden         string        `env:"ES_HIDDEN,default=hidden"`
+	HomeTTL        time.Duration `env:"HOME_FEED_TTL,default=1m"`
+	Users          string        `env:"ES_USERS,default=user_by_id"`
+	UserNames      string        `env:"ES_USER_NAMES,default=user_by_name"`
+	Karma          string        `env:"ES_KARMA,default=karma"`
+	Submitted      string        `env:"ES_SUBMITTED,default=submitted"`
+	Shadowbanned   string        `env:"ES_SHADOWBANNED,default=shadowbanned"`
+	Tokens         string        `env:"ES_TOKENS,default=token"`
+	Links          string        `env:"ES_LINKS,default=links"`
+	Reputation     string        `env:"ES_REPUTATION,default=reputation"`
+	ModQueue       string        `env:"ES_MODQUEUE,default=modqueue"`
+	Reports        string        `env:"ES_REPORTS,default=reports"`
+	Moderators     string        `env:"ES_MODERATORS,default=moderators"`
+	ModLog         string        `env:"ES_MODLOG,default=modlog"`
+	Bans           string        `env:"ES_BANS,default=bans"`
+	Campaigns      string        `env:"ES_CAMPAIGNS,default=campaign_by_id"`
+	CampaignIndex  string        `env:"ES_CAMPAIGN_INDEX,default=campaigns"`
+	Impressions    string        `env:"ES_IMPRESSIONS,default=impressions"`
+	Frequency      string        `env:"ES_FREQUENCY,default=frequency"`
+	AdStats        string        `env:"ES_AD_STATS,default=ad_stats"`
+	AdViewers      string        `env:"ES_AD_VIEWERS,default=ad_viewers"`
+	Advertisers    string        `env:"ES_ADVERTISERS,default=advertisers"`
+	AdServed       string        `env:"ES_AD_SERVED,default=ad_served"`
+	AdClicks       string        `env:"ES_AD_CLICKS,default=ad_clicks"`
+	// Campaigns may run ahead of an even pace by the interval of the ads scheduler.
+	AdsInterval time.Duration `env:"ADS_SCHEDULE_INTERVAL,default=1m"`
+	// Ads are never shown next to posts of sensitive subreddits or posts with sensitive flairs, nor next to NSFW posts.
+	SensitiveSubreddits []string `env:"ADS_SENSITIVE_SUBREDDITS"`
+	SensitiveFlairs     []string `env:"ADS_SENSITIVE_FLAIRS"`
+	// FrequencyCap limits impressions of a campaign per viewer within an hour, zero disables it.
+	FrequencyCap int64 `env:"ADS_FREQUENCY_CAP,default=3"`
 }
@@ -0,0 +1,20 @@
+package storage
+
+// StreamTypeField keeps a kind of an event. Messages without it are treated as EventPostSubmitted
+// since this is the only kind of events the very first version of the service has been producing.
+const StreamTypeField = "type"
+
+const (
+	EventPostSubmitted = "post_submitted"
+	EventPostEdited    = "post_edited"
+	EventPostDeleted   = "post_deleted"
+	EventPostVoted     = "post_voted"
+	EventPostReported  = "post_reported"
+	EventPostModerated = "post_moderated"
+
+	EventCommentSubmitted = "comment_submitted"
+	EventCommentVoted     = "comment_voted"
+
+	EventAdImpressed = "ad_impressed"
+	EventAdClicked   = "ad_clicked"
+)
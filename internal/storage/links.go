@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// LinkKey names a sorted set keeping posts of a normalized link ordered by submission time. Links are hashed,
+// since they can be long.
+func LinkKey(prefix, link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return prefix + ":" + hex.EncodeToString(sum[:])
+}
+
+// GetDuplicate returns the newest post of the link submitted to the subreddit since the moment or nil if there is
+// no such post. Posts of other subreddits are skipped until the moment is reached.
+func (s *storage) GetDuplicate(ctx context.Context, subreddit, link string, since int64) (*protocol.Post, error) {
+	for offset := 0; ; offset += s.cfg.PageSize {
+		ids, err := s.client.ZRevRangeByScore(ctx, LinkKey(s.cfg.Links, link), &redis.ZRangeBy{
+			Min:    strconv.FormatInt(since, 10),
+			Max:    "+inf",
+			Offset: int64(offset),
+			Count:  int64(s.cfg.PageSize),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		posts, err := s.getPosts(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range posts {
+			if strings.EqualFold(posts[i].Subreddit, subreddit) && !posts[i].Deleted {
+				return &posts[i], nil
+			}
+		}
+		if len(ids) < s.cfg.PageSize {
+			return nil, nil
+		}
+	}
+}
+
+// GetDuplicates returns a page of posts of the link, the newest first, and tells whether there are more. The post
+// the duplicates are listed for is excluded before paging, so pages stay full.
+func (s *storage) GetDuplicates(ctx context.Context, link, except string, page int) ([]protocol.Post, bool, error) {
+	key := LinkKey(s.cfg.Links, link)
+	start := int64(page * s.cfg.PageSize)
+	rank, err := s.client.ZRevRank(ctx, key, except).Result()
+	switch {
+	case err == redis.Nil:
+	case err != nil:
+		return nil, false, err
+	case rank < start:
+		start++
+	}
+
+	// The excluded post may be among them, and one more tells whether there are more.
+	ids, err := s.client.ZRevRange(ctx, key, start, start+int64(s.cfg.PageSize)+1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	others := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != except {
+			others = append(others, id)
+		}
+	}
+	others, more := s.cut(others)
+	posts, err := s.getPosts(ctx, others)
+	return posts, more, err
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestDuplicates(t *testing.T) {
+	Convey("Test duplicates of a link", t, func() {
+		ctx := context.Background()
+		s, mr := newTestStorage(t, &Config{Posts: "post_by_id", Links: "links", PageSize: 2})
+
+		const link = "https://reddit.com/"
+		// Posts 1..5 are submitted one by one at 1001..1005, and only the oldest one went to r/golang.
+		ids := []string{"1", "2", "3", "4", "5"}
+		for i, id := range ids {
+			subreddit := "programming"
+			if i == 0 {
+				subreddit = "golang"
+			}
+			blob, err := json.Marshal(&protocol.Post{ID: id, Link: link, Subreddit: subreddit})
+			So(err, ShouldBeNil)
+			mr.HSet("post_by_id", id, string(blob))
+			_, err = mr.ZAdd(LinkKey("links", link), float64(1001+i), id)
+			So(err, ShouldBeNil)
+		}
+		idsOf := func(posts []protocol.Post) []string {
+			result := []string{}
+			for _, post := range posts {
+				result = append(result, post.ID)
+			}
+			return result
+		}
+
+		Convey("A duplicate is found beyond the first page", func() {
+			post, err := s.GetDuplicate(ctx, "GoLang", link, 1000)
+
+			So(err, ShouldBeNil)
+			So(post, ShouldNotBeNil)
+			So(post.ID, ShouldEqual, "1")
+		})
+
+		Convey("A duplicate is looked for within the window only", func() {
+			post, err := s.GetDuplicate(ctx, "golang", link, 1002)
+
+			So(err, ShouldBeNil)
+			So(post, ShouldBeNil)
+		})
+
+		Convey("There are no duplicates of another link", func() {
+			post, err := s.GetDuplicate(ctx, "golang", "https://golang.org/", 0)
+
+			So(err, ShouldBeNil)
+			So(post, ShouldBeNil)
+		})
+
+		for _, c := range []struct {
+			except string
+			pages  [][]string
+		}{
+			{except: "5", pages: [][]string{{"4", "3"}, {"2", "1"}, {}}},
+			{except: "3", pages: [][]string{{"5", "4"}, {"2", "1"}, {}}},
+			{except: "1", pages: [][]string{{"5", "4"}, {"3", "2"}, {}}},
+		} {
+			c := c
+			Convey(fmt.Sprintf("Pages are full when post %s is excluded", c.except), func() {
+				for page, expected := range c.pages {
+					posts, _, err := s.GetDuplicates(ctx, link, c.except, page)
+
+					So(err, ShouldBeNil)
+					So(idsOf(posts), ShouldResemble, expected)
+				}
+			})
+		}
+	})
+}
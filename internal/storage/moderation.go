@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// ReportsKey names a hash keeping the reason of every user who has reported a post.
+func ReportsKey(prefix, id string) string {
+	return prefix + ":" + id
+}
+
+// moderatorsKey names a sorted set keeping moderators of a subreddit ordered by the time they've been appointed.
+func (s *storage) moderatorsKey(subreddit string) string {
+	return s.cfg.Moderators + ":" + strings.ToLower(subreddit)
+}
+
+// modLogKey names a stream of moderation actions of a subreddit.
+func (s *storage) modLogKey(subreddit string) string {
+	return s.cfg.ModLog + ":" + strings.ToLower(subreddit)
+}
+
+func (s *storage) ReportPost(ctx context.Context, report *protocol.PostReported) error {
+	return s.publish(ctx, EventPostReported, report)
+}
+
+// logEntry makes an entry of the moderation log of a subreddit.
+func (s *storage) logEntry(subreddit string, blob []byte) *redis.XAddArgs {
+	return &redis.XAddArgs{
+		Stream: s.modLogKey(subreddit),
+		Values: map[string]interface{}{StreamValueField: blob},
+	}
+}
+
+// ModeratePost publishes an action on a post and writes it to the moderation log at once.
+func (s *storage) ModeratePost(ctx context.Context, action *protocol.ModAction) error {
+	blob, err := s.encode(action)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.cfg.Stream,
+			Values: map[string]interface{}{
+				StreamTypeField:  EventPostModerated,
+				StreamValueField: blob,
+			},
+		})
+		pipe.XAdd(ctx, s.logEntry(action.Subreddit, blob))
+		return nil
+	})
+	return err
+}
+
+// IsModerator tells whether a user has been appointed a moderator of a subreddit. Creators of subreddits aren't
+// kept there, they are moderators anyway.
+func (s *storage) IsModerator(ctx context.Context, subreddit, user string) (bool, error) {
+	appointed, err := s.GetAppointed(ctx, subreddit, user)
+	return appointed != nil, err
+}
+
+// GetAppointed returns the time a user has been appointed a moderator of a subreddit or nil if the user isn't one.
+func (s *storage) GetAppointed(ctx context.Context, subreddit, user string) (*int64, error) {
+	score, err := s.client.ZScore(ctx, s.moderatorsKey(subreddit), user).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	appointed := int64(score)
+	return &appointed, nil
+}
+
+// GetModerators returns moderators of a subreddit in the order they've been appointed.
+func (s *storage) GetModerators(ctx context.Context, subreddit string) ([]string, error) {
+	return s.client.ZRange(ctx, s.moderatorsKey(subreddit), 0, -1).Result()
+}
+
+// AddModerator appoints the target of an action a moderator and writes the action to the moderation log at once.
+// A moderator appointed again keeps the original time.
+func (s *storage) AddModerator(ctx context.Context, action *protocol.ModAction) error {
+	blob, err := s.encode(action)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddNX(ctx, s.moderatorsKey(action.Subreddit), &redis.Z{Score: float64(action.Created), Member: action.Target})
+		pipe.XAdd(ctx, s.logEntry(action.Subreddit, blob))
+		return nil
+	})
+	return err
+}
+
+// RemoveModerator is the opposite of AddModerator.
+func (s *storage) RemoveModerator(ctx context.Context, action *protocol.ModAction) error {
+	blob, err := s.encode(action)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, s.moderatorsKey(action.Subreddit), action.Target)
+		pipe.XAdd(ctx, s.logEntry(action.Subreddit, blob))
+		return nil
+	})
+	return err
+}
+
+// GetModQueue returns posts waiting for moderators, the oldest first.
+func (s *storage) GetModQueue(ctx context.Context, subreddit string, page int) ([]protocol.ModQueueItem, error) {
+	ids, err := s.client.ZRangeByScore(ctx, ModQueueKey(s.cfg.ModQueue, subreddit), &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    "+inf",
+		Offset: int64(page * s.cfg.PageSize),
+		Count:  int64(s.cfg.PageSize),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.getPosts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make([]protocol.ModQueueItem, 0, len(posts))
+	for _, post := range posts {
+		if post.Deleted || post.Removed {
+			continue
+		}
+		reasons, err := s.client.HVals(ctx, ReportsKey(s.cfg.Reports, post.ID)).Result()
+		if err != nil {
+			return nil, err
+		}
+		item := protocol.ModQueueItem{Post: post}
+		if len(reasons) != 0 {
+			item.Reports = make(map[string]int, len(reasons))
+			for _, reason := range reasons {
+				item.Reports[reason]++
+			}
+		}
+		queue = append(queue, item)
+	}
+	return queue, nil
+}
+
+// GetModLog returns a page of the moderation log of a subreddit, the newest first. A page starts after
+// the entry with the given identifier, or from the very end if it's empty.
+func (s *storage) GetModLog(ctx context.Context, subreddit, after string) ([]protocol.ModAction, error) {
+	start, count := "+", int64(s.cfg.PageSize)
+	if after != "" {
+		// The range is inclusive, so the entry itself is fetched and skipped.
+		start, count = after, count+1
+	}
+	messages, err := s.client.XRevRangeN(ctx, s.modLogKey(subreddit), start, "-", count).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]protocol.ModAction, 0, len(messages))
+	for _, message := range messages {
+		if message.ID == after {
+			continue
+		}
+		blob, ok := message.Values[StreamValueField].(string)
+		if !ok {
+			continue
+		}
+		var action protocol.ModAction
+		if err := s.decode([]byte(blob), &action); err != nil {
+			return nil, err
+		}
+		action.ID = message.ID
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
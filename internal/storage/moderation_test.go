@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestModerators(t *testing.T) {
+	Convey("Test moderators", t, func() {
+		ctx := context.Background()
+		s, _ := newTestStorage(t, &Config{Moderators: "moderators", ModLog: "modlog"})
+
+		appoint := func(user string, created int64) {
+			So(s.AddModerator(ctx, &protocol.ModAction{
+				Subreddit: "GoLang",
+				Moderator: "t2_abcdefg2",
+				Action:    protocol.ModAddModerator,
+				Target:    user,
+				Created:   created,
+			}), ShouldBeNil)
+		}
+
+		Convey("Moderators are kept in the order they've been appointed", func() {
+			appoint("t2_abcdefg4", 100)
+			appoint("t2_abcdefg3", 200)
+			// Appointing a moderator again changes nothing.
+			appoint("t2_abcdefg4", 300)
+
+			moderators, err := s.GetModerators(ctx, "golang")
+			So(err, ShouldBeNil)
+			So(moderators, ShouldResemble, []string{"t2_abcdefg4", "t2_abcdefg3"})
+
+			appointed, err := s.GetAppointed(ctx, "golang", "t2_abcdefg4")
+			So(err, ShouldBeNil)
+			So(*appointed, ShouldEqual, 100)
+			appointed, err = s.GetAppointed(ctx, "golang", "t2_abcdefg5")
+			So(err, ShouldBeNil)
+			So(appointed, ShouldBeNil)
+
+			So(s.RemoveModerator(ctx, &protocol.ModAction{Subreddit: "GoLang", Action: protocol.ModRemoveModerator, Target: "t2_abcdefg4"}), ShouldBeNil)
+			ok, err := s.IsModerator(ctx, "golang", "t2_abcdefg4")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+	})
+}
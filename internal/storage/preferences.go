@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// SavedKey names a sorted set keeping posts saved by a user ordered by the time of saving.
+func SavedKey(prefix, user string) string {
+	return prefix + ":" + user
+}
+
+// HiddenKey names a set keeping posts hidden by a user.
+func HiddenKey(prefix, user string) string {
+	return prefix + ":" + user
+}
+
+// GetPreferences returns preferences of a user. Users who have never changed them get the defaults.
+func (s *storage) GetPreferences(ctx context.Context, user string) (*protocol.Preferences, error) {
+	var prefs protocol.Preferences
+	blob, err := s.client.HGet(ctx, s.cfg.Preferences, user).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return &prefs, nil
+		}
+		return nil, err
+	}
+	if err := s.decode([]byte(blob), &prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (s *storage) SetPreferences(ctx context.Context, user string, prefs *protocol.Preferences) error {
+	blob, err := s.encode(prefs)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.cfg.Preferences, user, blob).Err()
+}
+
+// SavePost saves a post for a user. Saving a post again moves it to the top of the listing.
+func (s *storage) SavePost(ctx context.Context, user, id string, saved int64) error {
+	return s.client.ZAdd(ctx, SavedKey(s.cfg.Saved, user), &redis.Z{Score: float64(saved), Member: id}).Err()
+}
+
+func (s *storage) UnsavePost(ctx context.Context, user, id string) error {
+	return s.client.ZRem(ctx, SavedKey(s.cfg.Saved, user), id).Err()
+}
+
+// GetSaved returns a page of posts saved by a user, the most recently saved first, and tells whether there are more.
+func (s *storage) GetSaved(ctx context.Context, user string, page int) ([]protocol.Post, bool, error) {
+	start := int64(page * s.cfg.PageSize)
+	ids, err := s.client.ZRevRange(ctx, SavedKey(s.cfg.Saved, user), start, start+int64(s.cfg.PageSize)).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	ids, more := s.cut(ids)
+	posts, err := s.getPosts(ctx, ids)
+	return posts, more, err
+}
+
+func (s *storage) HidePost(ctx context.Context, user, id string) error {
+	return s.client.SAdd(ctx, HiddenKey(s.cfg.Hidden, user), id).Err()
+}
+
+func (s *storage) UnhidePost(ctx context.Context, user, id string) error {
+	return s.client.SRem(ctx, HiddenKey(s.cfg.Hidden, user), id).Err()
+}
+
+// GetHidden tells which of the posts a user has hidden. Only the posts are looked up, so it doesn't depend on how
+// many posts the user has hidden.
+func (s *storage) GetHidden(ctx context.Context, user string, ids []string) (map[string]bool, error) {
+	hidden := map[string]bool{}
+	if len(ids) == 0 {
+		return hidden, nil
+	}
+	key := HiddenKey(s.cfg.Hidden, user)
+	cmds := make([]*redis.BoolCmd, len(ids))
+	if _, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.SIsMember(ctx, key, id)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if cmd.Val() {
+			hidden[ids[i]] = true
+		}
+	}
+	return hidden, nil
+}
+
+// lookUpHidden lets the filter hide the posts its user has hidden among the given ones.
+func (s *storage) lookUpHidden(ctx context.Context, filter *protocol.FeedFilter, ids []string) error {
+	if filter.User == "" {
+		return nil
+	}
+	hidden, err := s.GetHidden(ctx, filter.User, ids)
+	if err != nil {
+		return err
+	}
+	if filter.HiddenPosts == nil {
+		filter.HiddenPosts = make(map[string]bool, len(hidden))
+	}
+	for id := range hidden {
+		filter.HiddenPosts[id] = true
+	}
+	return nil
+}
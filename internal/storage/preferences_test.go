@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestHiddenPosts(t *testing.T) {
+	Convey("Test posts hidden by a viewer", t, func() {
+		ctx := context.Background()
+		s, _ := newAdsStorage(t, time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC))
+		s.cfg.Hidden = "hidden"
+		So(s.HidePost(ctx, "t2_abcdefg2", "2b"), ShouldBeNil)
+		So(s.HidePost(ctx, "t2_abcdefg2", "9z"), ShouldBeNil)
+
+		query := func(page int) *protocol.FeedQuery {
+			return &protocol.FeedQuery{Page: page, Viewer: "t2_abcdefg2", NoAds: true, Filter: protocol.FeedFilter{User: "t2_abcdefg2"}}
+		}
+
+		Convey("Only the given posts are looked up", func() {
+			hidden, err := s.GetHidden(ctx, "t2_abcdefg2", []string{"2a", "2b", "2c"})
+			So(err, ShouldBeNil)
+			So(hidden, ShouldResemble, map[string]bool{"2b": true})
+
+			hidden, err = s.GetHidden(ctx, "t2_abcdefg2", nil)
+			So(err, ShouldBeNil)
+			So(hidden, ShouldBeEmpty)
+		})
+
+		Convey("Hidden posts leave a page short, and the next page doesn't repeat posts", func() {
+			s.cfg.PageSize = 3
+
+			feed, more, err := s.GetFeed(ctx, query(0))
+			So(err, ShouldBeNil)
+			So(feedIDs(feed), ShouldResemble, []string{"2a", "2c"})
+			So(more, ShouldBeTrue)
+
+			feed, more, err = s.GetFeed(ctx, query(1))
+			So(err, ShouldBeNil)
+			So(feedIDs(feed), ShouldResemble, []string{"2d", "2e"})
+			So(more, ShouldBeFalse)
+		})
+
+		Convey("Posts hidden by others are shown", func() {
+			feed, _, err := s.GetFeed(ctx, &protocol.FeedQuery{NoAds: true, Filter: protocol.FeedFilter{User: "t2_abcdefg3"}})
+			So(err, ShouldBeNil)
+			So(feedIDs(feed), ShouldResemble, []string{"2a", "2b", "2c", "2d", "2e"})
+		})
+
+		Convey("A promoted post the viewer has hidden isn't shown", func() {
+			So(s.HidePost(ctx, "t2_abcdefg2", "3a"), ShouldBeNil)
+			q := query(0)
+			q.NoAds = false
+
+			feed, _, err := s.GetFeed(ctx, q)
+			So(err, ShouldBeNil)
+			So(feedIDs(feed), ShouldResemble, []string{"2a", "2c", "2d", "2e"})
+		})
+	})
+}
@@ -0,0 +1,31 @@
+package storage
+
+import "context"
+
+// Fields of a reputation hash.
+const (
+	ReputationPosts = "posts"
+	ReputationSpam  = "spam"
+)
+
+// ReputationKey names a hash counting posts of a domain and the ones of them which turned out to be spam.
+func ReputationKey(prefix, domain string) string {
+	return prefix + ":" + domain
+}
+
+// GetReputation returns the number of accepted posts of a domain and the number of spam ones.
+func (s *storage) GetReputation(ctx context.Context, domain string) (int, int, error) {
+	counters, err := s.client.HGetAll(ctx, ReputationKey(s.cfg.Reputation, domain)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	posts, err := atoi(counters[ReputationPosts])
+	if err != nil {
+		return 0, 0, err
+	}
+	spam, err := atoi(counters[ReputationSpam])
+	if err != nil {
+		return 0, 0, err
+	}
+	return posts, spam, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// rotate picks the next promoted post by smooth weighted round-robin: every post gains its weight, the post
+// with the most current weight is picked, and it loses the total weight. Hence a post with the weight of 3 is
+// picked three times as often as a post with the weight of 1, and picks are interleaved rather than bunched.
+//
+// The weights are kept in KEYS[1] and current weights in KEYS[2]. Redis runs a script atomically, so replicas
+// share a single rotation. The current weights are rewritten every time, which drops posts gone from KEYS[1].
+//
+// Only posts targeted at the feed of the subreddit ARGV[1] take part, their targeting is kept in KEYS[3]. Every
+// feed needs a rotation of its own then, since the posts taking part differ.
+var rotate = redis.NewScript(`
+local function targets(id)
+	local blob = redis.call('HGET', KEYS[3], id)
+	if not blob then
+		return true
+	end
+	local targeting = cjson.decode(blob)
+	for _, name in ipairs(targeting.excluded_subreddits or {}) do
+		if string.lower(name) == ARGV[1] then
+			return false
+		end
+	end
+	local subreddits = targeting.subreddits or {}
+	if #subreddits == 0 then
+		return true
+	end
+	for _, name in ipairs(subreddits) do
+		if string.lower(name) == ARGV[1] then
+			return true
+		end
+	end
+	return false
+end
+
+local weights = redis.call('HGETALL', KEYS[1])
+local current = {}
+local total = 0
+local best, most
+for i = 1, #weights, 2 do
+	local id, weight = weights[i], tonumber(weights[i + 1])
+	if weight and weight > 0 and targets(id) then
+		local value = tonumber(redis.call('HGET', KEYS[2], id) or '0') + weight
+		current[id] = value
+		total = total + weight
+		if not most or value > most or (value == most and id < best) then
+			best, most = id, value
+		end
+	end
+end
+redis.call('DEL', KEYS[2])
+if not best then
+	return false
+end
+current[best] = current[best] - total
+for id, value in pairs(current) do
+	redis.call('HSET', KEYS[2], id, value)
+end
+return best
+`)
+
+// PromotionStateKey names a hash keeping current weights of the rotation of promoted posts for the feed
+// of a subreddit, or for the global and home feeds if the subreddit is empty.
+func PromotionStateKey(prefix, subreddit string) string {
+	if subreddit == "" {
+		return prefix
+	}
+	return prefix + ":" + strings.ToLower(subreddit)
+}
+
+// nextPromoted returns the identifier of the next promoted post of a rotation targeted at the feed of a subreddit
+// or an empty string if there are none.
+func (s *storage) nextPromoted(ctx context.Context, weights, state, subreddit string) (string, error) {
+	keys := []string{weights, state, s.cfg.Targets}
+	id, err := rotate.Run(ctx, s.client, keys, strings.ToLower(subreddit)).Text()
+	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
+		return "", err
+	}
+	return id, nil
+}
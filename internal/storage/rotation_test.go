@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRotate(t *testing.T) {
+	Convey("Test the rotation of promoted posts", t, func() {
+		ctx := context.Background()
+		s, mr := newTestStorage(t, &Config{Targets: "promotion_targets"})
+
+		picks := func(subreddit string, n int) []string {
+			result := make([]string, 0, n)
+			for i := 0; i < n; i++ {
+				id, err := s.nextPromoted(ctx, "promotion_weights", "promotion_state", subreddit)
+				So(err, ShouldBeNil)
+				result = append(result, id)
+			}
+			return result
+		}
+
+		Convey("Nothing is picked from an empty rotation", func() {
+			So(picks("", 1), ShouldResemble, []string{""})
+			So(mr.Exists("promotion_state"), ShouldBeFalse)
+		})
+
+		Convey("Posts are picked in proportion to their weights and interleaved", func() {
+			mr.HSet("promotion_weights", "1a", "3", "1b", "1")
+
+			So(picks("", 8), ShouldResemble, []string{"1a", "1a", "1b", "1a", "1a", "1a", "1b", "1a"})
+		})
+
+		Convey("Ties go to the least identifier", func() {
+			mr.HSet("promotion_weights", "1b", "1", "1a", "1")
+
+			So(picks("", 4), ShouldResemble, []string{"1a", "1b", "1a", "1b"})
+		})
+
+		Convey("Posts without a positive weight aren't picked", func() {
+			mr.HSet("promotion_weights", "1a", "0", "1b", "-1", "1c", "x", "1d", "1")
+
+			So(picks("", 2), ShouldResemble, []string{"1d", "1d"})
+		})
+
+		Convey("Current weights of posts gone from the rotation are dropped", func() {
+			mr.HSet("promotion_weights", "1a", "1", "1b", "1")
+			So(picks("", 1), ShouldResemble, []string{"1a"})
+			So(mr.HGet("promotion_state", "1b"), ShouldEqual, "1")
+
+			mr.HDel("promotion_weights", "1b")
+			mr.HSet("promotion_state", "1c", "100")
+
+			So(picks("", 1), ShouldResemble, []string{"1a"})
+			keys, err := mr.HKeys("promotion_state")
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"1a"})
+		})
+
+		Convey("Only posts targeted at the feed take part", func() {
+			mr.HSet("promotion_weights", "1a", "1", "1b", "1", "1c", "1")
+			mr.HSet("promotion_targets",
+				"1a", `{"subreddits":["GoLang"]}`,
+				"1b", `{"excluded_subreddits":["GoLang"]}`,
+			)
+
+			Convey("A subreddit feed has the posts targeted at it and untargeted ones", func() {
+				So(picks("golang", 4), ShouldResemble, []string{"1a", "1c", "1a", "1c"})
+			})
+
+			Convey("The global feed has untargeted posts only", func() {
+				So(picks("", 4), ShouldResemble, []string{"1b", "1c", "1b", "1c"})
+			})
+
+			Convey("Other subreddit feeds skip posts targeted elsewhere", func() {
+				So(picks("rust", 4), ShouldResemble, []string{"1b", "1c", "1b", "1c"})
+			})
+		})
+	})
+}
@@ -3,13 +3,16 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 
 	"nanoreddit/pkg/protocol"
 )
 
-//TODO using such constants crosspackagely isn't a good idea. it would be better to extract it into an abstration
+// TODO using such constants crosspackagely isn't a good idea. it would be better to extract it into an abstration
 const StreamValueField = "event"
 
 type storage struct {
@@ -17,17 +20,21 @@ type storage struct {
 	client redis.Cmdable
 	encode func(v interface{}) ([]byte, error)
 	decode func(data []byte, v interface{}) error
+	now    func() time.Time
 }
 
-func (s *storage) AddPost(ctx context.Context, post *protocol.Post) error {
-	blob, err := s.encode(post)
+func (s *storage) publish(ctx context.Context, eventType string, event interface{}) error {
+	blob, err := s.encode(event)
 	if err != nil {
 		return err
 	}
 
 	a := redis.XAddArgs{
 		Stream: s.cfg.Stream,
-		Values: map[string]interface{}{StreamValueField: blob},
+		Values: map[string]interface{}{
+			StreamTypeField:  eventType,
+			StreamValueField: blob,
+		},
 	}
 	if err := s.client.XAdd(ctx, &a).Err(); err != nil {
 		return err
@@ -36,58 +43,187 @@ func (s *storage) AddPost(ctx context.Context, post *protocol.Post) error {
 	return nil
 }
 
-func (s *storage) GetFeed(ctx context.Context, page int) ([]protocol.Post, error) {
-	// Posts on Redis are already sorted by score.
-	blobs, err := s.client.ZRevRangeByScore(ctx, s.cfg.Feed, &redis.ZRangeBy{
-		Min:    "-inf",
-		Max:    "+inf",
-		Offset: int64(page * s.cfg.PageSize),
-		Count:  int64(s.cfg.PageSize),
-	}).Result()
+// nextID issues a new unique identifier. Like Reddit does, we encode a sequence number in base 36.
+func (s *storage) nextID(ctx context.Context) (string, error) {
+	n, err := s.client.Incr(ctx, s.cfg.Sequence).Result()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(n, 36), nil
+}
+
+func (s *storage) AddPost(ctx context.Context, post *protocol.Post) error {
+	id, err := s.nextID(ctx)
 	if err != nil {
+		return err
+	}
+	post.ID = id
+
+	return s.publish(ctx, EventPostSubmitted, post)
+}
+
+func (s *storage) EditPost(ctx context.Context, edit *protocol.PostEdited) error {
+	return s.publish(ctx, EventPostEdited, edit)
+}
+
+func (s *storage) DeletePost(ctx context.Context, deletion *protocol.PostDeleted) error {
+	return s.publish(ctx, EventPostDeleted, deletion)
+}
+
+func (s *storage) VotePost(ctx context.Context, vote *protocol.PostVoted) error {
+	return s.publish(ctx, EventPostVoted, vote)
+}
+
+// GetPost returns a materialized post or nil if there is no such post.
+func (s *storage) GetPost(ctx context.Context, id string) (*protocol.Post, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Posts, id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	// A result can have up to two additional promoted posts.
-	feed := make([]protocol.Post, 0, len(blobs)+2)
+	var post protocol.Post
+	if err := s.decode([]byte(blob), &post); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// cut cuts a page off identifiers which have been read one beyond it, and it tells whether there are more.
+func (s *storage) cut(ids []string) ([]string, bool) {
+	if len(ids) > s.cfg.PageSize {
+		return ids[:s.cfg.PageSize], true
+	}
+	return ids, false
+}
+
+// getPosts resolves identifiers into posts keeping their order. Posts that aren't found are skipped.
+func (s *storage) getPosts(ctx context.Context, ids []string) ([]protocol.Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	blobs, err := s.client.HMGet(ctx, s.cfg.Posts, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]protocol.Post, 0, len(blobs))
 	for _, blob := range blobs {
+		blob, ok := blob.(string)
+		if !ok {
+			continue
+		}
 		var post protocol.Post
-		if err := json.Unmarshal([]byte(blob), &post); err != nil {
+		if err := s.decode([]byte(blob), &post); err != nil {
 			return nil, err
 		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// SubredditFeedKey names a sorted set keeping posts of a subreddit ranked like the global feed.
+func SubredditFeedKey(prefix, subreddit string) string {
+	return prefix + ":" + strings.ToLower(subreddit)
+}
+
+// ModQueueKey names a sorted set keeping posts of a subreddit waiting for moderators ordered by submission time.
+func ModQueueKey(prefix, subreddit string) string {
+	return prefix + ":" + strings.ToLower(subreddit)
+}
+
+// GetFeed returns a page of a feed and tells whether there are more pages.
+func (s *storage) GetFeed(ctx context.Context, query *protocol.FeedQuery) ([]protocol.Post, bool, error) {
+	key := s.cfg.Feed
+	switch {
+	case query.Subreddit != "":
+		key = SubredditFeedKey(s.cfg.Feed, query.Subreddit)
+	case query.Home:
+		var err error
+		if key, err = s.homeFeed(ctx, query.User); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Posts on Redis are already sorted by score. Filtered posts leave a page short rather than taking posts of
+	// the next page, so pages never repeat posts.
+	ids, err := s.client.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    "+inf",
+		Offset: int64(query.Page * s.cfg.PageSize),
+		Count:  int64(s.cfg.PageSize + 1),
+	}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	ids, more := s.cut(ids)
+	if err := s.lookUpHidden(ctx, &query.Filter, ids); err != nil {
+		return nil, false, err
+	}
+	posts, err := s.getPosts(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// A result can have up to two additional promoted posts.
+	feed := make([]protocol.Post, 0, len(posts)+2)
+	shown := make(map[string]bool, 2)
+	for _, post := range posts {
+		// A cached listing may still refer to posts which have been deleted or removed since then.
+		if post.Deleted || post.Removed {
+			continue
+		}
+		// Posts are filtered before slots of promoted posts are counted, so the slots stay in place.
+		if query.Filter.Hides(&post, query.Subreddit) {
+			continue
+		}
 		feed = append(feed, post)
 
 		// TODO get rid a magic number
 		// TODO check the case when we got 16 posts
 		// We have to add a promoted post only if we've just reached 3 or 17 posts but not yet exceeded it.
-		if len(feed) != 3 && len(feed) != 17 {
+		if query.NoAds || len(feed) != 3 && len(feed) != 17 {
 			continue
 		}
-		// Unless there are some NSFW posts.
-		if feed[len(feed)-3].NSFW || feed[len(feed)-2].NSFW {
-			continue
-		}
-
-		// RPOPLPUSH lets a list to act as a circular one. Hence we can show promoted posts evenly.
-		blob, err := s.client.RPopLPush(ctx, s.cfg.Promotion, s.cfg.Promotion).Result()
+		// Unless the posts around the slot aren't brand-safe.
+		promotedPost, err := s.promoted(ctx, query, feed[len(feed)-3:len(feed)-1], shown)
 		if err != nil {
-			if err == redis.Nil {
-				continue
-			}
-			return nil, err
+			return nil, false, err
 		}
-
-		var promotedPost protocol.Post
-		if err := json.Unmarshal([]byte(blob), &promotedPost); err != nil {
-			return nil, err
+		if promotedPost == nil {
+			continue
 		}
 		// Insert a promoted post into feed.
 		//TODO improve
 		prev := len(feed)
-		feed = append(feed, promotedPost)
+		feed = append(feed, *promotedPost)
 		feed[prev-2], feed[prev-1], feed[prev] = feed[prev], feed[prev-2], feed[prev-1]
+		shown[promotedPost.ID] = true
+
+		if promotedPost.Campaign != "" {
+			impression := protocol.AdImpressed{
+				Campaign:  promotedPost.Campaign,
+				PostID:    promotedPost.ID,
+				Viewer:    query.Viewer,
+				Subreddit: query.Subreddit,
+				Page:      query.Page,
+				Slot:      prev - 2,
+				RequestID: query.RequestID,
+				Created:   s.now().Unix(),
+			}
+			if err := s.publish(ctx, EventAdImpressed, &impression); err != nil {
+				return nil, false, err
+			}
+			if impression.RequestID != "" {
+				if err := s.serve(ctx, &impression); err != nil {
+					return nil, false, err
+				}
+			}
+		}
 	}
-	return feed, nil
+	return feed, more, nil
 }
 
 func NewStorage(cfg *Config, client redis.Cmdable) *storage {
@@ -96,5 +232,6 @@ func NewStorage(cfg *Config, client redis.Cmdable) *storage {
 		client: client,
 		encode: json.Marshal,
 		decode: json.Unmarshal,
+		now:    time.Now,
 	}
 }
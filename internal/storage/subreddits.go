@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// AddSubreddit registers a subreddit. It returns false if the name is already taken.
+// Unlike posts, subreddits are written directly: a name must be reserved at once,
+// and it must be visible for submissions right after the response.
+func (s *storage) AddSubreddit(ctx context.Context, subreddit *protocol.Subreddit) (bool, error) {
+	blob, err := s.encode(subreddit)
+	if err != nil {
+		return false, err
+	}
+	// Names are case-insensitive like they are on Reddit.
+	return s.client.HSetNX(ctx, s.cfg.Subreddits, strings.ToLower(subreddit.Name), blob).Result()
+}
+
+// GetSubreddit returns a subreddit or nil if there is no such subreddit.
+func (s *storage) GetSubreddit(ctx context.Context, name string) (*protocol.Subreddit, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Subreddits, strings.ToLower(name)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var subreddit protocol.Subreddit
+	if err := s.decode([]byte(blob), &subreddit); err != nil {
+		return nil, err
+	}
+	return &subreddit, nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func (s *storage) subscriptionsKey(user string) string {
+	return s.cfg.Subscriptions + ":" + user
+}
+
+func (s *storage) homeKey(user string) string {
+	return s.cfg.Home + ":" + user
+}
+
+func (s *storage) Subscribe(ctx context.Context, user, subreddit string) error {
+	if err := s.client.SAdd(ctx, s.subscriptionsKey(user), subreddit).Err(); err != nil {
+		return err
+	}
+	// The next request will merge the subscriptions again.
+	return s.client.Del(ctx, s.homeKey(user)).Err()
+}
+
+func (s *storage) Unsubscribe(ctx context.Context, user, subreddit string) error {
+	if err := s.client.SRem(ctx, s.subscriptionsKey(user), subreddit).Err(); err != nil {
+		return err
+	}
+	return s.client.Del(ctx, s.homeKey(user)).Err()
+}
+
+func (s *storage) GetSubscriptions(ctx context.Context, user string) ([]string, error) {
+	subreddits, err := s.client.SMembers(ctx, s.subscriptionsKey(user)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(subreddits)
+	return subreddits, nil
+}
+
+// homeFeed returns a key of a sorted set merging feeds of subreddits the user subscribes to.
+// The merge is cached for a while, so paging through a home feed is consistent and cheap.
+// Users without subscriptions get the global feed.
+func (s *storage) homeFeed(ctx context.Context, user string) (string, error) {
+	key := s.homeKey(user)
+	n, err := s.client.Exists(ctx, key).Result()
+	if err != nil {
+		return "", err
+	}
+	if n != 0 {
+		return key, nil
+	}
+
+	subreddits, err := s.GetSubscriptions(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	if len(subreddits) == 0 {
+		return s.cfg.Feed, nil
+	}
+	keys := make([]string, 0, len(subreddits))
+	for _, subreddit := range subreddits {
+		keys = append(keys, SubredditFeedKey(s.cfg.Feed, subreddit))
+	}
+	// A post belongs to a single subreddit, so there is nothing to aggregate actually.
+	if err := s.client.ZUnionStore(ctx, key, &redis.ZStore{Keys: keys, Aggregate: "MAX"}).Err(); err != nil {
+		return "", err
+	}
+	if err := s.client.Expire(ctx, key, s.cfg.HomeTTL).Err(); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// TokenKey names a key keeping an API token. Only a digest of a token is used, so the storage never reveals a usable credential.
+func TokenKey(prefix, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return prefix + ":" + hex.EncodeToString(sum[:])
+}
+
+// AddToken issues an API token. A token with an expiration time disappears from the storage by itself.
+func (s *storage) AddToken(ctx context.Context, grant *protocol.AccessToken) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	blob, err := s.encode(grant)
+	if err != nil {
+		return "", err
+	}
+	var ttl time.Duration
+	if grant.Expires != 0 {
+		ttl = time.Unix(grant.Expires, 0).Sub(s.now())
+	}
+	if err := s.client.Set(ctx, TokenKey(s.cfg.Tokens, token), blob, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetToken returns what an API token grants or nil if the token is unknown.
+func (s *storage) GetToken(ctx context.Context, token string) (*protocol.AccessToken, error) {
+	blob, err := s.client.Get(ctx, TokenKey(s.cfg.Tokens, token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var grant protocol.AccessToken
+	if err := s.decode([]byte(blob), &grant); err != nil {
+		return nil, err
+	}
+	// A key may outlive a token a little, since its TTL is computed when the token is issued.
+	if grant.Expires != 0 && s.now().Unix() >= grant.Expires {
+		return nil, nil
+	}
+	return &grant, nil
+}
+
+// RevokeToken drops an API token of the user. Tokens of others are left intact.
+func (s *storage) RevokeToken(ctx context.Context, user, token string) error {
+	grant, err := s.GetToken(ctx, token)
+	if err != nil || grant == nil || grant.User != user {
+		return err
+	}
+	return s.client.Del(ctx, TokenKey(s.cfg.Tokens, token)).Err()
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+func TestTokens(t *testing.T) {
+	Convey("Test API tokens", t, func() {
+		ctx := context.Background()
+		now := time.Date(2021, 1, 30, 12, 0, 0, 0, time.UTC)
+		s, mr := newTestStorage(t, &Config{Tokens: "token"})
+		s.now = func() time.Time { return now }
+
+		Convey("A token is kept by its digest until it expires", func() {
+			grant := &protocol.AccessToken{User: "t2_abcdefg2", Scopes: []string{"read"}, Expires: now.Add(time.Hour).Unix()}
+			token, err := s.AddToken(ctx, grant)
+			So(err, ShouldBeNil)
+			So(mr.Exists(TokenKey("token", token)), ShouldBeTrue)
+			So(mr.TTL(TokenKey("token", token)), ShouldEqual, time.Hour)
+
+			got, err := s.GetToken(ctx, token)
+			So(err, ShouldBeNil)
+			So(got, ShouldResemble, grant)
+
+			now = now.Add(time.Hour)
+			got, err = s.GetToken(ctx, token)
+			So(err, ShouldBeNil)
+			So(got, ShouldBeNil)
+		})
+
+		Convey("Tokens of others aren't revoked", func() {
+			token, err := s.AddToken(ctx, &protocol.AccessToken{User: "t2_abcdefg2", Scopes: []string{"read"}})
+			So(err, ShouldBeNil)
+
+			So(s.RevokeToken(ctx, "t2_abcdefg3", token), ShouldBeNil)
+			So(mr.Exists(TokenKey("token", token)), ShouldBeTrue)
+
+			So(s.RevokeToken(ctx, "t2_abcdefg2", token), ShouldBeNil)
+			So(mr.Exists(TokenKey("token", token)), ShouldBeFalse)
+		})
+	})
+}
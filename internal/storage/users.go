@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// Fields of a karma hash.
+const (
+	KarmaLink    = "link"
+	KarmaComment = "comment"
+)
+
+// KarmaKey names a hash keeping karma of a user.
+func KarmaKey(prefix, user string) string {
+	return prefix + ":" + user
+}
+
+// SubmittedKey names a sorted set keeping posts of an author ordered by submission time.
+func SubmittedKey(prefix, author string) string {
+	return prefix + ":" + author
+}
+
+// ShadowbannedKey names a sorted set keeping posts an author has submitted while shadowbanned ordered by submission
+// time. Only the author lists them, so they're kept apart from the other posts.
+func ShadowbannedKey(prefix, author string) string {
+	return prefix + ":" + author
+}
+
+// newUserID makes an identifier which looks like the ones Reddit has: 8 lowercase letters or numbers prefixed with t2_.
+// Random bytes beyond the largest multiple of the alphabet size are skipped, so every character is equally likely.
+func newUserID() (string, error) {
+	const (
+		alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+		length   = 8
+		limit    = 256 - 256%len(alphabet)
+	)
+	id := make([]byte, 0, length)
+	b := make([]byte, 2*length)
+	for len(id) < length {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, c := range b {
+			if int(c) < limit && len(id) < length {
+				id = append(id, alphabet[int(c)%len(alphabet)])
+			}
+		}
+	}
+	return "t2_" + string(id), nil
+}
+
+// AddUser registers a user and issues an identifier. It returns false if the name is already taken.
+// Like subreddits, users are written directly, since the name must be reserved at once.
+func (s *storage) AddUser(ctx context.Context, user *protocol.User) (bool, error) {
+	// Identifiers are random, so a collision is unlikely but possible.
+	const attempts = 5
+	for i := 0; ; i++ {
+		if i == attempts {
+			return false, errors.New("couldn't issue a unique user identifier")
+		}
+		id, err := newUserID()
+		if err != nil {
+			return false, err
+		}
+		user.ID = id
+
+		blob, err := s.encode(user)
+		if err != nil {
+			return false, err
+		}
+		ok, err := s.client.HSetNX(ctx, s.cfg.Users, user.ID, blob).Result()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			break
+		}
+	}
+
+	// Names are case-insensitive.
+	ok, err := s.client.HSetNX(ctx, s.cfg.UserNames, strings.ToLower(user.Name), user.ID).Result()
+	if err != nil || !ok {
+		// The identifier has been reserved in vain.
+		if err := s.client.HDel(ctx, s.cfg.Users, user.ID).Err(); err != nil {
+			return false, err
+		}
+		user.ID = ""
+	}
+	return ok, err
+}
+
+// GetUsernames returns names of the users by their identifiers. Users who don't exist are left out.
+func (s *storage) GetUsernames(ctx context.Context, ids []string) (map[string]string, error) {
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	blobs, err := s.client.HMGet(ctx, s.cfg.Users, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, blob := range blobs {
+		blob, ok := blob.(string)
+		if !ok {
+			continue
+		}
+		var user protocol.User
+		if err := s.decode([]byte(blob), &user); err != nil {
+			return nil, err
+		}
+		names[ids[i]] = user.Name
+	}
+	return names, nil
+}
+
+// GetUser returns a user with the karma or nil if there is no such user.
+func (s *storage) GetUser(ctx context.Context, id string) (*protocol.User, error) {
+	blob, err := s.client.HGet(ctx, s.cfg.Users, id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var user protocol.User
+	if err := s.decode([]byte(blob), &user); err != nil {
+		return nil, err
+	}
+
+	karma, err := s.client.HGetAll(ctx, KarmaKey(s.cfg.Karma, id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if user.LinkKarma, err = atoi(karma[KarmaLink]); err != nil {
+		return nil, err
+	}
+	if user.CommentKarma, err = atoi(karma[KarmaComment]); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func atoi(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// GetSubmitted returns a page of posts of the author, the newest first, and tells whether there are more. Own
+// listings have posts the author has submitted while shadowbanned too.
+func (s *storage) GetSubmitted(ctx context.Context, author string, own bool, page int) ([]protocol.Post, bool, error) {
+	start := int64(page * s.cfg.PageSize)
+	end := start + int64(s.cfg.PageSize)
+	var ids []string
+	var err error
+	if own {
+		ids, err = s.ownSubmitted(ctx, author, start, end)
+	} else {
+		ids, err = s.client.ZRevRange(ctx, SubmittedKey(s.cfg.Submitted, author), start, end).Result()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	ids, more := s.cut(ids)
+	posts, err := s.getPosts(ctx, ids)
+	return posts, more, err
+}
+
+// ownSubmitted merges both indexes of posts of the author and returns the range of them, the newest first. The range
+// is within the first posts of each index.
+func (s *storage) ownSubmitted(ctx context.Context, author string, start, end int64) ([]string, error) {
+	var merged []redis.Z
+	for _, key := range []string{SubmittedKey(s.cfg.Submitted, author), ShadowbannedKey(s.cfg.Shadowbanned, author)} {
+		entries, err := s.client.ZRevRangeWithScores(ctx, key, 0, end).Result()
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, entries...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+
+	var ids []string
+	for i := start; i <= end && i < int64(len(merged)); i++ {
+		ids = append(ids, merged[i].Member.(string))
+	}
+	return ids, nil
+}
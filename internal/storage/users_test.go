@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetSubmitted(t *testing.T) {
+	Convey("Test posts of an author", t, func() {
+		ctx := context.Background()
+		s, mr := newTestStorage(t, &Config{
+			Posts: "post_by_id", Submitted: "submitted", Shadowbanned: "shadowbanned", PageSize: 2,
+		})
+
+		for i, id := range []string{"1a", "1b", "1c", "1d"} {
+			mr.HSet("post_by_id", id, `{"id":"`+id+`","title":"title","author":"t2_abcdefg2"}`)
+			key := "submitted:t2_abcdefg2"
+			if id == "1b" || id == "1d" {
+				key = "shadowbanned:t2_abcdefg2"
+			}
+			if _, err := mr.ZAdd(key, float64(10-i), id); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		Convey("Others don't see posts submitted while shadowbanned", func() {
+			posts, more, err := s.GetSubmitted(ctx, "t2_abcdefg2", false, 0)
+			So(err, ShouldBeNil)
+			So(feedIDs(posts), ShouldResemble, []string{"1a", "1c"})
+			So(more, ShouldBeFalse)
+		})
+
+		Convey("The author sees both kinds merged by submission time", func() {
+			posts, more, err := s.GetSubmitted(ctx, "t2_abcdefg2", true, 0)
+			So(err, ShouldBeNil)
+			So(feedIDs(posts), ShouldResemble, []string{"1a", "1b"})
+			So(more, ShouldBeTrue)
+
+			posts, more, err = s.GetSubmitted(ctx, "t2_abcdefg2", true, 1)
+			So(err, ShouldBeNil)
+			So(feedIDs(posts), ShouldResemble, []string{"1c", "1d"})
+			So(more, ShouldBeFalse)
+		})
+	})
+}
+
+func TestNewUserID(t *testing.T) {
+	Convey("Identifiers look like the ones Reddit has", t, func() {
+		for i := 0; i < 100; i++ {
+			id, err := newUserID()
+			So(err, ShouldBeNil)
+			So(id, ShouldHaveLength, 11)
+			So(strings.TrimLeft(strings.TrimPrefix(id, "t2_"), "abcdefghijklmnopqrstuvwxyz0123456789"), ShouldBeEmpty)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package validation
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"nanoreddit/pkg/protocol"
+)
+
+// trackingParams are dropped from links. A name ending with "_" is a prefix.
+var trackingParams = []string{"utm_", "fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid", "igshid", "_ga", "_hsenc", "_hsmi"}
+
+// privateNetworks aren't reachable from the internet, so links to them make no sense or probe our users' networks.
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10",
+	} {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// Link is a normalized link of a post.
+type Link struct {
+	URL string
+	// Domain is a lowercased host without "www.", like "reddit.com".
+	Domain string
+}
+
+// NormalizeLink brings a link to the canonical form: the scheme and the host are lowercased, default ports,
+// credentials, fragments and tracking parameters are dropped, and remaining parameters are sorted. So the same page
+// submitted twice looks the same.
+func NormalizeLink(link string) (*Link, error) {
+	invalid := &protocol.FieldError{Field: "link", Rule: "link", Reason: protocol.ReasonInvalidFormat}
+
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, invalid
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, invalid
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return nil, invalid
+	}
+	if !public(host) {
+		return nil, &protocol.FieldError{
+			Field:       "link",
+			Rule:        "public",
+			Reason:      protocol.ReasonInvalidValue,
+			Description: "link should point to a public host",
+		}
+	}
+	port := u.Port()
+	if port == "80" && u.Scheme == "http" || port == "443" && u.Scheme == "https" {
+		port = ""
+	}
+	u.Host = host
+	if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	if port != "" {
+		u.Host += ":" + port
+	}
+	u.User = nil
+	// A fragment only points somewhere within a page, so it never makes another link.
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if tracking(name) {
+				query.Del(name)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return &Link{URL: u.String(), Domain: strings.TrimPrefix(host, "www.")}, nil
+}
+
+func tracking(param string) bool {
+	param = strings.ToLower(param)
+	for _, name := range trackingParams {
+		if param == name || strings.HasSuffix(name, "_") && strings.HasPrefix(param, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// public tells whether a host may be reachable from the internet. Names without a dot and names ending with
+// a number aren't domains, so they are either local names or IP addresses in exotic notations like 2130706433.
+func public(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range privateNetworks {
+			if network.Contains(ip) {
+				return false
+			}
+		}
+		return !ip.IsMulticast()
+	}
+	i := strings.LastIndexByte(host, '.')
+	if i < 0 {
+		return false
+	}
+	tld := host[i+1:]
+	return strings.Trim(tld, "0123456789") != "" && !strings.HasPrefix(tld, "0x")
+}
+
+// DomainPolicy tells which domains can be linked. Every rule covers subdomains as well.
+type DomainPolicy struct {
+	// Allow permits only listed domains. An empty list permits every domain.
+	Allow []string
+	// Deny prevails over Allow.
+	Deny []string
+}
+
+// Check returns an error if a domain isn't allowed.
+func (dp *DomainPolicy) Check(domain string) error {
+	if MatchDomain(dp.Deny, domain) != "" || len(dp.Allow) != 0 && MatchDomain(dp.Allow, domain) == "" {
+		return &protocol.FieldError{
+			Field:       "link",
+			Rule:        "domain",
+			Param:       domain,
+			Reason:      protocol.ReasonDomainNotAllowed,
+			Description: "links to " + domain + " aren't allowed",
+		}
+	}
+	return nil
+}
+
+// MatchDomain returns the first listed domain which is the domain or its parent. It returns an empty string
+// if there is no such domain.
+func MatchDomain(domains []string, domain string) string {
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "www.")
+		if d != "" && (domain == d || strings.HasSuffix(domain, "."+d)) {
+			return d
+		}
+	}
+	return ""
+}
@@ -1,22 +1,163 @@
 package validation
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"reflect"
 	"regexp"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+
+	"nanoreddit/pkg/protocol"
 )
 
 func NewValidator() (func(v interface{}) error, error) {
 	v := validator.New()
-	reg, err := regexp.Compile("^t2_[a-z0-9]{8}$")
-	if err != nil {
-		return nil, fmt.Errorf("couldn't compile a regexp: %w", err)
+	// Fields are reported by their JSON names, so clients can match them against their requests.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	for tag, expr := range map[string]string{
+		"author":    "^t2_[a-z0-9]{8}$",
+		"subreddit": "^[A-Za-z0-9_]{3,21}$",
+		"username":  "^[A-Za-z0-9_-]{3,20}$",
+	} {
+		reg, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't compile a regexp: %w", err)
+		}
+		if err := v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return reg.MatchString(fl.Field().String())
+		}); err != nil {
+			return nil, fmt.Errorf("couldn't register a validation")
+		}
+	}
+
+	if err := v.RegisterValidation("notblank", validators.NotBlank); err != nil {
+		return nil, fmt.Errorf("couldn't register a validation")
 	}
-	if err := v.RegisterValidation("author", func(fl validator.FieldLevel) bool {
-		return reg.MatchString(fl.Field().String())
-	}); err != nil {
+	if err := v.RegisterValidation("link", link); err != nil {
 		return nil, fmt.Errorf("couldn't register a validation")
 	}
-	return v.Struct, nil
+	v.RegisterStructValidation(post, protocol.Post{})
+	v.RegisterStructValidation(creative, protocol.Creative{})
+
+	return func(s interface{}) error {
+		err := v.Struct(s)
+		var fieldErrors validator.ValidationErrors
+		if !errors.As(err, &fieldErrors) {
+			return err
+		}
+		return translate(reflect.TypeOf(s), fieldErrors)
+	}, nil
+}
+
+// link accepts absolute http and https URLs only.
+func link(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// post checks that a post is either a link or a self post. A self post may have a title only.
+func post(sl validator.StructLevel) {
+	p := sl.Current().Interface().(protocol.Post)
+	if len(p.Link) != 0 && len(p.Content) != 0 {
+		fe := protocol.ErrLinkContentConflict[0]
+		sl.ReportError(p.Content, fe.Field, "Content", fe.Rule, fe.Param)
+	}
+}
+
+// creative follows the same rule as a post.
+func creative(sl validator.StructLevel) {
+	c := sl.Current().Interface().(protocol.Creative)
+	if len(c.Link) != 0 && len(c.Content) != 0 {
+		fe := protocol.ErrLinkContentConflict[0]
+		sl.ReportError(c.Content, fe.Field, "Content", fe.Rule, fe.Param)
+	}
+}
+
+// translate turns errors of the validator into errors of the protocol.
+func translate(t reflect.Type, fieldErrors validator.ValidationErrors) protocol.ValidationErrors {
+	errs := make(protocol.ValidationErrors, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		errs = append(errs, protocol.FieldError{
+			Field:  path(t, fe),
+			Rule:   fe.Tag(),
+			Param:  fe.Param(),
+			Reason: reason(fe),
+		})
+	}
+	return errs
+}
+
+// path builds a JSON path of a field. Embedded structs are skipped, since their fields are inlined by JSON.
+func path(t reflect.Type, fe validator.FieldError) string {
+	names := strings.Split(fe.Namespace(), ".")[1:]
+	fields := strings.Split(fe.StructNamespace(), ".")[1:]
+
+	var p []string
+	for i, field := range fields {
+		name := names[i]
+		if j := strings.IndexByte(field, '['); j >= 0 {
+			// A JSON path addresses items of lists and maps by dots.
+			name = strings.NewReplacer("[", ".", "]", "").Replace(name)
+			field = field[:j]
+		}
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			p = append(p, names[i:]...)
+			break
+		}
+		sf, ok := t.FieldByName(field)
+		if !ok {
+			p = append(p, names[i:]...)
+			break
+		}
+		t = sf.Type
+		if sf.Anonymous {
+			continue
+		}
+		p = append(p, name)
+	}
+	return strings.Join(p, ".")
+}
+
+func reason(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "notblank", "required_with", "required_without", "required_if", "required_unless":
+		return protocol.ReasonRequired
+	case "excluded_with", "excluded_without":
+		return protocol.ReasonMutuallyExclusive
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+	case "oneof", "eq", "ne":
+		return protocol.ReasonInvalidValue
+	default:
+		return protocol.ReasonInvalidFormat
+	}
+
+	switch fe.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return protocol.ReasonOutOfRange
+	}
+	switch fe.Tag() {
+	case "min", "gt", "gte":
+		return protocol.ReasonTooShort
+	case "max", "lt", "lte":
+		return protocol.ReasonTooLong
+	}
+	return protocol.ReasonInvalidValue
 }
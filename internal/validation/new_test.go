@@ -0,0 +1,180 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"nanoreddit/pkg/protocol"
+)
+
+type item struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type embedded struct {
+	Title string `json:"title" validate:"required,max=5"`
+}
+
+type request struct {
+	embedded
+	Author string  `json:"author,omitempty" validate:"omitempty,author"`
+	Count  int     `json:"count" validate:"min=1"`
+	Kind   string  `json:"kind" validate:"oneof=link self"`
+	Items  []item  `json:"items" validate:"dive"`
+	Ignore string  `json:"-" validate:"len=2"`
+	Ptr    *string `json:"ptr" validate:"omitempty,min=3"`
+}
+
+func TestNewValidator(t *testing.T) {
+	Convey("Test NewValidator", t, func() {
+		validate, err := NewValidator()
+		So(err, ShouldBeNil)
+
+		Convey("A valid request passes", func() {
+			err := validate(&request{embedded: embedded{Title: "title"}, Count: 1, Kind: "link", Ignore: "ok"})
+
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Every invalid field is reported by its JSON path", func() {
+			short := "ab"
+			err := validate(&request{
+				embedded: embedded{Title: "too long"},
+				Author:   "somebody",
+				Kind:     "video",
+				Items:    []item{{Name: "name"}, {}},
+				Ptr:      &short,
+			})
+
+			So(err, ShouldResemble, protocol.ValidationErrors{
+				{Field: "title", Rule: "max", Param: "5", Reason: protocol.ReasonTooLong},
+				{Field: "author", Rule: "author", Reason: protocol.ReasonInvalidFormat},
+				{Field: "count", Rule: "min", Param: "1", Reason: protocol.ReasonOutOfRange},
+				{Field: "kind", Rule: "oneof", Param: "link self", Reason: protocol.ReasonInvalidValue},
+				{Field: "items.1.name", Rule: "required", Reason: protocol.ReasonRequired},
+				{Field: "Ignore", Rule: "len", Param: "2", Reason: protocol.ReasonInvalidValue},
+				{Field: "ptr", Rule: "min", Param: "3", Reason: protocol.ReasonTooShort},
+			})
+			So(err.Error(), ShouldStartWith, "the length of title should be at most 5; author has an invalid format; ")
+		})
+
+		Convey("A missing field is required", func() {
+			err := validate(&request{Count: 1, Kind: "self", Ignore: "ok"})
+
+			So(err, ShouldResemble, protocol.ValidationErrors{
+				{Field: "title", Rule: "required", Reason: protocol.ReasonRequired},
+			})
+			So(err.Error(), ShouldEqual, "title is required")
+		})
+	})
+}
+
+func TestPostRules(t *testing.T) {
+	Convey("Test rules of posts", t, func() {
+		validate, err := NewValidator()
+		So(err, ShouldBeNil)
+
+		valid := func() *protocol.SubmitRequest {
+			return &protocol.SubmitRequest{Post: protocol.Post{
+				Title:     "title",
+				Link:      "https://reddit.com",
+				Subreddit: "golang",
+			}}
+		}
+		fails := func(request interface{}, field, rule, param, reason string) {
+			So(validate(request), ShouldResemble, protocol.ValidationErrors{
+				{Field: field, Rule: rule, Param: param, Reason: reason},
+			})
+		}
+
+		Convey("A valid link post passes", func() {
+			So(validate(valid()), ShouldBeNil)
+		})
+
+		Convey("A title is required", func() {
+			request := valid()
+			request.Title = ""
+			fails(request, "title", "required", "", protocol.ReasonRequired)
+
+			request.Title = " \t"
+			fails(request, "title", "notblank", "", protocol.ReasonRequired)
+		})
+
+		Convey("A title is limited by 300 characters", func() {
+			request := valid()
+			request.Title = strings.Repeat("ы", 300)
+			So(validate(request), ShouldBeNil)
+
+			request.Title += "ы"
+			fails(request, "title", "max", "300", protocol.ReasonTooLong)
+		})
+
+		Convey("Content is limited by 40000 characters", func() {
+			request := valid()
+			request.Link = ""
+			request.Content = strings.Repeat("a", 40000)
+			So(validate(request), ShouldBeNil)
+
+			request.Content += "a"
+			fails(request, "content", "max", "40000", protocol.ReasonTooLong)
+		})
+
+		Convey("A link is an absolute http or https URL", func() {
+			request := valid()
+			for _, link := range []string{"http://reddit.com", "https://reddit.com/r/golang?sort=new#top"} {
+				request.Link = link
+				So(validate(request), ShouldBeNil)
+			}
+			for _, link := range []string{"javascript:alert(1)", "ftp://reddit.com", "reddit.com", "/r/golang", "https://", "http://[::1"} {
+				request.Link = link
+				fails(request, "link", "link", "", protocol.ReasonInvalidFormat)
+			}
+		})
+
+		Convey("A link is limited by 2048 characters", func() {
+			request := valid()
+			request.Link = "https://reddit.com/" + strings.Repeat("a", 2048-19)
+			So(validate(request), ShouldBeNil)
+
+			request.Link += "a"
+			fails(request, "link", "max", "2048", protocol.ReasonTooLong)
+		})
+
+		Convey("A subreddit is required and has a format", func() {
+			request := valid()
+			request.Subreddit = ""
+			fails(request, "subreddit", "required", "", protocol.ReasonRequired)
+
+			for _, subreddit := range []string{"go", "r/golang", "go-lang", strings.Repeat("a", 22)} {
+				request.Subreddit = subreddit
+				fails(request, "subreddit", "subreddit", "", protocol.ReasonInvalidFormat)
+			}
+		})
+
+		Convey("A post cannot be both a link and a self post", func() {
+			request := valid()
+			request.Content = "content"
+			So(validate(request), ShouldResemble, protocol.ErrLinkContentConflict)
+		})
+
+		Convey("A self post may have a title only", func() {
+			request := valid()
+			request.Link = ""
+			So(validate(request), ShouldBeNil)
+
+			request.Content = "content"
+			So(validate(request), ShouldBeNil)
+		})
+
+		Convey("Edits follow the same rules", func() {
+			empty, long := "", strings.Repeat("a", 40001)
+
+			fails(&protocol.EditRequest{Title: &empty}, "title", "notblank", "", protocol.ReasonRequired)
+			fails(&protocol.EditRequest{Title: &long}, "title", "max", "300", protocol.ReasonTooLong)
+			fails(&protocol.EditRequest{Content: &long}, "content", "max", "40000", protocol.ReasonTooLong)
+			So(validate(&protocol.EditRequest{Content: &empty}), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package protocol
+
+import "net/http"
+
+// States of a campaign.
+const (
+	CampaignScheduled = "scheduled"
+	CampaignActive    = "active"
+	CampaignPaused    = "paused"
+	CampaignEnded     = "ended"
+)
+
+// Creative is what a campaign promotes. It becomes a promoted post.
+type Creative struct {
+	Title   string `json:"title" validate:"required,notblank,max=300"`
+	Link    string `json:"link,omitempty" validate:"omitempty,max=2048,link"`
+	Content string `json:"content,omitempty" validate:"max=40000"`
+}
+
+// Targeting limits where a campaign is shown.
+type Targeting struct {
+	// Subreddits limit a campaign to feeds of the subreddits. An empty list doesn't limit it.
+	Subreddits []string `json:"subreddits,omitempty" validate:"max=50,dive,subreddit"`
+	// ExcludedSubreddits keep a campaign out of feeds of the subreddits and away from their posts in other feeds.
+	ExcludedSubreddits []string `json:"excluded_subreddits,omitempty" validate:"max=50,dive,subreddit"`
+}
+
+type Campaign struct {
+	ID       string   `json:"id,omitempty"`
+	Owner    string   `json:"owner"`
+	Name     string   `json:"name" validate:"required,notblank,max=100"`
+	Creative Creative `json:"creative"`
+	// Start and End are Unix times, a campaign is shown within [Start, End).
+	Start int64 `json:"start" validate:"required"`
+	End   int64 `json:"end" validate:"required"`
+	// DailyBudget is a number of impressions per day.
+	DailyBudget int       `json:"daily_budget" validate:"required,min=1"`
+	Targeting   Targeting `json:"targeting"`
+	Paused      bool      `json:"paused"`
+	// PostID refers to the promoted post made of the creative.
+	PostID  string `json:"post_id,omitempty"`
+	Created int64  `json:"created,omitempty"`
+	// Status is maintained by the service on responses.
+	Status string `json:"status,omitempty"`
+}
+
+// Live tells whether a campaign should be shown at the moment regardless of its budget.
+func (c *Campaign) Live(now int64) bool {
+	return !c.Paused && c.Start <= now && now < c.End
+}
+
+// State tells the status of a campaign at the moment.
+func (c *Campaign) State(now int64) string {
+	switch {
+	case now >= c.End:
+		return CampaignEnded
+	case c.Paused:
+		return CampaignPaused
+	case now < c.Start:
+		return CampaignScheduled
+	}
+	return CampaignActive
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type CampaignRequest struct {
+	Campaign
+}
+
+func (cr *CampaignRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+// CampaignUpdate changes a campaign partially. A creative cannot be changed, since it has been promoted already.
+type CampaignUpdate struct {
+	Name        *string    `json:"name,omitempty" validate:"omitempty,notblank,max=100"`
+	Start       *int64     `json:"start,omitempty"`
+	End         *int64     `json:"end,omitempty"`
+	DailyBudget *int       `json:"daily_budget,omitempty" validate:"omitempty,min=1"`
+	Targeting   *Targeting `json:"targeting,omitempty"`
+}
+
+func (cu *CampaignUpdate) Bind(r *http.Request) error {
+	return nil
+}
+
+// ErrCampaignPeriod is returned if a campaign ends before it starts.
+var ErrCampaignPeriod = ValidationErrors{{
+	Field:  "end",
+	Rule:   "gtfield",
+	Param:  "start",
+	Reason: ReasonInvalidValue,
+}}
+
+type CampaignResponse struct {
+	Data Campaign `json:"data"`
+}
+
+// HouseAdRequest promotes a post of the site itself.
+type HouseAdRequest struct {
+	Creative
+}
+
+func (hr *HouseAdRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type AdvertiserRequest struct {
+	User string `json:"user" validate:"required,author"`
+}
+
+func (ar *AdvertiserRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type AdvertisersResponse struct {
+	Data []string `json:"data"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// AdImpressed is an event of a promoted post of a campaign shown in a feed.
+type AdImpressed struct {
+	Campaign string `json:"campaign"`
+	PostID   string `json:"post_id"`
+	// Viewer is a user or an address of an anonymous client.
+	Viewer    string `json:"viewer,omitempty"`
+	Subreddit string `json:"subreddit,omitempty"`
+	Page      int    `json:"page"`
+	// Slot is a position of a post within a page starting from zero.
+	Slot      int    `json:"slot"`
+	RequestID string `json:"request_id,omitempty"`
+	Created   int64  `json:"created"`
+}
+
+// AdClicked is an event of a link of a promoted post followed by a viewer.
+type AdClicked struct {
+	Campaign  string `json:"campaign"`
+	PostID    string `json:"post_id"`
+	Viewer    string `json:"viewer,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Created   int64  `json:"created"`
+}
+
+type AdStats struct {
+	Impressions int64 `json:"impressions"`
+	Clicks      int64 `json:"clicks"`
+	// UniqueViewers is estimated, so it may be a bit off.
+	UniqueViewers int64 `json:"unique_viewers"`
+}
+
+type HourlyAdStats struct {
+	// Hour is a Unix time of the start of an hour.
+	Hour int64 `json:"hour"`
+	AdStats
+}
+
+type CampaignStats struct {
+	Campaign string `json:"campaign"`
+	AdStats
+	Hourly []HourlyAdStats `json:"hourly"`
+}
+
+type CampaignStatsResponse struct {
+	Data CampaignStats `json:"data"`
+}
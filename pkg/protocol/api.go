@@ -3,6 +3,7 @@ package protocol
 import (
 	"errors"
 	"net/http"
+	"strings"
 )
 
 type ErrorResponse struct {
@@ -17,11 +18,127 @@ type GeneralResponse struct {
 
 type SubmitRequest struct {
 	Post
+	// Resubmit allows submitting a link which has been recently submitted to the subreddit.
+	Resubmit bool `json:"resubmit,omitempty"`
 }
 
 func (sr *SubmitRequest) Bind(r *http.Request) error {
-	if len(sr.Link) != 0 && len(sr.Content) != 0 {
-		return errors.New("A post cannot have both a link and content populated")
+	return nil
+}
+
+// ErrLinkContentConflict is returned if a post has both a link and content populated. A post without both is
+// a title-only self post.
+var ErrLinkContentConflict = ValidationErrors{{
+	Field:  "content",
+	Rule:   "excluded_with",
+	Param:  "link",
+	Reason: ReasonMutuallyExclusive,
+}}
+
+type PostRef struct {
+	ID string `json:"id"`
+}
+
+type SubmitResponse struct {
+	Data PostRef `json:"data"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type EditRequest struct {
+	Author  string  `json:"author" validate:"omitempty,author"`
+	Title   *string `json:"title,omitempty" validate:"omitempty,notblank,max=300"`
+	Content *string `json:"content,omitempty" validate:"omitempty,max=40000"`
+}
+
+func (er *EditRequest) Bind(r *http.Request) error {
+	if er.Title == nil && er.Content == nil {
+		return errors.New("An edit should change either a title or content")
 	}
 	return nil
 }
+
+///////////////////////////////////////////////////////////////////////////////
+
+type DeleteRequest struct {
+	Author string `json:"author" validate:"omitempty,author"`
+}
+
+func (dr *DeleteRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// FeedQuery describes a page of a listing. The global feed is used unless a subreddit or a home feed is requested.
+type FeedQuery struct {
+	Page      int
+	Subreddit string
+	// Home merges feeds of subreddits the user subscribes to.
+	Home bool
+	User string
+	// Viewer and RequestID attribute impressions of promoted posts.
+	Viewer    string
+	RequestID string
+	// NoAds leaves promoted posts out, syndicated feeds have none since their clicks cannot be attributed.
+	NoAds bool
+	// Filter is derived from preferences of the viewer.
+	Filter FeedFilter
+}
+
+// FeedFilter leaves posts out of a feed, promoted ones included.
+type FeedFilter struct {
+	// Over18 keeps NSFW posts.
+	Over18 bool
+	// User has hidden posts, which are looked up page by page rather than read at once.
+	User string
+	// HiddenPosts are posts of the user which have been looked up and found hidden.
+	HiddenPosts map[string]bool
+	// HiddenSubreddits are left out of every feed but their own.
+	HiddenSubreddits []string
+}
+
+// Hides tells whether a post should be left out of the feed of a subreddit, or of the global or home feed if
+// the subreddit is empty.
+func (f *FeedFilter) Hides(post *Post, subreddit string) bool {
+	if post.NSFW && !f.Over18 {
+		return true
+	}
+	if f.HiddenPosts[post.ID] {
+		return true
+	}
+	for _, name := range f.HiddenSubreddits {
+		if strings.EqualFold(name, post.Subreddit) && !strings.EqualFold(name, subreddit) {
+			return true
+		}
+	}
+	return false
+}
+
+// Types of feed updates.
+const (
+	FeedUpdateNew     = "new"
+	FeedUpdateScore   = "score"
+	FeedUpdateRemoval = "removal"
+)
+
+// FeedUpdate tells live feeds that a post has entered the feeds, its score has changed, or it has left the feeds.
+type FeedUpdate struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Subreddit string `json:"subreddit"`
+	// Post is the post as it is now. A removal doesn't carry it.
+	Post *Post `json:"post,omitempty"`
+}
+
+type SubscribeRequest struct {
+	Subreddit string `json:"subreddit" validate:"required"`
+}
+
+func (sr *SubscribeRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type SubscriptionsResponse struct {
+	Data []string `json:"data"`
+}
@@ -0,0 +1,40 @@
+package protocol
+
+import "net/http"
+
+// Ban keeps a user from submitting posts site-wide or to a subreddit. Posts of a shadowbanned user are accepted,
+// but they're never materialized, so the user doesn't notice anything.
+type Ban struct {
+	User string `json:"user"`
+	// Subreddit is empty for a site-wide ban.
+	Subreddit string `json:"subreddit,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Shadow    bool   `json:"shadow,omitempty"`
+	By        string `json:"by"`
+	Created   int64  `json:"created"`
+	// Expires is zero for a permanent ban.
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// Active tells whether a ban is still in effect at the moment.
+func (b *Ban) Active(now int64) bool {
+	return b.Expires == 0 || now < b.Expires
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type BanRequest struct {
+	User string `json:"user" validate:"required,author"`
+	// Duration of a ban in seconds, up to 10 years. A ban without a duration is permanent.
+	Duration int    `json:"duration" validate:"omitempty,min=1,max=315360000"`
+	Reason   string `json:"reason" validate:"max=100"`
+	Shadow   bool   `json:"shadow"`
+}
+
+func (br *BanRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type BanResponse struct {
+	Data Ban `json:"data"`
+}
@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type Comment struct {
+	ID         string `json:"id,omitempty"`
+	PostID     string `json:"post_id"`
+	ParentID   string `json:"parent_id,omitempty"`
+	Author     string `json:"author"`
+	Body       string `json:"body"`
+	Score      int    `json:"score"`
+	Ups        int    `json:"ups"`
+	Downs      int    `json:"downs"`
+	NumReplies int    `json:"num_replies"`
+	Created    int64  `json:"created,omitempty"`
+
+	// These are populated only when a thread is rendered.
+	Replies []Comment     `json:"replies,omitempty"`
+	More    *MoreComments `json:"more,omitempty"`
+}
+
+// MoreComments is a placeholder for comments which haven't got into a response.
+// The token should be passed back to continue the listing.
+type MoreComments struct {
+	Count int    `json:"count"`
+	Token string `json:"token"`
+}
+
+const (
+	CommentSortBest = "best"
+	CommentSortTop  = "top"
+	CommentSortNew  = "new"
+)
+
+var CommentSorts = []string{CommentSortBest, CommentSortTop, CommentSortNew}
+
+// CommentsQuery describes a part of a thread to fetch.
+type CommentsQuery struct {
+	PostID   string
+	ParentID string
+	Sort     string
+	Offset   int
+	Limit    int
+	Depth    int
+	// Budget is a number of comments a response may have at all, whatever the limit and the depth are.
+	Budget int
+}
+
+// CommentVoted is an event that sets a vote of a user for a comment. Direction 0 withdraws the vote.
+type CommentVoted struct {
+	CommentID string `json:"comment_id"`
+	Voter     string `json:"voter"`
+	Direction int    `json:"dir"`
+}
+
+// EncodeCommentsToken makes an opaque token that continues a listing of replies to the parent from the offset.
+func EncodeCommentsToken(parentID string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(parentID + ":" + strconv.Itoa(offset)))
+}
+
+func DecodeCommentsToken(token string) (parentID string, offset int, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, fmt.Errorf("couldn't decode a token: %w", err)
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("malformed token: %q", token)
+	}
+	offset, err = strconv.Atoi(parts[1])
+	if err != nil || offset < 0 {
+		return "", 0, fmt.Errorf("malformed token: %q", token)
+	}
+	return parts[0], offset, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type CommentRequest struct {
+	Author   string `json:"author" validate:"omitempty,author"`
+	Body     string `json:"body" validate:"required"`
+	ParentID string `json:"parent_id,omitempty"`
+}
+
+func (cr *CommentRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type CommentResponse struct {
+	Data PostRef `json:"data"`
+}
+
+type CommentsResponse struct {
+	Comments []Comment     `json:"comments"`
+	More     *MoreComments `json:"more,omitempty"`
+}
+
+type VoteRequest struct {
+	Author    string `json:"author" validate:"omitempty,author"`
+	Direction *int   `json:"dir" validate:"required,min=-1,max=1"`
+}
+
+func (vr *VoteRequest) Bind(r *http.Request) error {
+	return nil
+}
@@ -1,6 +1,75 @@
 package protocol
 
+import "strings"
+
 type Error struct {
 	Description string `json:"description"`
 	Code        int32  `json:"code"`
+	// Reason is a machine-readable cause of an error. Unlike a description, it never changes.
+	Reason string `json:"reason,omitempty"`
+	// Field is a JSON path of an invalid field of a request, like "title" or "items.0.name".
+	Field string `json:"field,omitempty"`
+	// Rule and Param describe a failed rule, like "max" and "300".
+	Rule  string `json:"rule,omitempty"`
+	Param string `json:"param,omitempty"`
+}
+
+// Reasons of errors.
+const (
+	ReasonInsufficientScope = "insufficient_scope"
+	ReasonRateLimited       = "rate_limited"
+	ReasonAlreadySubmitted  = "already_submitted"
+
+	// Reasons of invalid fields.
+	ReasonRequired          = "required"
+	ReasonTooShort          = "too_short"
+	ReasonTooLong           = "too_long"
+	ReasonOutOfRange        = "out_of_range"
+	ReasonInvalidFormat     = "invalid_format"
+	ReasonInvalidValue      = "invalid_value"
+	ReasonMutuallyExclusive = "mutually_exclusive"
+	ReasonDomainNotAllowed  = "domain_not_allowed"
+)
+
+// FieldError describes an invalid field of a request.
+type FieldError struct {
+	Field  string
+	Rule   string
+	Param  string
+	Reason string
+	// Description is optional, it's derived from the rest of fields.
+	Description string
+}
+
+func (fe *FieldError) Error() string {
+	if fe.Description != "" {
+		return fe.Description
+	}
+	switch fe.Reason {
+	case ReasonRequired:
+		return fe.Field + " is required"
+	case ReasonTooShort:
+		return "the length of " + fe.Field + " should be at least " + fe.Param
+	case ReasonTooLong:
+		return "the length of " + fe.Field + " should be at most " + fe.Param
+	case ReasonInvalidFormat:
+		return fe.Field + " has an invalid format"
+	case ReasonMutuallyExclusive:
+		return fe.Field + " cannot be populated along with " + fe.Param
+	}
+	if fe.Param != "" {
+		return fe.Field + " fails the rule " + fe.Rule + "=" + fe.Param
+	}
+	return fe.Field + " fails the rule " + fe.Rule
+}
+
+// ValidationErrors is a list of invalid fields of a request. Every field is rendered as a separate error.
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	s := make([]string, 0, len(ve))
+	for i := range ve {
+		s = append(s, ve[i].Error())
+	}
+	return strings.Join(s, "; ")
 }
@@ -1,12 +1,88 @@
 package protocol
 
 type Post struct {
-	Title     string `json:"title"`
-	Author    string `json:"author" validate:"author"`
-	Link      string `json:"link,omitempty" validate:"omitempty,url"`
-	Subreddit string `json:"subreddit"`
-	Content   string `json:"content,omitempty"`
-	Score     int    `json:"score"`
-	Promoted  bool   `json:"promoted"`
-	NSFW      bool   `json:"nsfw"`
+	ID        string `json:"id,omitempty"`
+	Title     string `json:"title" validate:"required,notblank,max=300"`
+	Author    string `json:"author" validate:"omitempty,author"`
+	Link      string `json:"link,omitempty" validate:"omitempty,max=2048,link"`
+	Subreddit string `json:"subreddit" validate:"required,subreddit"`
+	// Domain of a link is maintained by the service.
+	Domain  string `json:"domain,omitempty"`
+	Content string `json:"content,omitempty" validate:"max=40000"`
+	// Flair is a free-form label of a post chosen by its author.
+	Flair       string `json:"flair,omitempty" validate:"max=64"`
+	Score       int    `json:"score"`
+	Promoted    bool   `json:"promoted"`
+	NSFW        bool   `json:"nsfw"`
+	NumComments int    `json:"num_comments"`
+	Created     int64  `json:"created,omitempty"`
+	Edited      int64  `json:"edited,omitempty"`
+	Deleted     bool   `json:"deleted,omitempty"`
+	// Removed posts are hidden by moderators, approved ones have been reviewed and kept.
+	Removed  bool `json:"removed,omitempty"`
+	Approved bool `json:"approved,omitempty"`
+	// Spam is a decision of spam checks, it's maintained by the service. Only moderators see it, see Public.
+	Spam *SpamDecision `json:"spam,omitempty"`
+	// NSFWOverride is set if the service has marked a post NSFW instead of the author.
+	NSFWOverride *NSFWOverride `json:"nsfw_override,omitempty"`
+	// Campaign refers to an ad campaign which has promoted a post, it's maintained by the service.
+	Campaign string `json:"campaign,omitempty"`
+	// Shadowbanned posts are kept out of listings and shown to their authors only, it's maintained by the service.
+	Shadowbanned bool `json:"shadowbanned,omitempty"`
+	// HouseAd is a promoted post of the site itself, which administrators create. It's maintained by the service.
+	HouseAd bool `json:"house_ad,omitempty"`
+}
+
+// Public is a post the way anyone may see it: the spam decision is left to events and moderators, and nobody
+// notices a shadowban.
+func (p Post) Public() Post {
+	p.Spam = nil
+	p.Shadowbanned = false
+	return p
+}
+
+// PublicPosts makes public copies of posts.
+func PublicPosts(posts []Post) []Post {
+	if posts == nil {
+		return nil
+	}
+	result := make([]Post, 0, len(posts))
+	for i := range posts {
+		result = append(result, posts[i].Public())
+	}
+	return result
+}
+
+// Rules which make a post NSFW.
+const (
+	NSFWRuleSubreddit = "subreddit"
+	NSFWRuleKeyword   = "keyword"
+	NSFWRuleDomain    = "domain"
+)
+
+// NSFWOverride explains why a post is NSFW: a rule and what it has matched, like a keyword.
+type NSFWOverride struct {
+	Rule  string `json:"rule"`
+	Match string `json:"match"`
+}
+
+// DeletedMarker replaces every user-supplied field of a soft-deleted post.
+const DeletedMarker = "[deleted]"
+
+// PostEdited is an event that changes the title and/or the content of an existing post.
+type PostEdited struct {
+	ID      string  `json:"id"`
+	Title   *string `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+	Edited  int64   `json:"edited"`
+	// NSFWOverride is set if a new title or content makes a post NSFW.
+	NSFWOverride *NSFWOverride `json:"nsfw_override,omitempty"`
+	// Spam is a decision of spam checks on the edited post.
+	Spam *SpamDecision `json:"spam,omitempty"`
+}
+
+// PostDeleted is an event that soft-deletes an existing post.
+type PostDeleted struct {
+	ID      string `json:"id"`
+	Deleted int64  `json:"deleted"`
 }
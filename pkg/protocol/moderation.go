@@ -0,0 +1,81 @@
+package protocol
+
+import "net/http"
+
+// Reasons to report a post.
+const (
+	ReportSpam       = "spam"
+	ReportNSFW       = "nsfw"
+	ReportHarassment = "harassment"
+	ReportOther      = "other"
+)
+
+// Actions of moderators.
+const (
+	ModApprove         = "approve"
+	ModRemove          = "remove"
+	ModSpam            = "spam"
+	ModAddModerator    = "add_moderator"
+	ModRemoveModerator = "remove_moderator"
+	ModBanUser         = "ban_user"
+	ModUnbanUser       = "unban_user"
+)
+
+// PostReported is an event that brings a post to the attention of moderators of its subreddit.
+type PostReported struct {
+	ID       string `json:"id"`
+	Reporter string `json:"reporter"`
+	Reason   string `json:"reason"`
+	Reported int64  `json:"reported"`
+}
+
+// ModAction is an entry of a moderation log. Entries are never changed once they are written.
+// Actions on posts are events of the stream as well.
+type ModAction struct {
+	// ID is assigned by the log.
+	ID        string `json:"id,omitempty"`
+	Subreddit string `json:"subreddit"`
+	Moderator string `json:"moderator"`
+	Action    string `json:"action"`
+	// Target is a post or a user depending on the action.
+	Target  string `json:"target"`
+	Reason  string `json:"reason,omitempty"`
+	Created int64  `json:"created"`
+}
+
+// ModQueueItem is a post waiting for moderators along with the number of reports per reason.
+type ModQueueItem struct {
+	Post
+	Reports map[string]int `json:"reports,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type ReportRequest struct {
+	Reason string `json:"reason" validate:"required,oneof=spam nsfw harassment other"`
+}
+
+func (rr *ReportRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type ModerateRequest struct {
+	// Reason is shown in the moderation log.
+	Reason string `json:"reason" validate:"max=100"`
+}
+
+func (mr *ModerateRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type ModeratorRequest struct {
+	User string `json:"user" validate:"required,author"`
+}
+
+func (mr *ModeratorRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type ModeratorsResponse struct {
+	Data []string `json:"data"`
+}
@@ -0,0 +1,54 @@
+package protocol
+
+// Kinds of things in the Reddit API.
+const (
+	RedditKindListing = "Listing"
+	RedditKindLink    = "t3"
+)
+
+// RedditListing is a listing in the format of the Reddit API, so that its clients can read listings of the service.
+type RedditListing struct {
+	Kind string            `json:"kind"`
+	Data RedditListingData `json:"data"`
+}
+
+type RedditListingData struct {
+	Children []RedditThing `json:"children"`
+	// After and Before are cursors of the next and the previous page, they're null at the ends of a listing.
+	After  *string `json:"after"`
+	Before *string `json:"before"`
+	Dist   int     `json:"dist"`
+}
+
+type RedditThing struct {
+	Kind string     `json:"kind"`
+	Data RedditLink `json:"data"`
+}
+
+// RedditLink is a post under the names of fields of the Reddit API. Author is a name of the author, and
+// AuthorFullname is the identifier, like they are there.
+type RedditLink struct {
+	ID string `json:"id"`
+	// Name is a fullname of a post, its identifier prefixed by its kind.
+	Name                  string  `json:"name"`
+	Title                 string  `json:"title"`
+	Author                string  `json:"author"`
+	AuthorFullname        string  `json:"author_fullname"`
+	Subreddit             string  `json:"subreddit"`
+	SubredditNamePrefixed string  `json:"subreddit_name_prefixed"`
+	URL                   string  `json:"url"`
+	Permalink             string  `json:"permalink"`
+	Domain                string  `json:"domain"`
+	IsSelf                bool    `json:"is_self"`
+	Selftext              string  `json:"selftext"`
+	LinkFlairText         *string `json:"link_flair_text"`
+	Score                 int     `json:"score"`
+	Ups                   int     `json:"ups"`
+	NumComments           int     `json:"num_comments"`
+	Over18                bool    `json:"over_18"`
+	Promoted              bool    `json:"promoted"`
+	Created               float64 `json:"created"`
+	CreatedUTC            float64 `json:"created_utc"`
+	// Edited is false unless a post has been edited, and the time of the edit otherwise.
+	Edited interface{} `json:"edited"`
+}
@@ -0,0 +1,23 @@
+package protocol
+
+// Outcomes of spam scoring.
+const (
+	SpamAccept = "accept"
+	// SpamQueue keeps a post away from listings until a moderator approves it.
+	SpamQueue  = "queue"
+	SpamReject = "reject"
+)
+
+// SpamDecision is a verdict of spam checks on a submitted post. It's recorded on the event for auditability.
+type SpamDecision struct {
+	Outcome string       `json:"outcome"`
+	Score   float64      `json:"score"`
+	Reasons []SpamReason `json:"reasons,omitempty"`
+}
+
+// SpamReason is a contribution of a single check to a score.
+type SpamReason struct {
+	Check       string  `json:"check"`
+	Score       float64 `json:"score"`
+	Description string  `json:"description"`
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import "net/http"
+
+const (
+	SubmissionTypeAny  = "any"
+	SubmissionTypeLink = "link"
+	SubmissionTypeSelf = "self"
+)
+
+type Subreddit struct {
+	Name        string `json:"name" validate:"subreddit"`
+	Title       string `json:"title" validate:"max=100"`
+	Description string `json:"description" validate:"max=500"`
+	// NSFW makes every post of the subreddit NSFW.
+	NSFW           bool   `json:"nsfw"`
+	SubmissionType string `json:"submission_type" validate:"oneof=any link self"`
+	Creator        string `json:"creator" validate:"omitempty,author"`
+	Created        int64  `json:"created,omitempty"`
+}
+
+// Allows tells whether a post of the kind can be submitted to the subreddit.
+func (s *Subreddit) Allows(post *Post) bool {
+	switch s.SubmissionType {
+	case SubmissionTypeLink:
+		return len(post.Link) != 0
+	case SubmissionTypeSelf:
+		return len(post.Link) == 0
+	}
+	return true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type SubredditRequest struct {
+	Subreddit
+}
+
+func (sr *SubredditRequest) Bind(r *http.Request) error {
+	if sr.SubmissionType == "" {
+		sr.SubmissionType = SubmissionTypeAny
+	}
+	return nil
+}
+
+type SubredditResponse struct {
+	Data Subreddit `json:"data"`
+}
@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scopes limit what an access token is allowed to do.
+const (
+	ScopeRead     = "read"
+	ScopeSubmit   = "submit"
+	ScopeVote     = "vote"
+	ScopeModPosts = "modposts"
+)
+
+// Scopes are all known scopes. First-party credentials are granted all of them.
+var Scopes = []string{ScopeRead, ScopeSubmit, ScopeVote, ScopeModPosts}
+
+// ParseScopes splits a space-delimited list of scopes like OAuth2 does.
+func ParseScopes(s string) ([]string, error) {
+	scopes := strings.Fields(s)
+	for _, scope := range scopes {
+		if !HasScope(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope: %q", scope)
+		}
+	}
+	return scopes, nil
+}
+
+// HasScope tells whether a scope is in a list.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessToken is what an API token grants. A token without an expiration time is valid until it's revoked.
+// Tokens issued along with registrations are first-party, while tokens issued to clients aren't.
+type AccessToken struct {
+	User       string   `json:"user"`
+	Scopes     []string `json:"scopes"`
+	Expires    int64    `json:"expires,omitempty"`
+	FirstParty bool     `json:"first_party,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type TokenRequest struct {
+	// Scope is a space-delimited list of scopes.
+	Scope string `json:"scope" validate:"required"`
+	// ExpiresIn is a lifetime of a token in seconds.
+	ExpiresIn int `json:"expires_in" validate:"omitempty,min=1"`
+}
+
+func (tr *TokenRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+// TokenResponse follows RFC 6749, so it isn't wrapped into data.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+type RevokeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (rr *RevokeRequest) Bind(r *http.Request) error {
+	return nil
+}
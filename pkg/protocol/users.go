@@ -0,0 +1,72 @@
+package protocol
+
+import (
+	"errors"
+	"net/http"
+)
+
+type User struct {
+	ID           string `json:"id"`
+	Name         string `json:"name" validate:"username"`
+	LinkKarma    int    `json:"link_karma"`
+	CommentKarma int    `json:"comment_karma"`
+	Created      int64  `json:"created,omitempty"`
+}
+
+// PostVoted is an event that sets a vote of a user for a post. Direction 0 withdraws the vote.
+type PostVoted struct {
+	PostID    string `json:"post_id"`
+	Voter     string `json:"voter"`
+	Direction int    `json:"dir"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+type UserRequest struct {
+	Name string `json:"name" validate:"username"`
+}
+
+func (ur *UserRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+type UserResponse struct {
+	Data User `json:"data"`
+	// Token is an API token issued along with a registration. It's never shown again.
+	Token string `json:"token,omitempty"`
+}
+
+type Session struct {
+	Token   string `json:"token"`
+	Expires int64  `json:"expires"`
+}
+
+type SessionResponse struct {
+	Data Session `json:"data"`
+}
+
+// Preferences of a user apply to feeds they view.
+type Preferences struct {
+	// Over18 shows NSFW posts. They're hidden by default, and anonymous viewers never see them.
+	Over18 bool `json:"over_18"`
+	// HiddenSubreddits are left out of the global and home feeds, but their own feeds are shown as usual.
+	HiddenSubreddits []string `json:"hidden_subreddits"`
+}
+
+// PreferencesRequest changes preferences. Missing fields are left as they are, and an empty list of subreddits
+// clears it.
+type PreferencesRequest struct {
+	Over18           *bool    `json:"over_18,omitempty"`
+	HiddenSubreddits []string `json:"hidden_subreddits,omitempty" validate:"omitempty,max=100,dive,subreddit"`
+}
+
+func (pr *PreferencesRequest) Bind(r *http.Request) error {
+	if pr.Over18 == nil && pr.HiddenSubreddits == nil {
+		return errors.New("An update should change some preferences")
+	}
+	return nil
+}
+
+type PreferencesResponse struct {
+	Data Preferences `json:"data"`
+}
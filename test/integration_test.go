@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/go-resty/resty/v2"
@@ -69,6 +70,18 @@ import (
 // 	os.Exit(result)
 // }
 
+// anonymize drops fields populated by the service so that a feed can be compared with submitted posts.
+func anonymize(feed []protocol.Post) []protocol.Post {
+	result := make([]protocol.Post, 0, len(feed))
+	for _, post := range feed {
+		post.ID = ""
+		post.Created = 0
+		post.Spam = nil
+		result = append(result, post)
+	}
+	return result
+}
+
 func TestNanoreddit(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -93,7 +106,11 @@ func TestNanoreddit(t *testing.T) {
 			So(err, ShouldBeNil)
 		}
 		{
-			err := redisClient.Del(ctx, "promotion").Err()
+			err := redisClient.Del(ctx, "promotion_weights", "promotion_state", "promotion_targets", "house_ads", "house_ads_state", "campaign_by_id").Err()
+			So(err, ShouldBeNil)
+		}
+		{
+			err := redisClient.Del(ctx, "post_by_id").Err()
 			So(err, ShouldBeNil)
 		}
 		{
@@ -102,8 +119,104 @@ func TestNanoreddit(t *testing.T) {
 		}
 
 		c := resty.New()
+
+		// Posts are submitted on behalf of an authenticated user.
+		var user protocol.UserResponse
+		{
+			name := "it-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+			resp, err := c.R().SetBody(&protocol.UserRequest{Name: name}).SetResult(&user).Post("http://localhost:8080/users")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+		}
+		c.SetAuthToken(user.Token)
+		author := user.Data.ID
 		r := c.R()
 
+		// Posts are promoted by campaigns, which only advertisers may start.
+		{
+			err := redisClient.SAdd(ctx, "advertisers", author).Err()
+			So(err, ShouldBeNil)
+		}
+
+		// Scores are maintained by votes, so posts are ranked by voters.
+		const voterCount = 30
+		voters := make([]*resty.Client, 0, voterCount)
+		for i := 0; i < voterCount; i++ {
+			var voter protocol.UserResponse
+			name := fmt.Sprintf("it-%s-%d", strconv.FormatInt(time.Now().UnixNano(), 36), i)
+			resp, err := resty.New().R().SetBody(&protocol.UserRequest{Name: name}).SetResult(&voter).Post("http://localhost:8080/users")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			voters = append(voters, resty.New().SetAuthToken(voter.Token))
+		}
+
+		// submit publishes a post and waits until it's materialized, so it can be voted for.
+		submit := func(post *protocol.Post) string {
+			var submitted protocol.SubmitResponse
+			resp, err := c.R().SetBody(post).SetResult(&submitted).Post("http://localhost:8080/submit")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			for attempt := 0; attempt < 100; attempt++ {
+				resp, err := c.R().Get("http://localhost:8080/posts/" + submitted.Data.ID)
+				So(err, ShouldBeNil)
+				if resp.StatusCode() == http.StatusOK {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return submitted.Data.ID
+		}
+
+		// rate gives a post the score by votes of as many voters.
+		rate := func(id string, score int) {
+			dir := 1
+			if score < 0 {
+				dir, score = -1, -score
+			}
+			So(score, ShouldBeLessThanOrEqualTo, voterCount)
+			for _, voter := range voters[:score] {
+				resp, err := voter.R().SetBody(&protocol.VoteRequest{Direction: &dir}).Post("http://localhost:8080/posts/" + id + "/vote")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			}
+		}
+
+		// promote starts an untargeted campaign, so its post is shown in the global feed.
+		promote := func(title string) {
+			now := time.Now().Unix()
+			campaign := protocol.Campaign{
+				Name:        title,
+				Creative:    protocol.Creative{Title: title},
+				Start:       now,
+				End:         now + 24*60*60,
+				DailyBudget: 1000000000,
+			}
+			resp, err := c.R().SetBody(&protocol.CampaignRequest{Campaign: campaign}).Post("http://localhost:8080/ads/campaigns")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+		}
+
+		// scheduled waits until the ads scheduler puts as many campaigns into the rotation.
+		scheduled := func(count int64) {
+			for attempt := 0; attempt < 100; attempt++ {
+				n, err := redisClient.HLen(ctx, "promotion_weights").Result()
+				So(err, ShouldBeNil)
+				if n == count {
+					return
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			So(redisClient.HLen(ctx, "promotion_weights").Val(), ShouldEqual, count)
+		}
+
+		// Posts can be submitted only to an existing subreddit.
+		const subreddit = "integration"
+		{
+			resp, err := r.SetBody(&protocol.Subreddit{Name: subreddit}).Post("http://localhost:8080/subreddits")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode(), ShouldBeIn, http.StatusOK, http.StatusConflict)
+		}
+
 		Convey("Initially, we've got an empty feed", func() {
 			var feed []protocol.Post
 			resp, err := r.SetResult(&feed).Get("http://localhost:8080/feed")
@@ -114,72 +227,52 @@ func TestNanoreddit(t *testing.T) {
 
 		Convey("Posts are stored in the order sorted by their score", func() {
 			var posts []protocol.Post
-			direction := 1
-			for pages := 1; pages <= 10; pages++ {
-				for i := 0; i < 5; i++ {
-					for score := 6 * direction; score != direction; score -= direction {
-						post := protocol.Post{
-							Author:    fmt.Sprintf("t2_%08x", len(posts)),
-							Subreddit: fmt.Sprintf("subreddit %d", len(posts)),
-							Title:     fmt.Sprintf("title %d", len(posts)),
-							Score:     len(posts)*5 + score + 10,
-						}
-						{
-							resp, err := r.SetBody(&post).Post("http://localhost:8080/submit")
-							So(err, ShouldBeNil)
-							So(string(resp.Body()), ShouldBeEmpty)
-							So(resp.StatusCode(), ShouldEqual, http.StatusOK)
-						}
-						{
-							posts = append(posts, post)
-							sort.Slice(posts, func(i, j int) bool {
-								return posts[i].Score > posts[j].Score
-							})
-						}
-						for p := 0; p < pages; p++ {
-							var feed []protocol.Post
-							resp, err := r.SetResult(&feed).SetQueryParam("page", strconv.Itoa(p)).Get("http://localhost:8080/feed")
-							So(err, ShouldBeNil)
-							{
-								begin, end := p*pageSize, (p+1)*pageSize
-								if end > len(posts) {
-									end = len(posts)
-								}
-								expectation := posts[begin:end]
-								So(feed, assertions.ShouldResemble, expectation)
-							}
-							So(resp.StatusCode(), ShouldEqual, http.StatusOK)
-						}
-						// The next page should be empty
-						{
-							var feed []protocol.Post
-							resp, err := r.SetResult(&feed).SetQueryParam("page", strconv.Itoa(pages)).Get("http://localhost:8080/feed")
-							So(err, ShouldBeNil)
-							So(feed, ShouldBeEmpty)
-							So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			// Scores are spread around zero, so posts are ranked apart from the order of submission.
+			const count = 2*voterCount + 1
+			for i := 0; i < count; i++ {
+				post := protocol.Post{
+					Author:    author,
+					Subreddit: subreddit,
+					Title:     fmt.Sprintf("title %d", len(posts)),
+				}
+				post.Score = i*7%count - voterCount
+				rate(submit(&post), post.Score)
+				{
+					posts = append(posts, post)
+					sort.Slice(posts, func(i, j int) bool {
+						return posts[i].Score > posts[j].Score
+					})
+				}
+				pages := (len(posts) + pageSize - 1) / pageSize
+				for p := 0; p < pages; p++ {
+					var feed []protocol.Post
+					resp, err := r.SetResult(&feed).SetQueryParam("page", strconv.Itoa(p)).Get("http://localhost:8080/feed")
+					So(err, ShouldBeNil)
+					{
+						begin, end := p*pageSize, (p+1)*pageSize
+						if end > len(posts) {
+							end = len(posts)
 						}
+						expectation := posts[begin:end]
+						So(anonymize(feed), assertions.ShouldResemble, expectation)
 					}
+					So(resp.StatusCode(), ShouldEqual, http.StatusOK)
 				}
-			}
-		})
-
-		Convey("Promoted posts should not appear if not-promoted ones are too few", func() {
-			var promoted []protocol.Post
-			for i := 0; i < 10; i++ {
-				post := protocol.Post{
-					Author:    fmt.Sprintf("t2_%08x", i),
-					Subreddit: fmt.Sprintf("subreddit %d", i),
-					Title:     fmt.Sprintf("XXX title %d", i),
-					Score:     123,
-					Promoted:  true,
-				}
+				// The next page should be empty
 				{
-					resp, err := r.SetBody(&post).Post("http://localhost:8080/submit")
+					var feed []protocol.Post
+					resp, err := r.SetResult(&feed).SetQueryParam("page", strconv.Itoa(pages)).Get("http://localhost:8080/feed")
 					So(err, ShouldBeNil)
-					So(string(resp.Body()), ShouldBeEmpty)
+					So(feed, ShouldBeEmpty)
 					So(resp.StatusCode(), ShouldEqual, http.StatusOK)
 				}
-				promoted = append(promoted, post)
+			}
+		})
+
+		Convey("Promoted posts should not appear if not-promoted ones are too few", func() {
+			const campaigns = 10
+			for i := 0; i < campaigns; i++ {
+				promote(fmt.Sprintf("XXX title %d", i))
 				{
 					var feed []protocol.Post
 					resp, err := r.SetResult(&feed).Get("http://localhost:8080/feed")
@@ -188,23 +281,19 @@ func TestNanoreddit(t *testing.T) {
 					So(resp.StatusCode(), ShouldEqual, http.StatusOK)
 				}
 			}
+			scheduled(campaigns)
 
-			score := 999
+			score := voterCount
 			var posts []protocol.Post
-			for pages := 1; pages <= 10; pages++ {
+			for pages := 1; pages <= 2; pages++ {
 				for i := 0; i < pageSize; i++ {
 					post := protocol.Post{
-						Author:    fmt.Sprintf("t2_%08x", score),
-						Subreddit: fmt.Sprintf("subreddit %d", score),
+						Author:    author,
+						Subreddit: subreddit,
 						Title:     fmt.Sprintf("title %d", score),
 						Score:     score,
 					}
-					{
-						resp, err := r.SetBody(&post).Post("http://localhost:8080/submit")
-						So(err, ShouldBeNil)
-						So(string(resp.Body()), ShouldBeEmpty)
-						So(resp.StatusCode(), ShouldEqual, http.StatusOK)
-					}
+					rate(submit(&post), post.Score)
 					posts = append(posts, post)
 					for p := 0; p < pages; p++ {
 						var feed []protocol.Post
@@ -218,17 +307,17 @@ func TestNanoreddit(t *testing.T) {
 							expectation := posts[begin:end]
 							switch {
 							case len(expectation) < 3:
-								So(feed, assertions.ShouldResemble, expectation)
+								So(anonymize(feed), assertions.ShouldResemble, expectation)
 							case 3 <= len(expectation) && len(expectation) < 16:
-								So(feed[0], assertions.ShouldResemble, expectation[0])
+								So(anonymize(feed[0:1])[0], assertions.ShouldResemble, expectation[0])
 								So(feed[1].Promoted, assertions.ShouldBeTrue)
-								So(feed[2:], assertions.ShouldResemble, expectation[1:])
+								So(anonymize(feed[2:]), assertions.ShouldResemble, expectation[1:])
 							case 16 <= len(expectation):
-								So(feed[0], assertions.ShouldResemble, expectation[0])
+								So(anonymize(feed[0:1])[0], assertions.ShouldResemble, expectation[0])
 								So(feed[1].Promoted, assertions.ShouldBeTrue)
-								So(feed[2:15], assertions.ShouldResemble, expectation[1:14])
+								So(anonymize(feed[2:15]), assertions.ShouldResemble, expectation[1:14])
 								So(feed[15].Promoted, assertions.ShouldBeTrue)
-								So(feed[16:], assertions.ShouldResemble, expectation[14:])
+								So(anonymize(feed[16:]), assertions.ShouldResemble, expectation[14:])
 							}
 						}
 						So(resp.StatusCode(), ShouldEqual, http.StatusOK)
@@ -239,22 +328,16 @@ func TestNanoreddit(t *testing.T) {
 		})
 
 		Convey("Promoted posts won't appear in the neighborhood to NSFW-posts", func() {
-			var promoted []protocol.Post
-			for i := 0; i < 10; i++ {
-				post := protocol.Post{
-					Author:    fmt.Sprintf("t2_%08x", i),
-					Subreddit: fmt.Sprintf("subreddit %d", i),
-					Title:     fmt.Sprintf("XXX title %d", i),
-					Score:     123,
-					Promoted:  true,
-				}
-				{
-					resp, err := r.SetBody(&post).Post("http://localhost:8080/submit")
-					So(err, ShouldBeNil)
-					So(string(resp.Body()), ShouldBeEmpty)
-					So(resp.StatusCode(), ShouldEqual, http.StatusOK)
-				}
-				promoted = append(promoted, post)
+			// NSFW posts are hidden by default.
+			{
+				resp, err := r.SetBody(map[string]interface{}{"over_18": true}).Patch("http://localhost:8080/prefs")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			}
+
+			const campaigns = 10
+			for i := 0; i < campaigns; i++ {
+				promote(fmt.Sprintf("XXX title %d", i))
 				{
 					var feed []protocol.Post
 					resp, err := r.SetResult(&feed).Get("http://localhost:8080/feed")
@@ -263,24 +346,20 @@ func TestNanoreddit(t *testing.T) {
 					So(resp.StatusCode(), ShouldEqual, http.StatusOK)
 				}
 			}
+			scheduled(campaigns)
 
-			score := 999
+			score := voterCount
 			var posts []protocol.Post
-			for pages := 1; pages <= 10; pages++ {
+			for pages := 1; pages <= 2; pages++ {
 				for i := 0; i < pageSize; i++ {
 					post := protocol.Post{
-						Author:    fmt.Sprintf("t2_%08x", score),
-						Subreddit: fmt.Sprintf("subreddit %d", score),
+						Author:    author,
+						Subreddit: subreddit,
 						Title:     fmt.Sprintf("title %d", score),
 						Score:     score,
 						NSFW:      true,
 					}
-					{
-						resp, err := r.SetBody(&post).Post("http://localhost:8080/submit")
-						So(err, ShouldBeNil)
-						So(string(resp.Body()), ShouldBeEmpty)
-						So(resp.StatusCode(), ShouldEqual, http.StatusOK)
-					}
+					rate(submit(&post), post.Score)
 					posts = append(posts, post)
 					for p := 0; p < pages; p++ {
 						var feed []protocol.Post
@@ -292,7 +371,7 @@ func TestNanoreddit(t *testing.T) {
 								end = len(posts)
 							}
 							expectation := posts[begin:end]
-							So(feed, assertions.ShouldResemble, expectation)
+							So(anonymize(feed), assertions.ShouldResemble, expectation)
 						}
 						So(resp.StatusCode(), ShouldEqual, http.StatusOK)
 					}